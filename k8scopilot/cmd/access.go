@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/sashabaranov/go-openai"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// accessCheck 是一次 SelfSubjectAccessReview 要检查的权限
+type accessCheck struct {
+	Verb      string
+	Group     string
+	Resource  string
+	Namespace string
+	Name      string
+}
+
+func (a accessCheck) String() string {
+	resource := a.Resource
+	if a.Group != "" {
+		resource += "." + a.Group
+	}
+	if a.Name != "" {
+		resource += "/" + a.Name
+	}
+	if a.Namespace == "" {
+		return a.Verb + " " + resource
+	}
+	return fmt.Sprintf("%s %s（命名空间 %s）", a.Verb, resource, a.Namespace)
+}
+
+func gvrAccess(verb string, gvr schema.GroupVersionResource, namespace, name string) accessCheck {
+	return accessCheck{Verb: verb, Group: gvr.Group, Resource: gvr.Resource, Namespace: namespace, Name: name}
+}
+
+// forbiddenError 表示当前身份缺少执行工具所需的权限，作为工具结果返回给模型
+type forbiddenError struct {
+	Tool    string
+	Missing []accessCheck
+}
+
+func (e *forbiddenError) Error() string {
+	missing := make([]string, 0, len(e.Missing))
+	for _, m := range e.Missing {
+		missing = append(missing, m.String())
+	}
+	return fmt.Sprintf("forbidden: 当前身份没有执行 %s 所需的权限，缺少: %s", e.Tool, strings.Join(missing, "; "))
+}
+
+func isForbidden(err error) bool {
+	var forbidden *forbiddenError
+	return errors.As(err, &forbidden)
+}
+
+// 同一会话中重复的检查只请求一次 apiserver
+var accessCache = map[accessCheck]bool{}
+
+// canI 用 SelfSubjectAccessReview 检查当前身份是否拥有权限
+// 检查本身失败时视为允许，由实际请求返回 apiserver 的错误
+func canI(clientGo *utils.ClientGo, check accessCheck) bool {
+	if allowed, ok := accessCache[check]; ok {
+		return allowed
+	}
+	review, err := clientGo.ClientSet.AuthorizationV1().SelfSubjectAccessReviews().Create(context.TODO(), &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: check.Namespace,
+				Verb:      check.Verb,
+				Group:     check.Group,
+				Resource:  check.Resource,
+				Name:      check.Name,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return true
+	}
+	accessCache[check] = review.Status.Allowed
+	return review.Status.Allowed
+}
+
+// requireAccess 在工具执行前检查所需权限，缺少任意一项时返回 forbiddenError
+func requireAccess(clientGo *utils.ClientGo, tool string, checks ...accessCheck) error {
+	var missing []accessCheck
+	for _, check := range checks {
+		if !canI(clientGo, check) {
+			missing = append(missing, check)
+		}
+	}
+	if len(missing) > 0 {
+		return &forbiddenError{Tool: tool, Missing: missing}
+	}
+	return nil
+}
+
+// toolAccessChecks 是工具在当前命名空间中的典型权限，用来决定是否向模型提供该工具
+func toolAccessChecks(tool string) []accessCheck {
+	var checks []accessCheck
+	switch tool {
+	case "generateAndDeployResource":
+		for _, t := range []string{"pod", "deployment", "service", "configmap"} {
+			checks = append(checks, gvrAccess("create", toolResources[t], namespace, ""))
+		}
+	case "queryResource", "deleteResource":
+		verb := "list"
+		if tool == "deleteResource" {
+			verb = "delete"
+		}
+		for _, t := range toolResourceTypes {
+			checks = append(checks, gvrAccess(verb, toolResources[t], namespace, ""))
+		}
+	case "rollbackDeployment":
+		checks = rollbackAccessChecks(namespace, "")
+	}
+	return checks
+}
+
+// availableTools 去掉当前身份完全无法使用的工具，部分可用的工具在描述中注明缺少的权限
+func availableTools(tools []openai.Tool) []openai.Tool {
+	clientGo, err := newClientGo()
+	if err != nil {
+		return tools
+	}
+	var out []openai.Tool
+	for _, tool := range tools {
+		checks := toolAccessChecks(tool.Function.Name)
+		var missing []string
+		for _, check := range checks {
+			if !canI(clientGo, check) {
+				missing = append(missing, check.String())
+			}
+		}
+		if len(checks) > 0 && len(missing) == len(checks) {
+			continue
+		}
+		if len(missing) > 0 {
+			f := *tool.Function
+			f.Description += "。当前身份缺少以下权限，涉及时会被拒绝: " + strings.Join(missing, "; ")
+			tool.Function = &f
+		}
+		out = append(out, tool)
+	}
+	return out
+}
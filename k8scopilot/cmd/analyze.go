@@ -6,6 +6,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -20,6 +21,15 @@ and usage of using your command. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
+	// 所有子命令共用 -n、-A、--selector、--exclude-namespace 和 --since
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		s, err := buildScope()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		scope = s
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("analyze called")
 	},
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+)
+
+// auditCmd 浏览 copilot 执行过的操作
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "查看 copilot 对集群执行过的操作",
+}
+
+var auditListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出审计记录，最新的在最后",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		records, err := readAudit()
+		if err != nil {
+			fmt.Println("读取审计日志失败:", err)
+			return
+		}
+		var filtered []utils.AuditRecord
+		for _, r := range records {
+			if auditSince > 0 && r.Time.Before(time.Now().Add(-auditSince)) {
+				continue
+			}
+			if auditTool != "" && r.Tool != auditTool {
+				continue
+			}
+			filtered = append(filtered, r)
+		}
+		if len(filtered) == 0 {
+			fmt.Println("没有审计记录")
+			return
+		}
+		if auditLimit > 0 && len(filtered) > auditLimit {
+			filtered = filtered[len(filtered)-auditLimit:]
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tUSER\tCONTEXT\tTOOL\tRESULT\tARGUMENTS")
+		for _, r := range filtered {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Time.Format("2006-01-02 15:04:05"), r.User, r.Context, r.Tool, r.Result, truncateRunes(r.Arguments, 60))
+		}
+		w.Flush()
+	},
+}
+
+var auditShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "查看一条审计记录的完整内容，id 可以只写前缀",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		records, err := readAudit()
+		if err != nil {
+			fmt.Println("读取审计日志失败:", err)
+			return
+		}
+		var matched []utils.AuditRecord
+		for _, r := range records {
+			if strings.HasPrefix(r.ID, args[0]) {
+				matched = append(matched, r)
+			}
+		}
+		switch len(matched) {
+		case 0:
+			fmt.Println("找不到审计记录", args[0])
+			return
+		case 1:
+		default:
+			fmt.Printf("前缀 %s 匹配到 %d 条记录，请写出更长的 id\n", args[0], len(matched))
+			return
+		}
+
+		r := matched[0]
+		fields := [][2]string{
+			{"ID", r.ID},
+			{"时间", r.Time.Format("2006-01-02 15:04:05")},
+			{"用户", r.User},
+			{"集群", r.Context},
+			{"命令", r.Command},
+			{"模型", r.Model},
+			{"输入", r.Prompt},
+			{"工具", r.Tool},
+			{"参数", r.Arguments},
+			{"确认", r.Confirmation},
+			{"结果", r.Result},
+		}
+		for _, f := range fields {
+			if f[1] != "" {
+				fmt.Printf("%s: %s\n", f[0], f[1])
+			}
+		}
+		if r.DryRun != "" {
+			fmt.Println("\n预览:")
+			fmt.Println(strings.TrimRight(r.DryRun, "\n"))
+		}
+		fmt.Println("\n响应:")
+		fmt.Println(r.Response)
+		if r.Undoable {
+			fmt.Printf("\n可以用 k8scopilot undo %s 撤销\n", r.ID)
+		}
+	},
+}
+
+var (
+	auditSince time.Duration
+	auditTool  string
+	auditLimit int
+)
+
+func readAudit() ([]utils.AuditRecord, error) {
+	if err := initSession(); err != nil {
+		return nil, err
+	}
+	return utils.ReadAuditLog(auditLog.File())
+}
+
+// clusterContext 返回当前使用的集群，离线分析时为快照
+func clusterContext() string {
+	switch {
+	case snapshotFile != "":
+		return "snapshot:" + snapshotFile
+	case testCluster != nil:
+		return "test"
+	}
+	return utils.CurrentContext(kubeconfig)
+}
+
+// pendingDryRun 是工具执行前的预览，例如模型生成的清单（已脱敏），由 recordAudit 写入下一条审计记录
+var pendingDryRun string
+
+// recordAudit 补全用户和集群信息后写入审计日志，写入失败只打印提示
+func recordAudit(r utils.AuditRecord) utils.AuditRecord {
+	if r.DryRun == "" {
+		r.DryRun = pendingDryRun
+	}
+	pendingDryRun = ""
+	if err := initSession(); err != nil {
+		fmt.Println("写入审计日志失败:", err)
+		return r
+	}
+	if u, err := user.Current(); err == nil {
+		r.User = u.Username
+	}
+	r.Context = clusterContext()
+	// 变更前已由 saveUndo 保存的撤销信息：成功时确认，ID 与审计记录相同；没有成功时删除
+	if pendingUndo != nil && pendingUndo.ActionID != "" {
+		if r.Result == utils.AuditSucceeded {
+			r.ID = pendingUndo.ActionID
+			pendingUndo.Pending = false
+			if err := undoStore.Save(pendingUndo); err != nil {
+				fmt.Println("保存撤销信息失败:", err)
+			}
+			r.Undoable = true
+			sessionUndo = append(sessionUndo, r.ID)
+		} else if err := undoStore.Remove(pendingUndo.ActionID); err != nil {
+			fmt.Println("删除撤销信息失败:", err)
+		}
+	}
+	pendingUndo = nil
+	r, err := auditLog.Record(context.TODO(), r)
+	if err != nil {
+		fmt.Println("写入审计日志失败:", err)
+	}
+	return r
+}
+
+// 根据执行结果设置审计记录的 Result 和 Response
+func auditOutcome(r *utils.AuditRecord, response string, err error) {
+	switch {
+	case err == nil:
+		r.Result, r.Response = utils.AuditSucceeded, response
+	case isForbidden(err):
+		r.Result, r.Response = utils.AuditForbidden, err.Error()
+	case isPolicyDenied(err):
+		r.Result, r.Response = utils.AuditDenied, err.Error()
+	default:
+		r.Result, r.Response = utils.AuditFailed, err.Error()
+	}
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "..."
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.AddCommand(auditListCmd, auditShowCmd)
+	auditListCmd.Flags().DurationVar(&auditSince, "since", 0, "only include actions newer than this duration, e.g. 24h")
+	auditListCmd.Flags().StringVar(&auditTool, "tool", "", "only include actions of this tool")
+	auditListCmd.Flags().IntVar(&auditLimit, "limit", 50, "show at most this many of the newest records (0 for all)")
+}
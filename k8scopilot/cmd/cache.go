@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd 管理本地的模型回复缓存
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "管理模型回复缓存",
+}
+
+var cacheStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "查看缓存条目数、占用空间和命中率",
+	Run: func(cmd *cobra.Command, args []string) {
+		c := utils.NewResponseCache(appConfig.Cache, appConfig.DataPath())
+		if c == nil {
+			fmt.Println("缓存已在配置文件中关闭")
+			return
+		}
+		stats, err := c.Stats()
+		if err != nil {
+			fmt.Println("读取缓存失败:", err)
+			return
+		}
+		fmt.Printf("条目: %d (已过期 %d)\n", stats.Entries, stats.Expired)
+		fmt.Printf("占用: %.1f KiB\n", float64(stats.Bytes)/1024)
+		if total := stats.Hits + stats.Misses; total > 0 {
+			fmt.Printf("命中: %d/%d (%.0f%%)\n", stats.Hits, total, float64(stats.Hits)*100/float64(total))
+		}
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "清空缓存",
+	Run: func(cmd *cobra.Command, args []string) {
+		c := utils.NewResponseCache(appConfig.Cache, appConfig.DataPath())
+		if c == nil {
+			fmt.Println("缓存已在配置文件中关闭")
+			return
+		}
+		removed, err := c.Clear(cacheExpiredOnly)
+		if err != nil {
+			fmt.Println("清理缓存失败:", err)
+			return
+		}
+		fmt.Printf("已删除 %d 条缓存\n", removed)
+	},
+}
+
+var cacheExpiredOnly bool
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cacheStatsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	cacheClearCmd.Flags().BoolVar(&cacheExpiredOnly, "expired", false, "only remove expired entries")
+}
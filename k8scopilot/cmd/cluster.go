@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+)
+
+var snapshotFile string
+
+// testCluster 不为空时代替真实集群，只由测试设置
+var testCluster *utils.ClientGo
+
+var (
+	fakeClusterOnce sync.Once
+	fakeCluster     *utils.ClientGo
+	fakeClusterErr  error
+)
+
+// newClientGo 创建集群客户端
+// 指定 --from-snapshot 时使用快照构造的只读副本，整个进程共用一份，写操作会被拒绝
+func newClientGo() (*utils.ClientGo, error) {
+	if testCluster != nil {
+		return testCluster, nil
+	}
+	if snapshotFile == "" {
+		return utils.NewClientGo(kubeconfig)
+	}
+	fakeClusterOnce.Do(func() {
+		objects, logs, meta, err := utils.ReadSnapshot(snapshotFile)
+		if err != nil {
+			fakeClusterErr = err
+			return
+		}
+		fmt.Printf("📦 使用 %s 创建的快照 %s\n", meta.CreatedAt.Format("2006-01-02 15:04:05"), snapshotFile)
+		fakeCluster = utils.NewReadOnlyClientGo(objects, logs)
+	})
+	return fakeCluster, fakeClusterErr
+}
+
+func init() {
+	analyzeCmd.PersistentFlags().StringVar(&snapshotFile, "from-snapshot", "", "analyze a snapshot archive created by 'k8scopilot snapshot' instead of the live cluster")
+}
@@ -15,11 +15,13 @@ import (
 	"github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/restmapper"
+	"sigs.k8s.io/yaml"
 )
 
 // deepseekCmd represents the deepseek command
@@ -42,23 +44,32 @@ func startChat() {
 	fmt.Println("我是 K8s Copilot， 请问有什么可以帮助你？")
 	for {
 		fmt.Print("> ")
-		if scanner.Scan() {
-			input := scanner.Text()
-			if input == "exit" {
-				fmt.Println("再见！")
-				break
-			}
-			if input == "" {
-				continue
-			}
-			response := processInput(input)
-			fmt.Println(response)
+		// 输入结束（例如通过管道输入）时退出
+		if !scanner.Scan() {
+			printRedactionReport()
+			break
 		}
+		input := scanner.Text()
+		if input == "exit" {
+			printRedactionReport()
+			fmt.Println("再见！")
+			break
+		}
+		if input == "" {
+			continue
+		}
+		// undo [action-id] 撤销本次对话中最近一次（或指定的）变更
+		if input == "undo" || strings.HasPrefix(input, "undo ") {
+			fmt.Println(undoLast(strings.TrimSpace(strings.TrimPrefix(input, "undo"))))
+			continue
+		}
+		response := processInput(input)
+		fmt.Println(response)
 	}
 }
 
 func processInput(input string) string {
-	client, err := utils.NewOpenAIClient()
+	client, err := newLLMClient()
 	if err != nil {
 		return err.Error()
 	}
@@ -157,15 +168,44 @@ func functionCalling(input string, client *utils.OpenAI) string {
 		Type:     openai.ToolTypeFunction,
 		Function: &f3,
 	}
-	// 调用 t1、t2、t3
+	// 回滚 Deployment
+	f4 := openai.FunctionDefinition{
+		Name:        "rollbackDeployment",
+		Description: "把 Deployment 回滚到之前的版本，效果同 kubectl rollout undo",
+		Parameters: jsonschema.Definition{
+			Type: jsonschema.Object,
+			Properties: map[string]jsonschema.Definition{
+				"namespace": {
+					Type:        jsonschema.String,
+					Description: "Kubernetes 命名空间",
+				},
+				"name": {
+					Type:        jsonschema.String,
+					Description: "Deployment 名称",
+				},
+				"revision": {
+					Type:        jsonschema.Integer,
+					Description: "要回滚到的版本号，不填表示上一个版本",
+				},
+			},
+			Required: []string{"namespace", "name"},
+		},
+	}
+	t4 := openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f4,
+	}
+	// 调用 t1、t2、t3、t4
 	dialogue := []openai.ChatCompletionMessage{
 		{Role: openai.ChatMessageRoleUser, Content: input},
 	}
-	resp, err := client.Client.CreateChatCompletion(context.TODO(),
+	// 当前身份无权使用的工具不提供给模型
+	tools := availableTools([]openai.Tool{t1, t2, t3, t4})
+	resp, err := client.ChatCompletion(utils.WithOperation(context.TODO(), "functionCalling"),
 		openai.ChatCompletionRequest{
 			Model:    openai.GPT4o,
 			Messages: dialogue,
-			Tools:    []openai.Tool{t1, t2, t3},
+			Tools:    tools,
 		},
 	)
 	if err != nil {
@@ -183,12 +223,58 @@ func functionCalling(input string, client *utils.OpenAI) string {
 	}
 	// fmt.Sprintf("OpenAI 希望能请求函数 %s，参数 %s", msg.ToolCalls[0].Function.Name, msg.ToolCalls[0].Function.Arguments)
 	result, err := callFunction(client, msg.ToolCalls[0].Function.Name, msg.ToolCalls[0].Function.Arguments)
+	record := utils.AuditRecord{
+		Command:      "ask deepseek",
+		Prompt:       input,
+		Tool:         msg.ToolCalls[0].Function.Name,
+		Arguments:    msg.ToolCalls[0].Function.Arguments,
+		Confirmation: utils.ConfirmationAuto,
+		Model:        openai.GPT4o,
+	}
+	auditOutcome(&record, result, err)
+	recordAudit(record)
+	// 权限不足或被策略拒绝时把结果交回模型，由它向用户解释原因
+	if isForbidden(err) || isPolicyDenied(err) {
+		operation := "explainForbidden"
+		if isPolicyDenied(err) {
+			operation = "explainDenied"
+		}
+		dialogue = append(dialogue, openai.ChatCompletionMessage{
+			Role:       openai.ChatMessageRoleTool,
+			Content:    err.Error(),
+			ToolCallID: msg.ToolCalls[0].ID,
+		})
+		resp, respErr := client.ChatCompletion(utils.WithOperation(context.TODO(), operation),
+			openai.ChatCompletionRequest{
+				Model:    openai.GPT4o,
+				Messages: dialogue,
+				Tools:    tools,
+			},
+		)
+		if respErr != nil || len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+			return err.Error()
+		}
+		return resp.Choices[0].Message.Content
+	}
 	if err != nil {
 		return err.Error()
 	}
 	return result
 }
 
+// 对话工具支持的资源类型
+var toolResources = map[string]schema.GroupVersionResource{
+	"pod":        {Group: "", Version: "v1", Resource: "pods"},
+	"service":    {Group: "", Version: "v1", Resource: "services"},
+	"deployment": {Group: "apps", Version: "v1", Resource: "deployments"},
+	"configmap":  {Group: "", Version: "v1", Resource: "configmaps"},
+	"secret":     {Group: "", Version: "v1", Resource: "secrets"},
+	"satefulset": {Group: "apps", Version: "v1", Resource: "statefulsets"},
+}
+
+var toolResourceTypes = []string{"pod", "service", "deployment", "configmap", "secret", "satefulset"}
+
+// callFunction 执行模型选择的工具，各工具在请求 apiserver 之前检查 RBAC 权限和策略文件
 func callFunction(client *utils.OpenAI, name, arguments string) (string, error) {
 	if name == "generateAndDeployResource" {
 		params := struct {
@@ -220,17 +306,34 @@ func callFunction(client *utils.OpenAI, name, arguments string) (string, error)
 		}
 		return deleteResource(params.Namespace, params.ResourceType, params.ResourceName)
 	}
+	if name == "rollbackDeployment" {
+		params := struct {
+			Namespace string `json:"namespace"`
+			Name      string `json:"name"`
+			Revision  int64  `json:"revision"`
+		}{}
+		if err := json.Unmarshal([]byte(arguments), &params); err != nil {
+			return "", err
+		}
+		return rollbackDeployment(params.Namespace, params.Name, params.Revision)
+	}
 	return "", fmt.Errorf("未找到函数 %s", name)
 }
 
 func generateAndDeployResource(client *utils.OpenAI, userInput string) (string, error) {
-	yamlContent, err := client.SendMessage("你现在是一个K8s 资源生成器，请根据用户的输入生成 K8s YAML， 注意除了 YAML 内容以外不要输出任务内容，不要把YAML内容放在```代码块中", userInput)
+	systemPrompt, err := promptSet.Render(utils.PromptYAMLGenerator, nil)
 	if err != nil {
 		return "", err
 	}
+	yamlContent, err := client.SendMessage(systemPrompt, userInput)
+	if err != nil {
+		return "", err
+	}
+	// 审计记录保存实际生成的清单，解析成功后再按对象遮盖 Secret 的取值
+	pendingDryRun = redactor.Redact(yamlContent)
 	// return yamlContent, nil
 	// TODO: 调用 dynamic client 部署资源
-	clientGo, err := utils.NewClientGo(kubeconfig)
+	clientGo, err := newClientGo()
 	if err != nil {
 		return "", err
 	}
@@ -245,6 +348,8 @@ func generateAndDeployResource(client *utils.OpenAI, userInput string) (string,
 		return "", err
 	}
 
+	pendingDryRun = auditManifest(unstructuredObj)
+
 	// 创建 mapper
 	mapper := restmapper.NewDiscoveryRESTMapper(resources)
 
@@ -258,37 +363,58 @@ func generateAndDeployResource(client *utils.OpenAI, userInput string) (string,
 	if namespace == "" {
 		namespace = "default"
 	}
-	_, err = clientGo.DynamicClient.Resource(mapping.Resource).Namespace(namespace).Create(context.TODO(), unstructuredObj, metav1.CreateOptions{})
-	if err != nil {
+	if err := requireAccess(clientGo, "generateAndDeployResource", gvrAccess("create", mapping.Resource, namespace, "")); err != nil {
 		return "", err
 	}
+	var created *unstructured.Unstructured
+	create := func(dryRun []string) (err error) {
+		created, err = clientGo.DynamicClient.Resource(mapping.Resource).Namespace(namespace).Create(context.TODO(), unstructuredObj, metav1.CreateOptions{DryRun: dryRun})
+		return err
+	}
+	req := withObject(newPolicyRequest("generateAndDeployResource", "create", mapping.Resource, namespace, unstructuredObj.GetName()), unstructuredObj.Object)
+	if err := enforcePolicy(clientGo, req, create); err != nil {
+		return "", err
+	}
+	captureUndo(utils.UndoCreate, mapping.Resource, namespace, unstructuredObj.GetName(), nil)
+	if err := saveUndo(); err != nil {
+		return "", err
+	}
+	if err := create(nil); err != nil {
+		return "", err
+	}
+	// 使用 generateName 时创建后才知道对象的名字，由 recordAudit 保存
+	pendingUndo.Name = created.GetName()
 	return fmt.Sprintf("资源 %s 创建成功", unstructuredObj.GetName()), nil
 }
 
+// auditManifest 返回写入审计记录的清单，Secret 只保留 key，其余内容按脱敏规则遮盖
+func auditManifest(obj *unstructured.Unstructured) string {
+	copied := obj.DeepCopy()
+	utils.RedactSecretObject(copied)
+	out, err := yaml.Marshal(copied.Object)
+	if err != nil {
+		return ""
+	}
+	return redactor.Redact(string(out))
+}
+
 func queryResource(namespace, resourceType string) (string, error) {
-	clientGo, err := utils.NewClientGo(kubeconfig)
+	clientGo, err := newClientGo()
 	if err != nil {
 		return "", err
 	}
 	resourceType = strings.ToLower(resourceType)
 
-	var gvr schema.GroupVersionResource
-	switch resourceType {
-	case "pod":
-		gvr = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
-	case "service":
-		gvr = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "services"}
-	case "deployment":
-		gvr = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
-	case "configmap":
-		gvr = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "configmaps"}
-	case "satefulset":
-		gvr = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}
-	case "secret":
-		gvr = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "secrets"}
-	default:
+	gvr, ok := toolResources[resourceType]
+	if !ok {
 		return "", fmt.Errorf("不支持的资源类型: %s", resourceType)
 	}
+	if err := requireAccess(clientGo, "queryResource", gvrAccess("list", gvr, namespace, "")); err != nil {
+		return "", err
+	}
+	if err := enforcePolicy(clientGo, newPolicyRequest("queryResource", "list", gvr, namespace, ""), nil); err != nil {
+		return "", err
+	}
 	// 通过 dynamicClient 获取资源
 	resourceList, err := clientGo.DynamicClient.Resource(gvr).Namespace(namespace).List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
@@ -297,42 +423,58 @@ func queryResource(namespace, resourceType string) (string, error) {
 
 	result := ""
 	for _, item := range resourceList.Items {
+		// Secret 只返回 key，value 不离开集群
+		if resourceType == "secret" {
+			result += fmt.Sprintf("资源名称: %s, 资源类型: %s, keys: %s\n", item.GetName(), resourceType, strings.Join(utils.SecretKeys(&item), ","))
+			continue
+		}
 		result += fmt.Sprintf("资源名称: %s, 资源类型: %s\n", item.GetName(), resourceType)
 	}
 	return result, nil
 }
 func deleteResource(namespace, resourceType, resourceName string) (string, error) {
-	clientGo, err := utils.NewClientGo(kubeconfig)
+	clientGo, err := newClientGo()
 	if err != nil {
 		return "", err
 	}
 	resourceType = strings.ToLower(resourceType)
 
-	var gvr schema.GroupVersionResource
-	switch resourceType {
-	case "pod":
-		gvr = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "pods"}
-	case "service":
-		gvr = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "services"}
-	case "deployment":
-		gvr = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
-	case "configmap":
-		gvr = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "configmaps"}
-	case "secret":
-		gvr = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "secrets"}
-	case "satefulset":
-		gvr = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}
-	default:
+	gvr, ok := toolResources[resourceType]
+	if !ok {
 		return "", fmt.Errorf("不支持的资源类型: %s (当前支持: pod/service/deployment/configmap/secret/satefulset)", resourceType)
 	}
 	// 处理默认命名空间
 	if namespace == "" {
 		namespace = "default"
 	}
+	// 删除前需要读取对象用于 undo，因此同时检查 get 权限
+	if err := requireAccess(clientGo, "deleteResource", gvrAccess("delete", gvr, namespace, resourceName), gvrAccess("get", gvr, namespace, resourceName)); err != nil {
+		return "", err
+	}
+	del := func(dryRun []string) error {
+		return clientGo.DynamicClient.Resource(gvr).Namespace(namespace).Delete(context.TODO(), resourceName, metav1.DeleteOptions{DryRun: dryRun})
+	}
+	if err := enforcePolicy(clientGo, newPolicyRequest("deleteResource", "delete", gvr, namespace, resourceName), del); err != nil {
+		return "", err
+	}
 
-	// 执行删除操作
-	err = clientGo.DynamicClient.Resource(gvr).Namespace(namespace).Delete(context.TODO(), resourceName, metav1.DeleteOptions{})
+	// 删除前保存对象，用于 undo；无法保存时不删除，否则删除后无法撤销
+	obj, err := clientGo.DynamicClient.Resource(gvr).Namespace(namespace).Get(context.TODO(), resourceName, metav1.GetOptions{})
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", fmt.Errorf("%s %s 在命名空间 %s 中不存在",
+				resourceType, resourceName, namespace)
+		}
+		return "", fmt.Errorf("删除前读取对象失败，无法保存撤销快照，已取消删除: %v", err)
+	}
+	captureUndo(utils.UndoDelete, gvr, namespace, resourceName, obj)
+	pendingDryRun = fmt.Sprintf("删除 %s %s/%s（resourceVersion %s）", resourceType, namespace, resourceName, obj.GetResourceVersion())
+
+	if err := saveUndo(); err != nil {
+		return "", err
+	}
+	// 执行删除操作
+	if err := del(nil); err != nil {
 		// 通过错误信息内容判断
 		if strings.Contains(err.Error(), "not found") {
 			return "", fmt.Errorf("%s %s 在命名空间 %s 中不存在",
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/internal/testutil"
+	"github.com/sashabaranov/go-openai"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var podsResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+func TestFunctionCallingQuery(t *testing.T) {
+	server := setupOffline(t, "cluster", testutil.MockResponse{
+		ToolCalls: []testutil.MockToolCall{{Name: "queryResource", Arguments: `{"namespace":"shop","resource_type":"pod"}`}},
+	})
+	client, err := newLLMClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := functionCalling("shop 里有哪些 Pod", client)
+	if !strings.Contains(result, "资源名称: web-abc-1, 资源类型: pod") {
+		t.Errorf("查询结果不对: %q", result)
+	}
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("期望 1 次模型请求，实际 %d 次", len(requests))
+	}
+	var tools []string
+	for _, tool := range requests[0].Tools {
+		tools = append(tools, tool.Function.Name)
+	}
+	if got := strings.Join(tools, ","); got != "generateAndDeployResource,queryResource,deleteResource,rollbackDeployment" {
+		t.Errorf("提供给模型的工具不对: %s", got)
+	}
+}
+
+// 权限不足时把工具调用和拒绝原因交回模型，由它向用户解释
+func TestFunctionCallingReplaysForbidden(t *testing.T) {
+	server := setupOffline(t, "restricted",
+		testutil.MockResponse{
+			ToolCalls: []testutil.MockToolCall{{Name: "deleteResource", Arguments: `{"namespace":"shop","resource_type":"pod","resource_name":"web-1"}`}},
+		},
+		testutil.MockResponse{Content: "当前身份没有删除 Pod 的权限，请联系管理员"},
+	)
+	// 按 --namespace 判断向模型提供哪些工具
+	defer func(ns string) { namespace = ns }(namespace)
+	namespace = "shop"
+	client, err := newLLMClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := functionCalling("删除 shop 中的 web-1", client)
+	if result != "当前身份没有删除 Pod 的权限，请联系管理员" {
+		t.Errorf("应返回模型的解释: %q", result)
+	}
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("期望 2 次模型请求，实际 %d 次", len(requests))
+	}
+	// 没有任何删除权限时不提供 deleteResource
+	for _, tool := range requests[0].Tools {
+		if tool.Function.Name == "deleteResource" {
+			t.Error("不应向模型提供 deleteResource")
+		}
+	}
+	replay := requests[1].Messages
+	if len(replay) != 3 {
+		t.Fatalf("回放的对话应包含用户输入、工具调用和工具结果，实际 %d 条", len(replay))
+	}
+	call, toolResult := replay[1], replay[2]
+	if len(call.ToolCalls) != 1 || call.ToolCalls[0].Function.Name != "deleteResource" {
+		t.Errorf("第二条消息应为原来的工具调用: %+v", call)
+	}
+	if toolResult.Role != openai.ChatMessageRoleTool || toolResult.ToolCallID != call.ToolCalls[0].ID {
+		t.Errorf("工具结果应对应原来的调用: %+v", toolResult)
+	}
+	if !strings.Contains(toolResult.Content, "delete") {
+		t.Errorf("工具结果应说明缺少的权限: %q", toolResult.Content)
+	}
+
+	clientGo, err := newClientGo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientGo.DynamicClient.Resource(podsResource).Namespace("shop").Get(context.TODO(), "web-1", metav1.GetOptions{}); err != nil {
+		t.Errorf("被拒绝的删除不应执行: %v", err)
+	}
+}
+
+func TestCallFunction(t *testing.T) {
+	setupOffline(t, "cluster")
+	client, err := newLLMClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := callFunction(client, "queryResource", `{"namespace":"shop","resource_type":"configmap"}`)
+	if err != nil || result != "资源名称: app-config, 资源类型: configmap\n" {
+		t.Errorf("queryResource: %q, %v", result, err)
+	}
+	// Secret 只返回 key
+	result, err = callFunction(client, "queryResource", `{"namespace":"shop","resource_type":"secret"}`)
+	if err != nil || result != "资源名称: db, 资源类型: secret, keys: password\n" {
+		t.Errorf("queryResource secret: %q, %v", result, err)
+	}
+
+	result, err = callFunction(client, "deleteResource", `{"namespace":"shop","resource_type":"pod","resource_name":"web-abc-1"}`)
+	if err != nil || result != "成功删除 pod/web-abc-1 于命名空间 shop" {
+		t.Fatalf("deleteResource: %q, %v", result, err)
+	}
+	clientGo, err := newClientGo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientGo.DynamicClient.Resource(podsResource).Namespace("shop").Get(context.TODO(), "web-abc-1", metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Pod 应已被删除: %v", err)
+	}
+	if _, err := callFunction(client, "deleteResource", `{"namespace":"shop","resource_type":"pod","resource_name":"web-abc-1"}`); err == nil || !strings.Contains(err.Error(), "不存在") {
+		t.Errorf("删除不存在的对象应报错: %v", err)
+	}
+
+	if _, err := callFunction(client, "scaleDeployment", `{}`); err == nil {
+		t.Error("未知的函数应报错")
+	}
+	if _, err := callFunction(client, "queryResource", `{"namespace":`); err == nil {
+		t.Error("参数不是合法的 JSON 时应报错")
+	}
+}
@@ -8,13 +8,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
-	"github.com/sashabaranov/go-openai"
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
 )
 
 // eventCmd represents the event command
@@ -25,7 +26,7 @@ var eventCmd = &cobra.Command{
 	Short: "交互式分析集群异常事件",
 	Run: func(cmd *cobra.Command, args []string) {
 		// 获取问题 Pod 列表
-		pods, err := getProblemPods()
+		pods, err := getProblemPods(true)
 		if err != nil {
 			fmt.Println("获取集群状态失败:", err)
 			return
@@ -36,108 +37,230 @@ var eventCmd = &cobra.Command{
 			return
 		}
 
-		// 交互选择
-		selectedPod, err := selectPod(pods)
+		notifier, err := utils.NewNotifier(appConfig.Notify)
 		if err != nil {
-			fmt.Println("选择无效")
+			fmt.Println("初始化通知渠道失败:", err)
 			return
 		}
+		defer func() {
+			if err := notifier.Flush(context.TODO()); err != nil {
+				fmt.Println("发送通知失败:", err)
+			}
+		}()
 
-		// 执行分析
-		result, err := analyzeSinglePod(selectedPod)
-		if err != nil {
-			fmt.Println("分析失败:", err)
-			return
+		// --all 时依次分析全部 Pod，否则交互选择
+		selected := pods
+		if !analyzeAll {
+			selectedPod, err := selectPod(pods)
+			if err != nil {
+				fmt.Println("选择无效")
+				return
+			}
+			selected = []PodIssue{selectedPod}
 		}
 
-		fmt.Println("\n分析结果：")
-		fmt.Println(result)
+		for _, pod := range selected {
+			fmt.Printf("\n%s 事件时间线：\n", pod)
+			for _, line := range timelineLines(pod.Timeline) {
+				fmt.Println("  " + line)
+			}
+
+			// 执行分析
+			result, err := analyzeSinglePod(pod)
+			if err != nil {
+				fmt.Printf("分析 %s 失败: %v\n", pod, err)
+				continue
+			}
+
+			fmt.Printf("\n%s 分析结果：\n", pod)
+			fmt.Println(result)
+
+			sendDiagnosis(notifier, utils.Diagnosis{
+				Kind:          pod.Workload.Kind,
+				Namespace:     pod.Namespace,
+				Name:          pod.Workload.Name,
+				Severity:      podSeverity(pod),
+				Events:        pod.Events,
+				Result:        result,
+				PromptVersion: promptSet.Version(utils.PromptPodAnalysis),
+			})
+		}
+		printRedactionReport()
 	},
 }
 
+var analyzeAll bool
+
 // 新增结构体存储 Pod 信息
+// 同一工作负载下的多个异常 Pod 合并为一条，Name 为用于取证的代表 Pod
 type PodIssue struct {
 	Name      string
 	Namespace string
-	Events    []string
-	Logs      string
+	// 代表 Pod 的事件内容，已去重
+	Events []string
+	// 该工作负载下所有异常 Pod 折叠后的事件，按时间排序
+	Timeline []utils.Event
+	Logs     string
+	// 容器配置和状态摘要
+	Spec string
+	// 所属顶层工作负载，独立 Pod 时 Kind 为 Pod
+	Workload workloadRef
+	// 该工作负载下出现异常的全部 Pod
+	Pods []string
 }
 
-// 步骤1：获取问题 Pod 列表
-func getProblemPods() ([]PodIssue, error) {
-	clientGo, err := utils.NewClientGo(kubeconfig)
+// 展示用的名称，例如 shop/Deployment/web (3 个 Pod)
+func (p PodIssue) String() string {
+	if p.Workload.Kind == "" || p.Workload.Kind == "Pod" {
+		return p.Namespace + "/" + p.Name
+	}
+	return fmt.Sprintf("%s/%s (%d 个 Pod)", p.Namespace, p.Workload, len(p.Pods))
+}
+
+// 步骤1：获取问题 Pod 列表，按工作负载聚合
+// withLogs 为 false 时不拉取代表 Pod 的日志，用于不需要日志的汇总场景
+func getProblemPods(withLogs bool) ([]PodIssue, error) {
+	clientGo, err := newClientGo()
 	if err != nil {
 		return nil, err
 	}
 
-	var podIssues []PodIssue
-
-	events, err := clientGo.ClientSet.CoreV1().Events("").List(context.TODO(), metav1.ListOptions{
-		FieldSelector: "type=Warning",
-	})
+	events, err := utils.ListEvents(context.TODO(), clientGo, scope.Namespace, true)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, event := range events.Items {
-		if event.InvolvedObject.Kind != "Pod" {
+	// 先按 Pod 收集折叠后的事件，保持时间顺序
+	var podKeys []string
+	podEvents := map[string][]utils.Event{}
+	for _, event := range events {
+		if event.Kind != "Pod" || !scope.includesNamespace(event.Namespace) || !scope.recent(event) {
 			continue
 		}
+		key := event.Namespace + "/" + event.Name
+		if _, ok := podEvents[key]; !ok {
+			podKeys = append(podKeys, key)
+		}
+		podEvents[key] = append(podEvents[key], event)
+	}
 
-		podName := event.InvolvedObject.Name
-		namespace := event.InvolvedObject.Namespace
-
-		// 去重处理
-		exists := false
-		for _, p := range podIssues {
-			if p.Name == podName && p.Namespace == namespace {
-				exists = true
-				break
+	// 再按所属工作负载分组
+	type group struct {
+		issue PodIssue
+		rep   *corev1.Pod
+	}
+	resolver := newOwnerResolver(clientGo)
+	var order []workloadRef
+	groups := map[workloadRef]*group{}
+	for _, key := range podKeys {
+		namespace, podName, _ := strings.Cut(key, "/")
+		pod, err := clientGo.ClientSet.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
+		if err != nil {
+			// Pod 已被删除时仍保留事件，单独作为一条；指定了 --selector 时无法判断标签，跳过
+			if scope.Selector != nil {
+				continue
 			}
-		}
-		if exists {
+			pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: podName, Namespace: namespace}}
+		} else if !scope.includesPod(pod) {
 			continue
 		}
-
-		// 获取基础信息
-		podIssue := PodIssue{
-			Name:      podName,
-			Namespace: namespace,
-			Events:    []string{event.Message},
+		workload := resolver.resolve(pod)
+		g, ok := groups[workload]
+		if !ok {
+			g = &group{issue: PodIssue{Namespace: namespace, Workload: workload}}
+			groups[workload] = g
+			order = append(order, workload)
+		}
+		g.issue.Pods = append(g.issue.Pods, podName)
+		g.issue.Timeline = append(g.issue.Timeline, podEvents[key]...)
+		// 事件最多、其次重启次数最多的 Pod 作为代表
+		messages := eventMessages(podEvents[key])
+		if g.rep == nil || len(messages) > len(g.issue.Events) ||
+			(len(messages) == len(g.issue.Events) && podRestarts(pod) > podRestarts(g.rep)) {
+			g.rep = pod
+			g.issue.Name = podName
+			g.issue.Events = messages
 		}
+	}
 
-		// 获取日志（保留核心错误信息）
-		if pod, err := clientGo.ClientSet.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{}); err == nil {
-			if pod.Status.Phase == corev1.PodRunning {
-				if logs, err := getLast100LinesLog(namespace, podName); err == nil {
-					podIssue.Logs = logs
-				}
+	// 只拉取代表 Pod 的配置和日志（分析前会按预算精简）
+	podIssues := make([]PodIssue, 0, len(order))
+	for _, workload := range order {
+		g := groups[workload]
+		utils.SortEvents(g.issue.Timeline)
+		if len(g.rep.Spec.Containers) > 0 {
+			g.issue.Spec = podSpecSummary(g.rep)
+		}
+		if withLogs && g.rep.Status.Phase == corev1.PodRunning {
+			if logs, err := getPodLogs(g.rep.Namespace, g.rep.Name); err == nil {
+				g.issue.Logs = logs
 			}
 		}
-
-		podIssues = append(podIssues, podIssue)
+		podIssues = append(podIssues, g.issue)
 	}
 
 	return podIssues, nil
 }
 
+// eventMessages 返回去重后的事件内容
+func eventMessages(events []utils.Event) []string {
+	var messages []string
+	for _, e := range events {
+		if !slices.Contains(messages, e.Message) {
+			messages = append(messages, e.Message)
+		}
+	}
+	return messages
+}
+
+// timelineLines 把事件时间线转换成展示和提示词中使用的文本
+func timelineLines(events []utils.Event) []string {
+	lines := make([]string, 0, len(events))
+	for _, e := range events {
+		lines = append(lines, e.String())
+	}
+	return lines
+}
+
+// 根据事件内容粗略判断严重级别
+func podSeverity(pod PodIssue) string {
+	for _, e := range pod.Events {
+		for _, keyword := range []string{"Back-off restarting", "CrashLoopBackOff", "OOMKilled", "Evicted"} {
+			if strings.Contains(e, keyword) {
+				return utils.SeverityCritical
+			}
+		}
+	}
+	return utils.SeverityWarning
+}
+
 func ptr[T any](v T) *T {
 	return &v
 }
 
-// 获取日志最后100行（控制长度）
-func getLast100LinesLog(namespace, podName string) (string, error) {
-	clientGo, err := utils.NewClientGo(kubeconfig)
+// 单次最多拉取的日志量，真正发送给模型前还会再按 token 预算精简
+const (
+	maxLogLines = 2000
+	maxLogBytes = 1 << 20
+)
+
+func podLogOptions() *corev1.PodLogOptions {
+	return &corev1.PodLogOptions{
+		TailLines:  ptr(int64(maxLogLines)),
+		LimitBytes: ptr(int64(maxLogBytes)),
+	}
+}
+
+// 获取日志末尾部分（控制长度）
+func getPodLogs(namespace, podName string) (string, error) {
+	clientGo, err := newClientGo()
 	if err != nil {
 		return "", err
 	}
 
-	logOptions := &corev1.PodLogOptions{
-		TailLines: ptr(int64(100)), // 仅获取最后100行
-	}
+	logOptions := podLogOptions()
 
-	req := clientGo.ClientSet.CoreV1().Pods(namespace).GetLogs(podName, logOptions)
-	podLogs, err := req.Stream(context.TODO())
+	podLogs, err := clientGo.PodLogs(context.TODO(), namespace, podName, logOptions)
 	if err != nil {
 		return "", err
 	}
@@ -155,10 +278,9 @@ func getLast100LinesLog(namespace, podName string) (string, error) {
 func selectPod(pods []PodIssue) (PodIssue, error) {
 	fmt.Println("发现异常 Pod 列表：")
 	for i, pod := range pods {
-		fmt.Printf("[%d] %s/%s - 事件: %s\n",
+		fmt.Printf("[%d] %s - 事件: %s\n",
 			i+1,
-			pod.Namespace,
-			pod.Name,
+			pod,
 			strings.Join(pod.Events, ", "),
 		)
 	}
@@ -176,122 +298,148 @@ func selectPod(pods []PodIssue) (PodIssue, error) {
 
 // 步骤3：发送单个 Pod 分析请求
 func analyzeSinglePod(pod PodIssue) (string, error) {
-	client, err := utils.NewOpenAIClient()
-	if err != nil {
-		return "", err
+	// 构造精炼提示词：先算出模板固定部分的长度，再把剩余预算分给事件、日志和配置
+	model := analysisModel
+	budget := utils.NewPromptBudget(model, appConfig.Budget)
+	data := utils.PodAnalysisData{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
 	}
-
-	// 构造精炼提示词
-	prompt := fmt.Sprintf(`请分析以下 Kubernetes Pod 问题：
-Pod: %s/%s
-
-事件列表:
-%s
-
-相关日志（最后100行）:
-%s
-
-请按以下格式响应：
-1. 问题诊断（简明扼要）
-2. 解决步骤（带具体命令）
-3. 相关参考链接`,
-		pod.Namespace, pod.Name,
-		strings.Join(pod.Events, "\n- "),
-		pod.Logs,
-	)
-
-	messages := []openai.ChatCompletionMessage{
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: "你是一个 Kubernetes 专家，请用简洁的技术语言分析问题",
-		},
-		{
-			Role:    openai.ChatMessageRoleUser,
-			Content: prompt,
-		},
+	if pod.Workload.Kind != "" && pod.Workload.Kind != "Pod" {
+		data.Workload = pod.Workload.String()
+		data.Affected = len(pod.Pods)
+		data.AffectedPods = pod.Pods
+		if len(data.AffectedPods) > maxAffectedPods {
+			data.AffectedPods = data.AffectedPods[:maxAffectedPods]
+		}
 	}
-
-	resp, err := client.Client.CreateChatCompletion(
-		context.TODO(),
-		openai.ChatCompletionRequest{
-			Model:     openai.GPT4o,
-			Messages:  messages,
-			MaxTokens: 500, // 限制响应长度
-		},
-	)
+	skeleton, err := promptSet.Render(utils.PromptPodAnalysis, data)
 	if err != nil {
 		return "", err
 	}
-
-	if len(resp.Choices) == 0 {
-		return "", errors.New("未收到有效响应")
+	// 需求量包含每行的分隔开销，和 limitEvents、CondenseLogs 的计算方式一致
+	timeline := timelineLines(pod.Timeline)
+	eventsNeed := eventsTokens(model, timeline)
+	related := relatedTimeline(pod)
+	hits := retrieveRunbooks(strings.Join(pod.Events, "\n") + "\n" + utils.TruncateToTokens(model, pod.Logs, runbookQueryLogTokens, true))
+	past := similarIncidents(pod.Workload.Kind, pod.Namespace, pod.Workload.Name, pod.Events)
+	pastLines := pastIncidentLines(model, past)
+	logsNeed := utils.CountTokens(model, pod.Logs) + strings.Count(pod.Logs, "\n") + 1
+	alloc := budget.Allocate(utils.CountTokens(model, skeleton), []utils.BudgetSection{
+		{Name: "events", Need: eventsNeed, Weight: 1},
+		{Name: "timeline", Need: eventsTokens(model, related), Weight: 1},
+		{Name: "logs", Need: logsNeed, Weight: 3},
+		{Name: "spec", Need: utils.CountTokens(model, pod.Spec), Weight: 1},
+		{Name: "runbooks", Need: eventsTokens(model, runbookExcerpts(model, hits)), Weight: 2},
+		{Name: "incidents", Need: eventsTokens(model, pastLines), Weight: 2},
+	})
+	// 预算不足时保留最新的事件
+	data.Events = reverse(limitEvents(model, reverse(timeline), alloc["events"]))
+	data.Timeline = reverse(limitEvents(model, reverse(related), alloc["timeline"]))
+	data.Logs = strings.TrimRight(utils.CondenseLogs(model, pod.Logs, alloc["logs"]), "\n")
+	data.Spec = strings.TrimRight(utils.TruncateToTokens(model, pod.Spec, alloc["spec"], false), "\n")
+	data.Runbooks, hits = fitRunbooks(model, hits, alloc["runbooks"])
+	data.PastIncidents = limitEvents(model, pastLines, alloc["incidents"])
+
+	result, err := analyzeWithLLM("analyzeSinglePod", utils.PromptPodAnalysis, data, budget.ResponseTokens)
+	if err != nil {
+		return "", err
 	}
-
-	return resp.Choices[0].Message.Content, nil
+	return withPastIncidents(citeRunbooks(result, hits), past), nil
 }
 
-func getPodEventAndLogs() (map[string][]string, error) {
-	clientGo, err := utils.NewClientGo(kubeconfig)
+// relatedTimeline 返回代表 Pod 的工作负载、配置和节点的变化，Pod 自身的事件已在事件时间线中
+// 读取失败时返回空，不影响分析
+func relatedTimeline(pod PodIssue) []string {
+	clientGo, err := newClientGo()
 	if err != nil {
-		return nil, err
+		return nil
 	}
-	result := make(map[string][]string)
-
-	// 1. 查询所有命名空间的 Warning 事件
-	events, err := clientGo.ClientSet.CoreV1().Events("").List(context.TODO(), metav1.ListOptions{
-		FieldSelector: "type=Warning",
-	})
+	p, err := clientGo.ClientSet.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
 	if err != nil {
-		return nil, err
+		return nil
 	}
+	var lines []string
+	for _, e := range buildIncidentTimeline(clientGo, p, false) {
+		lines = append(lines, e.String())
+	}
+	return lines
+}
 
-	for _, event := range events.Items {
-		if event.InvolvedObject.Kind != "Pod" {
-			continue
-		}
-
-		podName := event.InvolvedObject.Name
-		namespace := event.InvolvedObject.Namespace
-		message := event.Message
+// 检索运维手册时使用的日志末尾长度
+const runbookQueryLogTokens = 200
 
-		// 2. 初始化日志条目
-		entries := []string{
-			fmt.Sprintf("Event: %s", message),
-			fmt.Sprintf("Namespace: %s", namespace),
-		}
+// 提示词中最多列出的受影响 Pod 数量
+const maxAffectedPods = 10
 
-		// 3. 获取 Pod 状态
-		pod, err := clientGo.ClientSet.CoreV1().Pods(namespace).Get(context.TODO(), podName, metav1.GetOptions{})
-		if err != nil {
-			entries = append(entries, fmt.Sprintf("获取Pod状态失败: %v", err))
-			result[podName] = entries
-			continue
-		}
+// 事件列表占用的 token，包含每行的分隔开销
+func eventsTokens(model string, events []string) int {
+	n := 0
+	for _, e := range events {
+		n += utils.CountTokens(model, e) + 2
+	}
+	return n
+}
 
-		// 4. 只有 Running 状态才获取日志
-		if pod.Status.Phase == corev1.PodRunning {
-			logOption := &corev1.PodLogOptions{}
-			req := clientGo.ClientSet.CoreV1().Pods(namespace).GetLogs(podName, logOption)
-			podLogs, err := req.Stream(context.TODO())
-			if err != nil {
-				entries = append(entries, fmt.Sprintf("日志获取失败: %v", err))
-			} else {
-				defer podLogs.Close()
-				buf := new(bytes.Buffer)
-				if _, err := buf.ReadFrom(podLogs); err != nil {
-					entries = append(entries, fmt.Sprintf("日志读取失败: %v", err))
-				} else {
-					entries = append(entries, fmt.Sprintf("日志内容:\n%s", buf.String()))
-				}
-			}
-		} else {
-			entries = append(entries, fmt.Sprintf("Pod状态: %s (无法获取日志)", pod.Status.Phase))
+// 按预算保留事件，超出部分只记录条数
+func limitEvents(model string, events []string, maxTokens int) []string {
+	var kept []string
+	used := 0
+	for i, e := range events {
+		t := utils.CountTokens(model, e) + 2
+		if used+t > maxTokens {
+			return append(kept, fmt.Sprintf("...(另有 %d 条事件被省略)", len(events)-i))
 		}
-
-		result[podName] = entries
+		kept = append(kept, e)
+		used += t
 	}
+	return kept
+}
 
-	return result, nil
+// 提取容器配置和状态中与排障相关的字段，不包含环境变量等可能敏感的内容
+func podSpecSummary(pod *corev1.Pod) string {
+	type containerSummary struct {
+		Name         string                      `json:"name"`
+		Image        string                      `json:"image"`
+		Resources    corev1.ResourceRequirements `json:"resources,omitempty"`
+		Ready        bool                        `json:"ready"`
+		RestartCount int32                       `json:"restartCount"`
+		State        corev1.ContainerState       `json:"state,omitempty"`
+		LastState    corev1.ContainerState       `json:"lastState,omitempty"`
+	}
+	summary := struct {
+		Phase      corev1.PodPhase    `json:"phase"`
+		Reason     string             `json:"reason,omitempty"`
+		Message    string             `json:"message,omitempty"`
+		NodeName   string             `json:"nodeName,omitempty"`
+		Containers []containerSummary `json:"containers"`
+	}{
+		Phase:    pod.Status.Phase,
+		Reason:   pod.Status.Reason,
+		Message:  pod.Status.Message,
+		NodeName: pod.Spec.NodeName,
+	}
+	statuses := map[string]corev1.ContainerStatus{}
+	for _, cs := range pod.Status.ContainerStatuses {
+		statuses[cs.Name] = cs
+	}
+	for _, c := range pod.Spec.Containers {
+		cs := statuses[c.Name]
+		summary.Containers = append(summary.Containers, containerSummary{
+			Name:         c.Name,
+			Image:        c.Image,
+			Resources:    c.Resources,
+			Ready:        cs.Ready,
+			RestartCount: cs.RestartCount,
+			State:        cs.State,
+			LastState:    cs.LastTerminationState,
+		})
+	}
+	out, err := yaml.Marshal(summary)
+	if err != nil {
+		return ""
+	}
+	return string(out)
 }
 
 func init() {
@@ -306,4 +454,5 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// eventCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	eventCmd.Flags().BoolVar(&analyzeAll, "all", false, "analyze all problem pods without prompting")
 }
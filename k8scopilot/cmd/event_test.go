@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/internal/testutil"
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestGetProblemPods(t *testing.T) {
+	setupOffline(t, "cluster")
+
+	pods, err := getProblemPods(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 {
+		t.Fatalf("期望 1 个有问题的工作负载，实际 %d 个: %v", len(pods), pods)
+	}
+	pod := pods[0]
+	if pod.Workload.String() != "Deployment/web" || pod.Name != "web-abc-1" {
+		t.Errorf("工作负载或代表 Pod 不对: %s %s", pod.Workload, pod.Name)
+	}
+	if len(pod.Events) != 1 || !strings.Contains(pod.Events[0], "Back-off restarting") {
+		t.Errorf("事件不对: %v", pod.Events)
+	}
+	if !strings.Contains(pod.Logs, "connection refused") {
+		t.Errorf("没有读取日志: %q", pod.Logs)
+	}
+
+	// 报告不需要日志
+	pods, err = getProblemPods(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pods) != 1 || pods[0].Logs != "" {
+		t.Errorf("withLogs 为 false 时不应读取日志: %+v", pods)
+	}
+}
+
+func TestAnalyzeSinglePod(t *testing.T) {
+	server := setupOffline(t, "cluster", testutil.MockResponse{
+		Match:   "web-abc-1",
+		Content: "1. 问题诊断: ConfigMap app-config 中的 DB_HOST 被改为 db2，连接被拒绝\n2. 解决步骤: kubectl -n shop edit configmap app-config",
+	})
+	pods, err := getProblemPods(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := analyzeSinglePod(pods[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("期望 1 次模型请求，实际 %d 次", len(requests))
+	}
+	prompt := requests[0].Messages[len(requests[0].Messages)-1]
+	if prompt.Role != openai.ChatMessageRoleUser {
+		t.Fatalf("最后一条消息应为用户消息: %s", prompt.Role)
+	}
+	if strings.Contains(prompt.Content, "hunter2") {
+		t.Error("日志中的密码没有脱敏")
+	}
+	assertGolden(t, "analyze_pod.golden", prompt.Content+"\n=== result ===\n"+result+"\n")
+}
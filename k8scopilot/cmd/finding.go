@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+)
+
+// finding 是规则检查直接得出的问题，不依赖模型
+type finding struct {
+	Severity string
+	Message  string
+}
+
+func (f finding) String() string {
+	return fmt.Sprintf("[%s] %s", strings.ToUpper(f.Severity), f.Message)
+}
+
+// 返回最高的严重级别，没有问题时为 info
+func maxSeverity(findings []finding) string {
+	severity := utils.SeverityInfo
+	for _, f := range findings {
+		if utils.SeverityRank(f.Severity) > utils.SeverityRank(severity) {
+			severity = f.Severity
+		}
+	}
+	return severity
+}
+
+func findingStrings(findings []finding) []string {
+	out := make([]string, 0, len(findings))
+	for _, f := range findings {
+		out = append(out, f.String())
+	}
+	return out
+}
+
+// sendDiagnosis 把单个诊断结果保存到事故库并放入本次命令共用的通知批次
+// notifier 由命令创建并在结束时统一 Flush，保证批量和限流生效
+func sendDiagnosis(notifier *utils.Notifier, d utils.Diagnosis) {
+	recordIncident(d)
+	if err := notifier.Notify(context.TODO(), d); err != nil {
+		fmt.Println("发送通知失败:", err)
+	}
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+)
+
+// incidentCmd 浏览分析过的事故，并记录实际的修复方法
+var incidentCmd = &cobra.Command{
+	Use:   "incident",
+	Short: "查看历史分析记录，记录实际有效的修复方法",
+}
+
+var incidentListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出事故，最新的在最后",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !loadIncidents() {
+			return
+		}
+		all, err := incidents.List()
+		if err != nil {
+			fmt.Println("读取事故库失败:", err)
+			return
+		}
+		var filtered []utils.Incident
+		for _, inc := range all {
+			if incidentUnresolved && inc.Resolved() {
+				continue
+			}
+			filtered = append(filtered, inc)
+		}
+		if len(filtered) == 0 {
+			fmt.Println("没有事故记录")
+			return
+		}
+		if incidentLimit > 0 && len(filtered) > incidentLimit {
+			filtered = filtered[len(filtered)-incidentLimit:]
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tOBJECT\tSEVERITY\tRESOLVED\tEVIDENCE")
+		for _, inc := range filtered {
+			resolved := "-"
+			if inc.Resolved() {
+				resolved = "yes"
+			}
+			evidence := ""
+			if len(inc.Evidence) > 0 {
+				evidence = inc.Evidence[0]
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", inc.ID, inc.Time.Format("2006-01-02 15:04:05"), inc.Object(), inc.Severity, resolved, truncateRunes(evidence, 60))
+		}
+		w.Flush()
+	},
+}
+
+var incidentShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "查看一条事故的证据、诊断和修复方法，id 可以只写前缀",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !loadIncidents() {
+			return
+		}
+		inc, err := incidents.Find(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fields := [][2]string{
+			{"ID", inc.ID},
+			{"时间", inc.Time.Format("2006-01-02 15:04:05")},
+		}
+		if inc.Count > 1 {
+			fields = append(fields,
+				[2]string{"最后出现", inc.LastSeen.Format("2006-01-02 15:04:05")},
+				[2]string{"出现次数", fmt.Sprint(inc.Count)})
+		}
+		fields = append(fields, [][2]string{
+			{"用户", inc.User},
+			{"集群", inc.Context},
+			{"对象", inc.Object()},
+			{"严重级别", inc.Severity},
+			{"指纹", inc.Fingerprint},
+			{"提示词", inc.PromptVersion},
+		}...)
+		for _, f := range fields {
+			if f[1] != "" {
+				fmt.Printf("%s: %s\n", f[0], f[1])
+			}
+		}
+		fmt.Println("\n证据:")
+		for _, e := range inc.Evidence {
+			fmt.Println("  " + e)
+		}
+		fmt.Println("\n诊断:")
+		fmt.Println(inc.Diagnosis)
+		if inc.Resolved() {
+			fmt.Printf("\n修复方法（%s 于 %s 记录）:\n%s\n", inc.ResolvedBy, inc.ResolvedAt.Format("2006-01-02 15:04:05"), inc.Resolution)
+		} else {
+			fmt.Printf("\n尚未记录修复方法，可以用 k8scopilot incident resolve %s 记录\n", inc.ID)
+		}
+	},
+}
+
+var incidentResolveCmd = &cobra.Command{
+	Use:   "resolve <id> [修复方法]",
+	Short: "记录事故实际的修复方法，之后分析相似问题时会一并提供给模型",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !loadIncidents() {
+			return
+		}
+		note := strings.Join(args[1:], " ")
+		if note == "" {
+			inc, err := incidents.Find(args[0])
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			fmt.Printf("%s %s\n请输入实际的修复方法（单独一行 . 结束）:\n", inc.ID, inc.Object())
+			note = readNote()
+		}
+		if strings.TrimSpace(note) == "" {
+			fmt.Println("修复方法为空，未记录")
+			return
+		}
+		username := ""
+		if u, err := user.Current(); err == nil {
+			username = u.Username
+		}
+		inc, err := incidents.Resolve(args[0], note, username)
+		if err != nil {
+			fmt.Println("记录修复方法失败:", err)
+			return
+		}
+		fmt.Printf("已记录事故 %s 的修复方法\n", inc.ID)
+	},
+}
+
+var (
+	incidentUnresolved bool
+	incidentLimit      int
+)
+
+// readNote 从标准输入读取多行文本，遇到单独的 . 或 EOF 结束
+func readNote() string {
+	var lines []string
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "." {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+func loadIncidents() bool {
+	if err := initSession(); err != nil {
+		fmt.Println("读取事故库失败:", err)
+		return false
+	}
+	if incidents == nil {
+		fmt.Println("事故库已在配置文件中禁用（incidents.disabled）")
+		return false
+	}
+	return true
+}
+
+// recordIncident 把一次分析保存到事故库，写入失败只打印提示
+func recordIncident(d utils.Diagnosis) {
+	if err := initSession(); err != nil || incidents == nil {
+		return
+	}
+	inc := &utils.Incident{
+		Context:       clusterContext(),
+		Kind:          d.Kind,
+		Namespace:     d.Namespace,
+		Name:          d.Name,
+		Severity:      d.Severity,
+		Evidence:      d.Events,
+		Diagnosis:     d.Result,
+		PromptVersion: d.PromptVersion,
+	}
+	if u, err := user.Current(); err == nil {
+		inc.User = u.Username
+	}
+	if err := incidents.Record(inc); err != nil {
+		fmt.Println("保存事故记录失败:", err)
+		return
+	}
+	if inc.Count > 1 {
+		fmt.Printf("与尚未解决的事故 %s 相同，已更新（第 %d 次出现），确认修复方法后可以用 k8scopilot incident resolve %s 记录\n", inc.ID, inc.Count, inc.ID)
+		return
+	}
+	fmt.Printf("已记录为事故 %s，确认修复方法后可以用 k8scopilot incident resolve %s 记录\n", inc.ID, inc.ID)
+}
+
+// 每次分析最多列出的相似事故
+const maxSimilarIncidents = 3
+
+// similarIncidents 查找证据相似的历史事故，参数与分析结束后记录的事故一致
+func similarIncidents(kind, namespace, name string, evidence []string) []utils.IncidentMatch {
+	if err := initSession(); err != nil || incidents == nil {
+		return nil
+	}
+	matches, err := incidents.Similar(kind, namespace, name, evidence, maxSimilarIncidents)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "查找相似事故失败:", err)
+		return nil
+	}
+	return matches
+}
+
+// 每条历史事故放入提示词的诊断长度
+const pastDiagnosisTokens = 150
+
+// pastIncidentLines 把已确认修复方法的相似事故整理成提示词中的条目，没有修复方法的事故不提供给模型
+func pastIncidentLines(model string, matches []utils.IncidentMatch) []string {
+	var lines []string
+	for _, m := range matches {
+		if !m.Resolved() {
+			continue
+		}
+		diagnosis := strings.Join(strings.Fields(utils.TruncateToTokens(model, m.Diagnosis, pastDiagnosisTokens, false)), " ")
+		lines = append(lines, fmt.Sprintf("%s %s（相似度 %.0f%%）诊断: %s；确认的修复方法: %s",
+			m.Time.Format("2006-01-02"), m.Object(), m.Similarity*100, diagnosis, m.Resolution))
+	}
+	return lines
+}
+
+// withPastIncidents 在分析结果后列出相似的历史事故和已确认的修复方法
+func withPastIncidents(result string, matches []utils.IncidentMatch) string {
+	if len(matches) == 0 {
+		return result
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(result, "\n") + "\n\n相似的历史事故：")
+	for _, m := range matches {
+		fix := "尚未记录修复方法"
+		if m.Resolved() {
+			fix = "修复方法: " + m.Resolution
+		}
+		fmt.Fprintf(&b, "\n%s %s %s（相似度 %.0f%%）%s", m.ID, m.Time.Format("2006-01-02 15:04"), m.Object(), m.Similarity*100, fix)
+	}
+	return b.String()
+}
+
+func init() {
+	rootCmd.AddCommand(incidentCmd)
+	incidentCmd.AddCommand(incidentListCmd, incidentShowCmd, incidentResolveCmd)
+	incidentListCmd.Flags().BoolVar(&incidentUnresolved, "unresolved", false, "only show incidents without a recorded fix")
+	incidentListCmd.Flags().IntVar(&incidentLimit, "limit", 50, "show at most this many of the newest incidents (0 for all)")
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/sashabaranov/go-openai"
+)
+
+// 各类 analyze 命令使用的模型
+const analysisModel = openai.GPT4o
+
+// 整个进程共用一套组件，便于统计本次会话的脱敏和用量情况
+var (
+	sessionOnce sync.Once
+	sessionErr  error
+	redactor    *utils.Redactor
+	usage       *utils.UsageTracker
+	cache       *utils.ResponseCache
+	auditLog    *utils.AuditLog
+	undoStore   *utils.UndoStore
+	policy      *utils.Policy
+	runbooks    *utils.RunbookIndex
+	incidents   *utils.IncidentStore
+)
+
+func initSession() error {
+	sessionOnce.Do(func() {
+		redactor, sessionErr = utils.NewRedactor(appConfig.Redact)
+		if sessionErr != nil {
+			return
+		}
+		usage, sessionErr = utils.NewUsageTracker(appConfig.Usage, appConfig.DataPath())
+		cache = utils.NewResponseCache(appConfig.Cache, appConfig.DataPath())
+		auditLog = utils.NewAuditLog(appConfig.Audit, appConfig.DataPath())
+		auditLog.Redactor = redactor
+		undoStore = utils.NewUndoStore(appConfig.DataPath())
+		incidents = utils.NewIncidentStore(appConfig.Incidents, appConfig.DataPath())
+		if sessionErr == nil {
+			policy, sessionErr = utils.LoadPolicy(appConfig.Policy, appConfig.DataPath())
+		}
+		if sessionErr == nil {
+			runbooks, sessionErr = utils.LoadRunbooks(appConfig.Runbooks, appConfig.DataPath())
+		}
+	})
+	return sessionErr
+}
+
+// newLLMClient 创建 LLM 客户端，并挂上配置文件中启用的组件
+func newLLMClient() (*utils.OpenAI, error) {
+	client, err := utils.NewOpenAIClient()
+	if err != nil {
+		return nil, err
+	}
+	if err := initSession(); err != nil {
+		return nil, err
+	}
+	client.Redactor = redactor
+	client.Usage = usage
+	if !noCache {
+		client.Cache = cache
+	}
+	return client, nil
+}
+
+// analyzeWithLLM 用系统提示词加指定模板请求一次诊断，operation 用于用量统计
+func analyzeWithLLM(operation, name string, data any, maxTokens int) (string, error) {
+	client, err := newLLMClient()
+	if err != nil {
+		return "", err
+	}
+	prompt, err := promptSet.Render(name, data)
+	if err != nil {
+		return "", err
+	}
+	systemPrompt, err := promptSet.Render(utils.PromptSystem, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.ChatCompletion(
+		utils.WithPromptVersion(utils.WithOperation(context.TODO(), operation), promptSet.Version(name)),
+		openai.ChatCompletionRequest{
+			Model: analysisModel,
+			Messages: []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleSystem, Content: systemPrompt},
+				{Role: openai.ChatMessageRoleUser, Content: prompt},
+			},
+			MaxTokens: maxTokens, // 限制响应长度
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", errors.New("未收到有效响应")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// printRedactionReport 输出本次会话中被脱敏的内容统计
+func printRedactionReport() {
+	if report := redactor.Report(); report != "" {
+		fmt.Println("🔒 发送给模型前已脱敏:", report)
+	}
+}
+
+// printUsageSummary 输出本次会话的模型用量和费用
+func printUsageSummary() {
+	if summary := usage.Summary(); summary != "" {
+		fmt.Println("\n本次会话模型用量：")
+		fmt.Println(summary)
+	}
+}
@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// nodeCmd 分析节点健康状况，不指定节点名时分析全部有问题的节点
+var nodeCmd = &cobra.Command{
+	Use:   "node [name]",
+	Short: "分析节点状况、污点、资源分配、kubelet 事件和被驱逐的 Pod",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		issues, err := getNodeIssues(args)
+		if err != nil {
+			fmt.Println("获取节点状态失败:", err)
+			return
+		}
+		if len(issues) == 0 {
+			fmt.Println("✅ 节点运行正常")
+			return
+		}
+
+		notifier, err := utils.NewNotifier(appConfig.Notify)
+		if err != nil {
+			fmt.Println("初始化通知渠道失败:", err)
+			return
+		}
+		defer func() {
+			if err := notifier.Flush(context.TODO()); err != nil {
+				fmt.Println("发送通知失败:", err)
+			}
+		}()
+
+		for _, node := range issues {
+			fmt.Printf("\n节点 %s：\n", node.Name)
+			for _, f := range node.Findings {
+				fmt.Println("  " + f.String())
+			}
+			result, err := analyzeSingleNode(node)
+			if err != nil {
+				fmt.Printf("分析节点 %s 失败: %v\n", node.Name, err)
+				continue
+			}
+			fmt.Printf("\n节点 %s 分析结果：\n", node.Name)
+			fmt.Println(result)
+
+			sendDiagnosis(notifier, utils.Diagnosis{
+				Kind:          "Node",
+				Name:          node.Name,
+				Severity:      maxSeverity(node.Findings),
+				Events:        node.evidence(),
+				Result:        result,
+				PromptVersion: promptSet.Version(utils.PromptNodeAnalysis),
+			})
+		}
+		printRedactionReport()
+	},
+}
+
+// NodeIssue 是一个节点的检查结果
+type NodeIssue struct {
+	Name     string
+	Findings []finding
+	// 全部状况，格式为 Type=Status (Reason): Message
+	Conditions []string
+	Taints     []string
+	// 可分配资源和已请求资源的对比
+	Resources string
+	// 与节点相关的事件，包含 Normal 事件（例如 NodeNotReady）
+	Events []string
+	// 在该节点上被驱逐的 Pod
+	Evicted []string
+	// 节点上运行中的 Pod 数量
+	PodCount int
+}
+
+// evidence 返回记录事故和查找相似事故所用的证据：检查结果加上事件
+func (n NodeIssue) evidence() []string {
+	return slices.Concat(findingStrings(n.Findings), n.Events)
+}
+
+// 请求量超过可分配量的该比例时给出提示
+const nodeRequestThreshold = 0.9
+
+// 节点出现以下状况为 True 时视为异常
+var nodePressureConditions = []corev1.NodeConditionType{
+	corev1.NodeMemoryPressure,
+	corev1.NodeDiskPressure,
+	corev1.NodePIDPressure,
+	corev1.NodeNetworkUnavailable,
+}
+
+// getNodeIssues 检查指定节点，names 为空时检查全部节点并只返回有问题的
+func getNodeIssues(names []string) ([]NodeIssue, error) {
+	clientGo, err := newClientGo()
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes []corev1.Node
+	if len(names) > 0 {
+		for _, name := range names {
+			node, err := clientGo.ClientSet.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			nodes = append(nodes, *node)
+		}
+	} else {
+		list, err := clientGo.ClientSet.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		nodes = list.Items
+	}
+
+	pods, err := clientGo.ClientSet.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	podsByNode := map[string][]corev1.Pod{}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" {
+			podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], pod)
+		}
+	}
+
+	eventsByNode := map[string][]string{}
+	warned := map[string]bool{}
+	// 折叠后的事件已按时间排序
+	if events, err := utils.ListEvents(context.TODO(), clientGo, metav1.NamespaceAll, false); err == nil {
+		for _, e := range events {
+			if e.Kind != "Node" || !scope.recent(e) {
+				continue
+			}
+			eventsByNode[e.Name] = append(eventsByNode[e.Name], e.String())
+			if e.Type == corev1.EventTypeWarning {
+				warned[e.Name] = true
+			}
+		}
+	}
+
+	var issues []NodeIssue
+	for i := range nodes {
+		issue := inspectNode(&nodes[i], podsByNode[nodes[i].Name])
+		issue.Events = eventsByNode[issue.Name]
+		if warned[issue.Name] && len(issue.Findings) == 0 {
+			issue.Findings = append(issue.Findings, finding{utils.SeverityWarning, "节点上有 Warning 事件"})
+		}
+		if len(names) == 0 && len(issue.Findings) == 0 {
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	return issues, nil
+}
+
+// inspectNode 对单个节点做规则检查
+func inspectNode(node *corev1.Node, pods []corev1.Pod) NodeIssue {
+	issue := NodeIssue{Name: node.Name}
+
+	ready := false
+	for _, c := range node.Status.Conditions {
+		line := fmt.Sprintf("%s=%s", c.Type, c.Status)
+		if c.Reason != "" {
+			line += fmt.Sprintf(" (%s)", c.Reason)
+		}
+		if c.Message != "" {
+			line += ": " + c.Message
+		}
+		issue.Conditions = append(issue.Conditions, line)
+
+		if c.Type == corev1.NodeReady {
+			ready = c.Status == corev1.ConditionTrue
+			continue
+		}
+		for _, t := range nodePressureConditions {
+			if c.Type == t && c.Status == corev1.ConditionTrue {
+				issue.Findings = append(issue.Findings, finding{utils.SeverityCritical, line})
+			}
+		}
+	}
+	if !ready {
+		issue.Findings = append(issue.Findings, finding{utils.SeverityCritical, "节点 NotReady"})
+	}
+	if node.Spec.Unschedulable {
+		issue.Findings = append(issue.Findings, finding{utils.SeverityWarning, "节点已被 cordon，不会调度新的 Pod"})
+	}
+
+	for _, t := range node.Spec.Taints {
+		taint := t.ToString()
+		issue.Taints = append(issue.Taints, taint)
+		// 节点控制器根据状况自动添加的污点
+		if strings.HasPrefix(t.Key, "node.kubernetes.io/") && t.Key != corev1.TaintNodeUnschedulable {
+			issue.Findings = append(issue.Findings, finding{utils.SeverityWarning, "系统污点 " + taint})
+		}
+	}
+
+	var running []corev1.Pod
+	for _, pod := range pods {
+		if pod.Status.Reason == "Evicted" {
+			issue.Evicted = append(issue.Evicted, fmt.Sprintf("%s/%s: %s", pod.Namespace, pod.Name, pod.Status.Message))
+			continue
+		}
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			running = append(running, pod)
+		}
+	}
+	issue.PodCount = len(running)
+	if len(issue.Evicted) > 0 {
+		issue.Findings = append(issue.Findings, finding{utils.SeverityWarning, fmt.Sprintf("%d 个 Pod 在该节点上被驱逐", len(issue.Evicted))})
+	}
+
+	var lines []string
+	requested := corev1.ResourceList{}
+	for _, pod := range running {
+		addResourceList(requested, podRequests(&pod))
+	}
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory, corev1.ResourceEphemeralStorage} {
+		allocatable, ok := node.Status.Allocatable[name]
+		if !ok || allocatable.IsZero() {
+			continue
+		}
+		req := requested[name]
+		ratio := float64(req.MilliValue()) / float64(allocatable.MilliValue())
+		line := fmt.Sprintf("%s: 已请求 %s / 可分配 %s (%.0f%%)", name, req.String(), allocatable.String(), ratio*100)
+		lines = append(lines, line)
+		if ratio >= nodeRequestThreshold {
+			issue.Findings = append(issue.Findings, finding{utils.SeverityWarning, line})
+		}
+	}
+	if allocatable, ok := node.Status.Allocatable[corev1.ResourcePods]; ok && !allocatable.IsZero() {
+		line := fmt.Sprintf("pods: %d / %s", len(running), allocatable.String())
+		lines = append(lines, line)
+		if float64(len(running)) >= float64(allocatable.Value())*nodeRequestThreshold {
+			issue.Findings = append(issue.Findings, finding{utils.SeverityWarning, line})
+		}
+	}
+	issue.Resources = strings.Join(lines, "\n")
+	return issue
+}
+
+// podRequests 计算 Pod 的有效资源请求：业务容器之和与最大的 init 容器取较大值，再加上 overhead
+func podRequests(pod *corev1.Pod) corev1.ResourceList {
+	total := corev1.ResourceList{}
+	for _, c := range pod.Spec.Containers {
+		addResourceList(total, c.Resources.Requests)
+	}
+	for _, c := range pod.Spec.InitContainers {
+		for name, q := range c.Resources.Requests {
+			if cur, ok := total[name]; !ok || q.Cmp(cur) > 0 {
+				total[name] = q.DeepCopy()
+			}
+		}
+	}
+	addResourceList(total, pod.Spec.Overhead)
+	return total
+}
+
+func addResourceList(total, add corev1.ResourceList) {
+	for name, q := range add {
+		cur, ok := total[name]
+		if !ok {
+			cur = resource.Quantity{Format: q.Format}
+		}
+		cur.Add(q)
+		total[name] = cur
+	}
+}
+
+// analyzeSingleNode 把节点检查结果发给模型分析
+func analyzeSingleNode(node NodeIssue) (string, error) {
+	model := analysisModel
+	budget := utils.NewPromptBudget(model, appConfig.Budget)
+	data := utils.NodeAnalysisData{
+		Name:       node.Name,
+		Findings:   findingStrings(node.Findings),
+		Conditions: node.Conditions,
+		Taints:     node.Taints,
+		Resources:  node.Resources,
+	}
+	skeleton, err := promptSet.Render(utils.PromptNodeAnalysis, data)
+	if err != nil {
+		return "", err
+	}
+	hits := retrieveRunbooks(strings.Join(slices.Concat(data.Findings, node.Conditions, node.Taints), "\n"))
+	past := similarIncidents("Node", "", node.Name, node.evidence())
+	pastLines := pastIncidentLines(model, past)
+	alloc := budget.Allocate(utils.CountTokens(model, skeleton), []utils.BudgetSection{
+		{Name: "events", Need: eventsTokens(model, node.Events), Weight: 2},
+		{Name: "evicted", Need: eventsTokens(model, node.Evicted), Weight: 1},
+		{Name: "runbooks", Need: eventsTokens(model, runbookExcerpts(model, hits)), Weight: 2},
+		{Name: "incidents", Need: eventsTokens(model, pastLines), Weight: 2},
+	})
+	// 保留最新的事件
+	data.Events = reverse(limitEvents(model, reverse(node.Events), alloc["events"]))
+	data.Evicted = limitEvents(model, node.Evicted, alloc["evicted"])
+	data.Runbooks, hits = fitRunbooks(model, hits, alloc["runbooks"])
+	data.PastIncidents = limitEvents(model, pastLines, alloc["incidents"])
+
+	result, err := analyzeWithLLM("analyzeSingleNode", utils.PromptNodeAnalysis, data, budget.ResponseTokens)
+	if err != nil {
+		return "", err
+	}
+	return withPastIncidents(citeRunbooks(result, hits), past), nil
+}
+
+func reverse(s []string) []string {
+	out := make([]string, len(s))
+	for i, v := range s {
+		out[len(s)-1-i] = v
+	}
+	return out
+}
+
+func init() {
+	analyzeCmd.AddCommand(nodeCmd)
+}
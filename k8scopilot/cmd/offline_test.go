@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/TarlyJQ/aiops/k8scopilot/internal/testutil"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+func TestMain(m *testing.M) {
+	// 时间线和事件按本地时区格式化，固定时区保证 golden 文件一致
+	time.Local = time.UTC
+	os.Exit(m.Run())
+}
+
+// setupOffline 用 testdata 下的清单构造假集群，并把模型请求指向按 script 回放的本地服务
+// 每个测试使用独立的数据目录，会话组件和假集群都会重新创建
+func setupOffline(t *testing.T, fixtures string, script ...testutil.MockResponse) *testutil.MockLLMServer {
+	t.Helper()
+	server := testutil.NewMockLLMServer(script)
+	url, stop := server.Start()
+	t.Cleanup(stop)
+	t.Setenv("OPENAI_API_KEY", "test")
+	t.Setenv("OPENAI_BASE_URL", url)
+
+	appConfig = &utils.Config{DataDir: t.TempDir()}
+	promptSet = utils.NewPromptSet(appConfig.Prompts)
+	sessionOnce = sync.Once{}
+	cluster, err := testutil.NewFixtureClientGo(filepath.Join("testdata", fixtures))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCluster, snapshotFile = cluster, ""
+	t.Cleanup(func() { testCluster = nil })
+	accessCache = map[accessCheck]bool{}
+	noCache = true
+	pendingDryRun, pendingUndo, sessionUndo = "", nil, nil
+	return server
+}
+
+// assertGolden 对比 testdata/golden 中的文件，-update 时改为写入
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("读取 %s 失败（可以用 -update 生成）: %v", path, err)
+	}
+	if got != string(want) {
+		t.Errorf("%s 与 golden 文件不一致，确认改动后用 go test ./cmd -update 更新\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
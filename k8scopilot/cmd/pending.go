@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// pendingCmd 在本地重放调度器的过滤逻辑，解释 Pending Pod 为什么无法调度
+var pendingCmd = &cobra.Command{
+	Use:   "pending [pod]",
+	Short: "逐个节点解释 Pending Pod 无法调度的原因",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		clientGo, err := newClientGo()
+		if err != nil {
+			fmt.Println("连接集群失败:", err)
+			return
+		}
+		pods, err := getPendingPods(clientGo, args)
+		if err != nil {
+			fmt.Println("获取 Pod 失败:", err)
+			return
+		}
+		if len(pods) == 0 {
+			fmt.Println("✅ 没有等待调度的 Pod")
+			return
+		}
+		nodes, err := clientGo.ClientSet.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			fmt.Println("获取节点失败:", err)
+			return
+		}
+		all, err := clientGo.ClientSet.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			fmt.Println("获取 Pod 失败:", err)
+			return
+		}
+		sim := newSchedulerSim(nodes.Items, all.Items)
+		resolver := newOwnerResolver(clientGo)
+
+		notifier, err := utils.NewNotifier(appConfig.Notify)
+		if err != nil {
+			fmt.Println("初始化通知渠道失败:", err)
+			return
+		}
+		defer func() {
+			if err := notifier.Flush(context.TODO()); err != nil {
+				fmt.Println("发送通知失败:", err)
+			}
+		}()
+
+		for i := range pods {
+			pod := &pods[i]
+			verdicts := sim.explain(pod)
+			fmt.Printf("\n%s/%s：%s\n", pod.Namespace, pod.Name, summarizeVerdicts(verdicts))
+			for _, v := range verdicts {
+				fmt.Println("  " + v.String())
+			}
+			if !pendingFix {
+				continue
+			}
+			workload := resolver.resolve(pod)
+			events := failedSchedulingEvents(clientGo, pod)
+			result, err := analyzeScheduling(pod, workload, verdicts, events)
+			if err != nil {
+				fmt.Printf("分析 %s/%s 失败: %v\n", pod.Namespace, pod.Name, err)
+				continue
+			}
+			fmt.Printf("\n%s/%s 修复建议：\n", pod.Namespace, pod.Name)
+			fmt.Println(result)
+
+			sendDiagnosis(notifier, utils.Diagnosis{
+				Kind:          workload.Kind,
+				Namespace:     pod.Namespace,
+				Name:          workload.Name,
+				Severity:      utils.SeverityWarning,
+				Events:        schedulingEvidence(verdicts, events),
+				Result:        result,
+				PromptVersion: promptSet.Version(utils.PromptSchedulingAnalysis),
+			})
+		}
+		if pendingFix {
+			printRedactionReport()
+		}
+	},
+}
+
+var pendingFix bool
+
+// getPendingPods 返回指定的 Pod，未指定时返回检查范围内所有尚未绑定节点的 Pending Pod
+func getPendingPods(clientGo *utils.ClientGo, names []string) ([]corev1.Pod, error) {
+	if len(names) > 0 {
+		pod, err := clientGo.ClientSet.CoreV1().Pods(namespace).Get(context.TODO(), names[0], metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return []corev1.Pod{*pod}, nil
+	}
+	list, err := clientGo.ClientSet.CoreV1().Pods(scope.Namespace).List(context.TODO(), scope.podListOptions())
+	if err != nil {
+		return nil, err
+	}
+	var pods []corev1.Pod
+	for _, pod := range list.Items {
+		if pod.Status.Phase == corev1.PodPending && pod.Spec.NodeName == "" && scope.includesPod(&pod) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// 与调度相关的 Pod 配置
+func schedulingConstraints(pod *corev1.Pod) string {
+	summary := struct {
+		Requests                  corev1.ResourceList               `json:"requests,omitempty"`
+		NodeSelector              map[string]string                 `json:"nodeSelector,omitempty"`
+		Affinity                  *corev1.Affinity                  `json:"affinity,omitempty"`
+		Tolerations               []corev1.Toleration               `json:"tolerations,omitempty"`
+		TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+		PriorityClassName         string                            `json:"priorityClassName,omitempty"`
+	}{
+		Requests:                  podRequests(pod),
+		NodeSelector:              pod.Spec.NodeSelector,
+		Affinity:                  pod.Spec.Affinity,
+		Tolerations:               pod.Spec.Tolerations,
+		TopologySpreadConstraints: pod.Spec.TopologySpreadConstraints,
+		PriorityClassName:         pod.Spec.PriorityClassName,
+	}
+	out, err := yaml.Marshal(summary)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// analyzeScheduling 把逐节点的判断结果交给模型，请它给出修复建议
+func analyzeScheduling(pod *corev1.Pod, workload workloadRef, verdicts []nodeVerdict, events []string) (string, error) {
+	nodes := make([]string, 0, len(verdicts))
+	for _, v := range verdicts {
+		nodes = append(nodes, v.String())
+	}
+
+	model := analysisModel
+	budget := utils.NewPromptBudget(model, appConfig.Budget)
+	data := utils.SchedulingAnalysisData{
+		Namespace:   pod.Namespace,
+		Name:        pod.Name,
+		Constraints: schedulingConstraints(pod),
+		Summary:     summarizeVerdicts(verdicts),
+	}
+	skeleton, err := promptSet.Render(utils.PromptSchedulingAnalysis, data)
+	if err != nil {
+		return "", err
+	}
+	hits := retrieveRunbooks(data.Summary + "\n" + strings.Join(events, "\n"))
+	past := similarIncidents(workload.Kind, pod.Namespace, workload.Name, schedulingEvidence(verdicts, events))
+	pastLines := pastIncidentLines(model, past)
+	alloc := budget.Allocate(utils.CountTokens(model, skeleton), []utils.BudgetSection{
+		{Name: "events", Need: eventsTokens(model, events), Weight: 1},
+		{Name: "nodes", Need: eventsTokens(model, nodes), Weight: 3},
+		{Name: "runbooks", Need: eventsTokens(model, runbookExcerpts(model, hits)), Weight: 2},
+		{Name: "incidents", Need: eventsTokens(model, pastLines), Weight: 2},
+	})
+	data.Events = limitEvents(model, events, alloc["events"])
+	data.Nodes = limitEvents(model, nodes, alloc["nodes"])
+	data.Runbooks, hits = fitRunbooks(model, hits, alloc["runbooks"])
+	data.PastIncidents = limitEvents(model, pastLines, alloc["incidents"])
+
+	result, err := analyzeWithLLM("analyzeScheduling", utils.PromptSchedulingAnalysis, data, budget.ResponseTokens)
+	if err != nil {
+		return "", err
+	}
+	return withPastIncidents(citeRunbooks(result, hits), past), nil
+}
+
+// failedSchedulingEvents 返回 Pod 在检查范围内的 FailedScheduling 事件
+func failedSchedulingEvents(clientGo *utils.ClientGo, pod *corev1.Pod) []string {
+	var events []string
+	if list, err := utils.ListObjectEvents(context.TODO(), clientGo, "Pod", pod.Namespace, pod.Name, false); err == nil {
+		for _, e := range list {
+			if e.Reason == "FailedScheduling" && scope.recent(e) {
+				events = append(events, e.String())
+			}
+		}
+	}
+	return events
+}
+
+// schedulingEvidence 返回记录事故和查找相似事故所用的证据：各谓词排除的节点数加上 FailedScheduling 事件
+func schedulingEvidence(verdicts []nodeVerdict, events []string) []string {
+	return append([]string{summarizeVerdicts(verdicts)}, events...)
+}
+
+func init() {
+	analyzeCmd.AddCommand(pendingCmd)
+	pendingCmd.Flags().BoolVar(&pendingFix, "fix", false, "ask the model for a fix based on the per-node results")
+}
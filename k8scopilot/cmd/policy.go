@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// policyError 表示操作被策略文件中的规则拒绝，作为工具结果返回给模型
+type policyError struct {
+	Tool   string
+	Target string
+	Rule   string
+	Reason string
+}
+
+func (e *policyError) Error() string {
+	msg := fmt.Sprintf("denied: 策略规则 %s 不允许 %s 执行 %s", e.Rule, e.Tool, e.Target)
+	if e.Reason != "" {
+		msg += "，原因: " + e.Reason
+	}
+	return msg
+}
+
+func isPolicyDenied(err error) bool {
+	var denied *policyError
+	return errors.As(err, &denied)
+}
+
+// newPolicyRequest 描述工具对某个资源的操作，内置资源以外的都视为自定义资源
+func newPolicyRequest(tool, verb string, gvr schema.GroupVersionResource, namespace, name string) utils.PolicyRequest {
+	return utils.PolicyRequest{
+		Tool:      tool,
+		Verb:      verb,
+		Group:     gvr.Group,
+		Resource:  gvr.Resource,
+		Namespace: namespace,
+		Name:      name,
+		Custom:    !scheme.Scheme.IsGroupRegistered(gvr.Group),
+	}
+}
+
+// withObject 附上将要提交的对象，并从 spec.replicas 读取副本数
+func withObject(req utils.PolicyRequest, obj map[string]any) utils.PolicyRequest {
+	req.Object = obj
+	if replicas, found, err := unstructured.NestedInt64(obj, "spec", "replicas"); found && err == nil {
+		req.Replicas = &replicas
+	}
+	return req
+}
+
+// enforcePolicy 在工具请求 apiserver 之前按策略文件检查操作
+// 规则要求 dry-run 时先以 dry-run 方式调用 submit，失败则拒绝；只读操作 submit 可以为空
+func enforcePolicy(clientGo *utils.ClientGo, req utils.PolicyRequest, submit func(dryRun []string) error) error {
+	if err := initSession(); err != nil {
+		return err
+	}
+	if len(policy.Rules) == 0 {
+		return nil
+	}
+	target := gvrAccess(req.Verb, schema.GroupVersionResource{Group: req.Group, Resource: req.Resource}, req.Namespace, req.Name).String()
+	deny := func(rule, reason string) error {
+		return &policyError{Tool: req.Tool, Target: target, Rule: rule, Reason: reason}
+	}
+	if req.Namespace != "" && policy.UsesNamespaceLabels() {
+		ns, err := clientGo.ClientSet.CoreV1().Namespaces().Get(context.TODO(), req.Namespace, metav1.GetOptions{})
+		switch {
+		case err == nil:
+			req.NamespaceLabels = ns.Labels
+		case !apierrors.IsNotFound(err):
+			// 读不到标签时无法判断 namespaceSelector，按拒绝处理
+			return deny("namespaceSelector", "无法读取命名空间标签: "+err.Error())
+		}
+	}
+
+	decision := policy.Evaluate(req)
+	if decision.Denied() {
+		return deny(decision.DeniedBy, decision.Reason)
+	}
+	if decision.DryRunBy == "" || submit == nil {
+		return nil
+	}
+	if clientGo.NoDryRun {
+		return deny(decision.DryRunBy, "要求先 dry-run，但当前集群不支持 dry-run")
+	}
+	if err := submit([]string{metav1.DryRunAll}); err != nil {
+		return deny(decision.DryRunBy, "dry-run 未通过: "+err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+)
+
+// promptsCmd 管理提示词模板
+var promptsCmd = &cobra.Command{
+	Use:   "prompts",
+	Short: "查看和导出提示词模板",
+}
+
+var promptsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出当前生效的提示词模板及版本",
+	Run: func(cmd *cobra.Command, args []string) {
+		infos, err := promptSet.List()
+		if err != nil {
+			fmt.Println("读取模板失败:", err)
+			return
+		}
+		for _, info := range infos {
+			fmt.Printf("%-16s %-4s v%-6s %s\n", info.Name, info.Lang, info.Version, info.Source)
+		}
+	},
+}
+
+var promptsExportCmd = &cobra.Command{
+	Use:   "export <dir>",
+	Short: "导出内置模板到目录，修改后在配置文件中通过 prompts.dir 引用",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := utils.ExportBuiltinPrompts(args[0]); err != nil {
+			fmt.Println("导出模板失败:", err)
+			return
+		}
+		fmt.Println("已导出到", args[0])
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(promptsCmd)
+	promptsCmd.AddCommand(promptsListCmd)
+	promptsCmd.AddCommand(promptsExportCmd)
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reportCmd 运行全部分析器，生成一份集群健康报告
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "检查全部命名空间的 Pod、节点、Service、存储和滚动更新，生成 Markdown/HTML 健康报告",
+	Run: func(cmd *cobra.Command, args []string) {
+		format := reportFormat
+		if format == "" {
+			format = "markdown"
+			if ext := filepath.Ext(reportOutput); ext == ".html" || ext == ".htm" {
+				format = "html"
+			}
+		}
+		if format != "markdown" && format != "html" {
+			fmt.Println("不支持的格式:", format)
+			return
+		}
+
+		clientGo, err := newClientGo()
+		if err != nil {
+			fmt.Println("连接集群失败:", err)
+			return
+		}
+		report := collectReport(clientGo)
+		if reportSummary && len(report.Items) > 0 {
+			summary, err := summarizeReport(report)
+			if err != nil {
+				report.Errors = append(report.Errors, "生成摘要失败: "+err.Error())
+			}
+			report.Summary = summary
+		}
+
+		output := reportOutput
+		if output == "" {
+			ext := ".md"
+			if format == "html" {
+				ext = ".html"
+			}
+			output = fmt.Sprintf("report-%s%s", report.GeneratedAt.Format("20060102-150405"), ext)
+		}
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Println("创建文件失败:", err)
+			return
+		}
+		defer f.Close()
+		render := utils.RenderMarkdown
+		if format == "html" {
+			render = utils.RenderHTML
+		}
+		if err := render(f, report); err != nil {
+			fmt.Println("生成报告失败:", err)
+			return
+		}
+		fmt.Printf("✅ 共 %d 个问题（critical %d），报告已写入 %s\n", len(report.Items), report.Count(utils.SeverityCritical), output)
+		printRedactionReport()
+	},
+}
+
+var (
+	reportOutput  string
+	reportFormat  string
+	reportSummary bool
+)
+
+// collectReport 依次运行各个分析器的规则检查，不会为单个问题调用模型
+// 单个分析器失败时记录在报告中，不影响其它分析器
+func collectReport(clientGo *utils.ClientGo) *utils.Report {
+	report := &utils.Report{
+		Context:     utils.CurrentContext(kubeconfig),
+		GeneratedAt: time.Now(),
+	}
+	index := map[string]int{}
+	addItem := func(kind, ns, name string, impact int, findings []finding) {
+		severity := maxSeverity(findings)
+		if severity == utils.SeverityInfo {
+			return
+		}
+		var lines []string
+		for _, f := range findings {
+			if f.Severity != utils.SeverityInfo {
+				lines = append(lines, f.Message)
+			}
+		}
+		// 同一对象（例如既有异常 Pod 又卡在滚动更新的 Deployment）合并为一项
+		key := utils.ReportItem{Kind: kind, Namespace: ns, Name: name}.Object()
+		if i, ok := index[key]; ok {
+			item := &report.Items[i]
+			if utils.SeverityRank(severity) > utils.SeverityRank(item.Severity) {
+				item.Severity = severity
+			}
+			item.Impact = max(item.Impact, impact)
+			item.Findings = append(item.Findings, lines...)
+			return
+		}
+		index[key] = len(report.Items)
+		report.Items = append(report.Items, utils.ReportItem{
+			Kind: kind, Namespace: ns, Name: name, Severity: severity, Impact: impact, Findings: lines,
+		})
+	}
+	fail := func(analyzer string, err error) {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", analyzer, err))
+	}
+
+	// Pod（已按工作负载聚合），报告只用事件，不拉取日志
+	if pods, err := getProblemPods(false); err != nil {
+		fail("pods", err)
+	} else {
+		for _, p := range pods {
+			var findings []finding
+			for _, e := range p.Events {
+				findings = append(findings, finding{podSeverity(p), e})
+			}
+			kind, name := p.Workload.Kind, p.Workload.Name
+			if kind == "" {
+				kind, name = "Pod", p.Name
+			}
+			addItem(kind, p.Namespace, name, max(len(p.Pods), 1), findings)
+		}
+	}
+
+	// 节点
+	if nodes, err := getNodeIssues(nil); err != nil {
+		fail("nodes", err)
+	} else {
+		for _, n := range nodes {
+			addItem("Node", "", n.Name, n.PodCount, n.Findings)
+		}
+	}
+
+	// Service，同一命名空间的 Pod、EndpointSlice、Ingress 和 NetworkPolicy 只列一次
+	if services, err := clientGo.ClientSet.CoreV1().Services(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{}); err != nil {
+		fail("services", err)
+	} else {
+		objsByNamespace := map[string]*serviceObjects{}
+		for i := range services.Items {
+			svc := &services.Items[i]
+			if svc.Spec.Type == corev1.ServiceTypeExternalName || len(svc.Spec.Selector) == 0 {
+				continue
+			}
+			objs, listed := objsByNamespace[svc.Namespace]
+			if !listed {
+				if objs, err = listServiceObjects(clientGo, svc.Namespace); err != nil {
+					fail("services in "+svc.Namespace, err)
+				}
+				objsByNamespace[svc.Namespace] = objs
+			}
+			if objs == nil {
+				continue
+			}
+			issue := checkService(svc, objs)
+			addItem("Service", svc.Namespace, svc.Name, len(issue.Pods), issue.Findings)
+		}
+	}
+
+	// 存储
+	if issues, err := inspectStorage(clientGo, metav1.NamespaceAll, nil); err != nil {
+		fail("storage", err)
+	} else {
+		for _, s := range issues {
+			addItem(s.Kind, s.Namespace, s.Name, len(s.Pods), s.Findings)
+		}
+	}
+
+	// 未完成的滚动更新
+	if deployments, err := clientGo.ClientSet.AppsV1().Deployments(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{}); err != nil {
+		fail("rollouts", err)
+	} else {
+		for _, d := range deployments.Items {
+			if rolloutComplete(&d) {
+				continue
+			}
+			issue, err := inspectRollout(clientGo, d.Namespace, d.Name, false)
+			if err != nil {
+				fail("rollout "+d.Namespace+"/"+d.Name, err)
+				continue
+			}
+			addItem("Deployment", d.Namespace, d.Name, int(ptrValue(d.Spec.Replicas, 1)), issue.Findings)
+		}
+	}
+
+	report.Sort()
+	return report
+}
+
+// 副本都已更新且可用、没有多余的旧 Pod 时视为滚动更新已完成
+func rolloutComplete(d *appsv1.Deployment) bool {
+	replicas := ptrValue(d.Spec.Replicas, 1)
+	return d.Status.UpdatedReplicas == replicas && d.Status.AvailableReplicas == replicas && d.Status.Replicas == replicas
+}
+
+// summarizeReport 请模型根据排好序的问题列表写一段给值班人员的摘要
+func summarizeReport(report *utils.Report) (string, error) {
+	model := analysisModel
+	budget := utils.NewPromptBudget(model, appConfig.Budget)
+	data := utils.ReportSummaryData{
+		Context:  report.Context,
+		Critical: report.Count(utils.SeverityCritical),
+		Warning:  report.Count(utils.SeverityWarning),
+	}
+	skeleton, err := promptSet.Render(utils.PromptReportSummary, data)
+	if err != nil {
+		return "", err
+	}
+	items := make([]string, 0, len(report.Items))
+	for _, item := range report.Items {
+		items = append(items, fmt.Sprintf("[%s] %s（影响 %d 个 Pod）: %s", strings.ToUpper(item.Severity), item.Object(), item.Impact, strings.Join(item.Findings, "; ")))
+	}
+	alloc := budget.Allocate(utils.CountTokens(model, skeleton), []utils.BudgetSection{
+		{Name: "items", Need: eventsTokens(model, items), Weight: 1},
+	})
+	data.Items = limitEvents(model, items, alloc["items"])
+
+	return analyzeWithLLM("summarizeReport", utils.PromptReportSummary, data, budget.ResponseTokens)
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "output file (default report-<time>.md)")
+	reportCmd.Flags().StringVar(&reportFormat, "format", "", "markdown or html (default: from the output file extension, otherwise markdown)")
+	reportCmd.Flags().StringVar(&snapshotFile, "from-snapshot", "", "build the report from a snapshot archive instead of the live cluster")
+	reportCmd.Flags().BoolVar(&reportSummary, "summary", true, "add an executive summary written by the model")
+}
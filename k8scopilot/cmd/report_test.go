@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/TarlyJQ/aiops/k8scopilot/internal/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReport(t *testing.T) {
+	server := setupOffline(t, "cluster", testutil.MockResponse{
+		Content: "web 的新版本无法连接数据库，node-1 内存不足且已被 cordon，请优先处理。",
+	})
+	clientGo, err := newClientGo()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一命名空间的两个 Service 共用一次列表
+	for _, name := range []string{"web", "web-canary"} {
+		svc := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+			Spec: corev1.ServiceSpec{
+				Selector: map[string]string{"app": name},
+				Ports:    []corev1.ServicePort{{Port: 80, TargetPort: intstr.FromInt32(8080)}},
+			},
+		}
+		if _, err := clientGo.ClientSet.CoreV1().Services("shop").Create(context.TODO(), svc, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clientSet := clientGo.ClientSet.(*fake.Clientset)
+	clientSet.ClearActions()
+	logReads := 0
+	readLogs := clientGo.LogReader
+	clientGo.LogReader = func(namespace, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+		logReads++
+		return readLogs(namespace, podName, opts)
+	}
+
+	report := collectReport(clientGo)
+	counts := map[string]int{}
+	for _, a := range clientSet.Actions() {
+		counts[a.GetVerb()+" "+a.GetResource().Resource+"/"+a.GetSubresource()]++
+	}
+	if counts["list endpointslices/"] != 1 || counts["list ingresses/"] != 1 || counts["list networkpolicies/"] != 1 {
+		t.Errorf("每个命名空间只应列一次: %v", counts)
+	}
+	if logReads != 0 {
+		t.Errorf("报告不应拉取日志，实际读取了 %d 次", logReads)
+	}
+	report.Context = "fixtures"
+	report.GeneratedAt = time.Date(2026, 10, 19, 8, 10, 0, 0, time.UTC)
+	summary, err := summarizeReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report.Summary = summary
+	if len(server.Requests()) != 1 {
+		t.Fatalf("期望 1 次模型请求，实际 %d 次", len(server.Requests()))
+	}
+
+	var b strings.Builder
+	if err := utils.RenderMarkdown(&b, report); err != nil {
+		t.Fatal(err)
+	}
+	assertGolden(t, "report.md.golden", b.String())
+}
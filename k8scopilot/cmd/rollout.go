@@ -0,0 +1,570 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// rolloutCmd 分析卡住或失败的 Deployment 滚动更新
+var rolloutCmd = &cobra.Command{
+	Use:   "rollout deployment/<name>",
+	Short: "对比新旧 ReplicaSet、进度条件、maxSurge/maxUnavailable 和镜像变化，判断是否需要回滚",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, err := parseDeploymentArg(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if rolloutRollback && snapshotFile != "" {
+			fmt.Println("快照是只读的，不能和 --from-snapshot 一起使用 --rollback")
+			return
+		}
+		clientGo, err := newClientGo()
+		if err != nil {
+			fmt.Println("连接集群失败:", err)
+			return
+		}
+		issue, err := inspectRollout(clientGo, namespace, name, true)
+		if err != nil {
+			fmt.Println("获取 Deployment 失败:", err)
+			return
+		}
+
+		fmt.Printf("Deployment %s/%s：\n", issue.Namespace, issue.Name)
+		for _, r := range issue.Revisions {
+			fmt.Println("  " + r)
+		}
+		for _, c := range issue.Changes {
+			fmt.Println("  变更: " + c)
+		}
+		for _, f := range issue.Findings {
+			fmt.Println("  " + f.String())
+		}
+		fmt.Println("\n建议:", issue.Recommendation)
+
+		notifier, err := utils.NewNotifier(appConfig.Notify)
+		if err != nil {
+			fmt.Println("初始化通知渠道失败:", err)
+			return
+		}
+
+		if maxSeverity(issue.Findings) != utils.SeverityInfo {
+			result, err := analyzeRollout(issue)
+			if err != nil {
+				fmt.Println("分析失败:", err)
+			} else {
+				fmt.Println("\n分析结果：")
+				fmt.Println(result)
+				sendDiagnosis(notifier, utils.Diagnosis{
+					Kind:          "Deployment",
+					Namespace:     issue.Namespace,
+					Name:          issue.Name,
+					Severity:      maxSeverity(issue.Findings),
+					Events:        findingStrings(issue.Findings),
+					Result:        result,
+					PromptVersion: promptSet.Version(utils.PromptRolloutAnalysis),
+				})
+				printRedactionReport()
+			}
+		}
+		// 在等待确认回滚之前发出通知
+		if err := notifier.Flush(context.TODO()); err != nil {
+			fmt.Println("发送通知失败:", err)
+		}
+
+		if !rolloutRollback {
+			return
+		}
+		revision := rolloutToRevision
+		if revision == 0 {
+			revision = issue.RollbackRevision
+		}
+		if revision == 0 {
+			fmt.Println("没有可以回滚的历史版本")
+			return
+		}
+		fmt.Printf("\n确认把 %s/%s 回滚到 revision %d？(y/N): ", issue.Namespace, issue.Name, revision)
+		var answer string
+		_, _ = fmt.Scanln(&answer)
+		record := utils.AuditRecord{
+			Command:   "analyze rollout",
+			Tool:      "rollbackDeployment",
+			Arguments: fmt.Sprintf(`{"namespace":%q,"name":%q,"revision":%d}`, issue.Namespace, issue.Name, revision),
+			DryRun:    issue.Recommendation,
+		}
+		if strings.ToLower(answer) != "y" {
+			record.Confirmation, record.Result = "declined", utils.AuditDeclined
+			recordAudit(record)
+			fmt.Println("已取消")
+			return
+		}
+		result, err := rollbackDeployment(issue.Namespace, issue.Name, revision)
+		record.Confirmation = "confirmed"
+		auditOutcome(&record, result, err)
+		recordAudit(record)
+		if err != nil {
+			fmt.Println("回滚失败:", err)
+			return
+		}
+		fmt.Println(result)
+	},
+}
+
+var (
+	rolloutRollback   bool
+	rolloutToRevision int64
+)
+
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// RolloutIssue 是一次滚动更新的检查结果
+type RolloutIssue struct {
+	Namespace string
+	Name      string
+	Findings  []finding
+	// 副本数、更新策略和状况（YAML）
+	Status string
+	// 各版本 ReplicaSet 的概况，新版本在前
+	Revisions []string
+	// 新版本相对上一版本的变化
+	Changes []string
+	// 新版本中失败 Pod 的证据，没有时 Name 为空
+	Pod PodIssue
+	// 规则给出的建议，以及建议回滚到的版本（0 表示不建议回滚）
+	Recommendation   string
+	RollbackRevision int64
+}
+
+// parseDeploymentArg 解析 deployment/<name>，也接受只写名称
+func parseDeploymentArg(arg string) (string, error) {
+	kind, name, ok := strings.Cut(arg, "/")
+	if !ok {
+		return arg, nil
+	}
+	switch strings.ToLower(kind) {
+	case "deployment", "deployments", "deploy", "deployment.apps":
+		return name, nil
+	}
+	return "", fmt.Errorf("目前只支持 Deployment，收到 %s", kind)
+}
+
+func revisionOf(obj metav1.Object) int64 {
+	v, _ := strconv.ParseInt(obj.GetAnnotations()[revisionAnnotation], 10, 64)
+	return v
+}
+
+// deploymentReplicaSets 返回 Deployment 管理的 ReplicaSet，按版本从新到旧排序
+func deploymentReplicaSets(clientGo *utils.ClientGo, d *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	list, err := clientGo.ClientSet.AppsV1().ReplicaSets(d.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var owned []appsv1.ReplicaSet
+	for _, rs := range list.Items {
+		if ref := metav1.GetControllerOf(&rs); ref != nil && ref.Kind == "Deployment" && ref.Name == d.Name {
+			owned = append(owned, rs)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool { return revisionOf(&owned[i]) > revisionOf(&owned[j]) })
+	return owned, nil
+}
+
+// inspectRollout 对 Deployment 的滚动更新做规则检查，withLogs 为 false 时不拉取新版本 Pod 的日志
+func inspectRollout(clientGo *utils.ClientGo, namespace, name string, withLogs bool) (RolloutIssue, error) {
+	d, err := clientGo.ClientSet.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return RolloutIssue{}, err
+	}
+	issue := RolloutIssue{Namespace: d.Namespace, Name: d.Name}
+	add := func(severity, format string, args ...any) {
+		issue.Findings = append(issue.Findings, finding{severity, fmt.Sprintf(format, args...)})
+	}
+	replicaSets, err := deploymentReplicaSets(clientGo, d)
+	if err != nil {
+		return issue, err
+	}
+
+	// 1. 新旧 ReplicaSet
+	var newRS *appsv1.ReplicaSet
+	var oldRSs []appsv1.ReplicaSet
+	for i := range replicaSets {
+		rs := &replicaSets[i]
+		if newRS == nil && revisionOf(rs) == revisionOf(d) {
+			newRS = rs
+			continue
+		}
+		oldRSs = append(oldRSs, *rs)
+	}
+	if newRS == nil && len(replicaSets) > 0 {
+		newRS, oldRSs = &replicaSets[0], replicaSets[1:]
+	}
+	for _, rs := range replicaSets {
+		label := "旧"
+		if newRS != nil && rs.Name == newRS.Name {
+			label = "新"
+		}
+		issue.Revisions = append(issue.Revisions, fmt.Sprintf("revision %d（%s）%s: 期望 %d，就绪 %d，可用 %d，镜像 %s",
+			revisionOf(&rs), label, rs.Name, ptrValue(rs.Spec.Replicas, 1), rs.Status.ReadyReplicas, rs.Status.AvailableReplicas, strings.Join(templateImages(&rs.Spec.Template), ",")))
+	}
+
+	// 2. 状况
+	if d.Spec.Paused {
+		add(utils.SeverityWarning, "Deployment 已暂停（paused），滚动更新不会继续")
+	}
+	if d.Status.ObservedGeneration < d.Generation {
+		add(utils.SeverityInfo, "控制器尚未处理最新的修改（generation %d，observed %d）", d.Generation, d.Status.ObservedGeneration)
+	}
+	deadlineExceeded := false
+	for _, c := range d.Status.Conditions {
+		switch {
+		case c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded":
+			deadlineExceeded = true
+			add(utils.SeverityCritical, "超过 progressDeadlineSeconds（%ds）仍未完成: %s", ptrValue(d.Spec.ProgressDeadlineSeconds, 600), c.Message)
+		case c.Type == appsv1.DeploymentReplicaFailure && c.Status == corev1.ConditionTrue:
+			add(utils.SeverityCritical, "创建 Pod 失败（%s）: %s", c.Reason, c.Message)
+		}
+	}
+
+	// 3. maxSurge/maxUnavailable
+	replicas := ptrValue(d.Spec.Replicas, 1)
+	if d.Spec.Strategy.Type == appsv1.RecreateDeploymentStrategyType {
+		add(utils.SeverityInfo, "更新策略为 Recreate，旧 Pod 全部删除后才会创建新 Pod")
+	} else {
+		surge, unavailable := rollingUpdateLimits(d)
+		total := d.Status.Replicas
+		maxTotal, minAvailable := replicas+surge, replicas-unavailable
+		math := fmt.Sprintf("replicas=%d，maxSurge=%d（最多 %d 个 Pod），maxUnavailable=%d（至少 %d 个可用），当前共 %d 个 Pod、可用 %d 个",
+			replicas, surge, maxTotal, unavailable, minAvailable, total, d.Status.AvailableReplicas)
+		newReady := int32(0)
+		if newRS != nil {
+			newReady = newRS.Status.AvailableReplicas
+		}
+		blocked := total >= maxTotal && d.Status.AvailableReplicas <= minAvailable && newRS != nil && newReady < ptrValue(newRS.Spec.Replicas, 0)
+		if blocked {
+			add(utils.SeverityCritical, "滚动更新无法推进：%s。已达到 Pod 上限且可用数不高于下限，只能等新 Pod 就绪", math)
+		} else {
+			add(utils.SeverityInfo, "滚动更新参数：%s", math)
+		}
+	}
+
+	// 4. 版本间的变化
+	var previous *appsv1.ReplicaSet
+	for i := range oldRSs {
+		if len(oldRSs[i].Spec.Template.Spec.Containers) > 0 {
+			previous = &oldRSs[i]
+			break
+		}
+	}
+	if newRS != nil && previous != nil {
+		issue.Changes = templateChanges(&previous.Spec.Template, &newRS.Spec.Template)
+		issue.RollbackRevision = revisionOf(previous)
+	}
+
+	// 5. 新版本 Pod 的证据
+	newFailing := false
+	if newRS != nil {
+		pods, err := clientGo.ClientSet.CoreV1().Pods(d.Namespace).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return issue, err
+		}
+		var rep *corev1.Pod
+		var failing []string
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if ref := metav1.GetControllerOf(pod); ref == nil || ref.Name != newRS.Name || podReady(pod) {
+				continue
+			}
+			failing = append(failing, pod.Name)
+			if rep == nil || podRestarts(pod) > podRestarts(rep) {
+				rep = pod
+			}
+		}
+		if rep != nil {
+			newFailing = podRestarts(rep) > 0 || podWaitingReason(rep) != ""
+			issue.Pod = PodIssue{
+				Name:      rep.Name,
+				Namespace: rep.Namespace,
+				Events:    podWarningEvents(clientGo, rep.Namespace, rep.Name),
+				Spec:      podSpecSummary(rep),
+				Workload:  workloadRef{Kind: "Deployment", Namespace: d.Namespace, Name: d.Name},
+				Pods:      failing,
+			}
+			if withLogs && rep.Status.Phase == corev1.PodRunning {
+				if logs, err := getPodLogs(rep.Namespace, rep.Name); err == nil {
+					issue.Pod.Logs = logs
+				}
+			}
+			severity := utils.SeverityWarning
+			if newFailing {
+				severity = utils.SeverityCritical
+			}
+			add(severity, "新版本有 %d 个 Pod 未就绪（%s 重启 %d 次%s）", len(failing), rep.Name, podRestarts(rep), waitingSuffix(rep))
+		}
+	}
+
+	issue.Status = yamlSummary(map[string]any{
+		"replicas":        replicas,
+		"strategy":        d.Spec.Strategy,
+		"paused":          d.Spec.Paused,
+		"status":          d.Status,
+		"revision":        revisionOf(d),
+		"minReadySeconds": d.Spec.MinReadySeconds,
+	})
+
+	// 6. 是否回滚
+	switch {
+	case (deadlineExceeded || newFailing) && issue.RollbackRevision > 0:
+		issue.Recommendation = fmt.Sprintf("建议回滚到 revision %d（k8scopilot analyze rollout deployment/%s --rollback 或 kubectl rollout undo deployment/%s --to-revision=%d）",
+			issue.RollbackRevision, d.Name, d.Name, issue.RollbackRevision)
+	case deadlineExceeded || newFailing:
+		issue.RollbackRevision = 0
+		issue.Recommendation = "新版本失败，但没有可回滚的历史版本，需要修复后重新发布"
+	case d.Status.UpdatedReplicas == replicas && d.Status.AvailableReplicas == replicas && d.Status.Replicas == replicas:
+		issue.Recommendation = "滚动更新已完成，无需回滚"
+	default:
+		issue.Recommendation = "滚动更新仍在进行，暂无失败迹象，继续观察"
+	}
+	return issue, nil
+}
+
+func ptrValue[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
+
+// rollingUpdateLimits 按控制器的规则换算 maxSurge（向上取整）和 maxUnavailable（向下取整）
+func rollingUpdateLimits(d *appsv1.Deployment) (int32, int32) {
+	replicas := int(ptrValue(d.Spec.Replicas, 1))
+	defaultValue := intstr.FromString("25%")
+	maxSurge, maxUnavailable := &defaultValue, &defaultValue
+	if ru := d.Spec.Strategy.RollingUpdate; ru != nil {
+		if ru.MaxSurge != nil {
+			maxSurge = ru.MaxSurge
+		}
+		if ru.MaxUnavailable != nil {
+			maxUnavailable = ru.MaxUnavailable
+		}
+	}
+	surge, _ := intstr.GetScaledValueFromIntOrPercent(maxSurge, replicas, true)
+	unavailable, _ := intstr.GetScaledValueFromIntOrPercent(maxUnavailable, replicas, false)
+	// 两者都为 0 时控制器按 maxUnavailable=1 处理
+	if surge == 0 && unavailable == 0 {
+		unavailable = 1
+	}
+	return int32(surge), int32(unavailable)
+}
+
+func templateImages(t *corev1.PodTemplateSpec) []string {
+	var images []string
+	for _, c := range t.Spec.Containers {
+		images = append(images, c.Image)
+	}
+	return images
+}
+
+// templateChanges 列出两个版本之间容器的变化，镜像给出具体值，其它字段只说明有变化
+func templateChanges(old, cur *corev1.PodTemplateSpec) []string {
+	var changes []string
+	oldContainers := map[string]corev1.Container{}
+	for _, c := range old.Spec.Containers {
+		oldContainers[c.Name] = c
+	}
+	for _, c := range cur.Spec.Containers {
+		o, ok := oldContainers[c.Name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("新增容器 %s（%s）", c.Name, c.Image))
+			continue
+		}
+		delete(oldContainers, c.Name)
+		if o.Image != c.Image {
+			changes = append(changes, fmt.Sprintf("容器 %s 镜像 %s → %s", c.Name, o.Image, c.Image))
+		}
+		var fields []string
+		for field, changed := range map[string]bool{
+			"command":         !equality.Semantic.DeepEqual(o.Command, c.Command) || !equality.Semantic.DeepEqual(o.Args, c.Args),
+			"env":             !equality.Semantic.DeepEqual(o.Env, c.Env) || !equality.Semantic.DeepEqual(o.EnvFrom, c.EnvFrom),
+			"resources":       !equality.Semantic.DeepEqual(o.Resources, c.Resources),
+			"ports":           !equality.Semantic.DeepEqual(o.Ports, c.Ports),
+			"probes":          !equality.Semantic.DeepEqual(o.ReadinessProbe, c.ReadinessProbe) || !equality.Semantic.DeepEqual(o.LivenessProbe, c.LivenessProbe) || !equality.Semantic.DeepEqual(o.StartupProbe, c.StartupProbe),
+			"volumeMounts":    !equality.Semantic.DeepEqual(o.VolumeMounts, c.VolumeMounts),
+			"securityContext": !equality.Semantic.DeepEqual(o.SecurityContext, c.SecurityContext),
+		} {
+			if changed {
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) > 0 {
+			sort.Strings(fields)
+			changes = append(changes, fmt.Sprintf("容器 %s 的 %s 有变化", c.Name, strings.Join(fields, "、")))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(oldContainers)) {
+		changes = append(changes, "删除容器 "+name)
+	}
+	if !equality.Semantic.DeepEqual(old.Spec.Volumes, cur.Spec.Volumes) {
+		changes = append(changes, "volumes 有变化")
+	}
+	return changes
+}
+
+func podWaitingReason(pod *corev1.Pod) string {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason != "ContainerCreating" {
+			return cs.State.Waiting.Reason
+		}
+	}
+	return ""
+}
+
+func waitingSuffix(pod *corev1.Pod) string {
+	if reason := podWaitingReason(pod); reason != "" {
+		return "，" + reason
+	}
+	return ""
+}
+
+// podWarningEvents 返回 Pod 折叠后的 Warning 事件，按时间排序
+func podWarningEvents(clientGo *utils.ClientGo, namespace, name string) []string {
+	list, err := utils.ListObjectEvents(context.TODO(), clientGo, "Pod", namespace, name, true)
+	if err != nil {
+		return nil
+	}
+	var events []string
+	for _, e := range list {
+		if scope.recent(e) {
+			events = append(events, e.String())
+		}
+	}
+	return events
+}
+
+// rollbackDeployment 把 Deployment 的 Pod 模板恢复为指定版本，效果同 kubectl rollout undo
+func rollbackDeployment(namespace, name string, revision int64) (string, error) {
+	clientGo, err := newClientGo()
+	if err != nil {
+		return "", err
+	}
+	if err := requireAccess(clientGo, "rollbackDeployment", rollbackAccessChecks(namespace, name)...); err != nil {
+		return "", err
+	}
+	d, err := clientGo.ClientSet.AppsV1().Deployments(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	replicaSets, err := deploymentReplicaSets(clientGo, d)
+	if err != nil {
+		return "", err
+	}
+	var target *appsv1.ReplicaSet
+	for i := range replicaSets {
+		rs := &replicaSets[i]
+		if revision == 0 && revisionOf(rs) < revisionOf(d) || revision != 0 && revisionOf(rs) == revision {
+			target = rs
+			break
+		}
+	}
+	if target == nil {
+		return "", errors.New("找不到要回滚的版本")
+	}
+	if revisionOf(target) == revisionOf(d) {
+		return fmt.Sprintf("%s/%s 已经是 revision %d", namespace, name, revisionOf(target)), nil
+	}
+	if err := captureTypedUndo(utils.UndoUpdate, appsv1.SchemeGroupVersion.WithResource("deployments"), "Deployment", d); err != nil {
+		return "", err
+	}
+	template := target.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	pendingDryRun = fmt.Sprintf("把 %s/%s 从 revision %d（镜像 %s）回滚到 revision %d（镜像 %s）", namespace, name,
+		revisionOf(d), strings.Join(templateImages(&d.Spec.Template), ","), revisionOf(target), strings.Join(templateImages(template), ","))
+	d.Spec.Template = *template
+	update := func(dryRun []string) error {
+		_, err := clientGo.ClientSet.AppsV1().Deployments(namespace).Update(context.TODO(), d, metav1.UpdateOptions{DryRun: dryRun})
+		return err
+	}
+	req := newPolicyRequest("rollbackDeployment", "update", appsv1.SchemeGroupVersion.WithResource("deployments"), namespace, name)
+	if err := enforcePolicy(clientGo, req, update); err != nil {
+		return "", err
+	}
+	if err := saveUndo(); err != nil {
+		return "", err
+	}
+	if err := update(nil); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("已把 %s/%s 回滚到 revision %d（镜像 %s）", namespace, name, revisionOf(target), strings.Join(templateImages(template), ",")), nil
+}
+
+// 回滚需要读取 Deployment 及其 ReplicaSet，并更新 Deployment
+func rollbackAccessChecks(namespace, name string) []accessCheck {
+	deployments := appsv1.SchemeGroupVersion.WithResource("deployments")
+	return []accessCheck{
+		gvrAccess("get", deployments, namespace, name),
+		gvrAccess("update", deployments, namespace, name),
+		gvrAccess("list", appsv1.SchemeGroupVersion.WithResource("replicasets"), namespace, ""),
+	}
+}
+
+// analyzeRollout 把检查结果和新版本 Pod 的证据交给模型
+func analyzeRollout(issue RolloutIssue) (string, error) {
+	model := analysisModel
+	budget := utils.NewPromptBudget(model, appConfig.Budget)
+	data := utils.RolloutAnalysisData{
+		Namespace:      issue.Namespace,
+		Name:           issue.Name,
+		Findings:       findingStrings(issue.Findings),
+		Revisions:      issue.Revisions,
+		Changes:        issue.Changes,
+		Status:         issue.Status,
+		Recommendation: issue.Recommendation,
+		PodName:        issue.Pod.Name,
+	}
+	skeleton, err := promptSet.Render(utils.PromptRolloutAnalysis, data)
+	if err != nil {
+		return "", err
+	}
+	pod := issue.Pod
+	hits := retrieveRunbooks(strings.Join(slices.Concat(data.Findings, issue.Changes, pod.Events), "\n"))
+	past := similarIncidents("Deployment", issue.Namespace, issue.Name, data.Findings)
+	pastLines := pastIncidentLines(model, past)
+	alloc := budget.Allocate(utils.CountTokens(model, skeleton), []utils.BudgetSection{
+		{Name: "events", Need: eventsTokens(model, pod.Events), Weight: 1},
+		{Name: "logs", Need: utils.CountTokens(model, pod.Logs) + strings.Count(pod.Logs, "\n") + 1, Weight: 3},
+		{Name: "spec", Need: utils.CountTokens(model, pod.Spec), Weight: 1},
+		{Name: "runbooks", Need: eventsTokens(model, runbookExcerpts(model, hits)), Weight: 2},
+		{Name: "incidents", Need: eventsTokens(model, pastLines), Weight: 2},
+	})
+	data.Events = limitEvents(model, pod.Events, alloc["events"])
+	data.Logs = strings.TrimRight(utils.CondenseLogs(model, pod.Logs, alloc["logs"]), "\n")
+	data.Spec = strings.TrimRight(utils.TruncateToTokens(model, pod.Spec, alloc["spec"], false), "\n")
+	data.Runbooks, hits = fitRunbooks(model, hits, alloc["runbooks"])
+	data.PastIncidents = limitEvents(model, pastLines, alloc["incidents"])
+
+	result, err := analyzeWithLLM("analyzeRollout", utils.PromptRolloutAnalysis, data, budget.ResponseTokens)
+	if err != nil {
+		return "", err
+	}
+	return withPastIncidents(citeRunbooks(result, hits), past), nil
+}
+
+func init() {
+	analyzeCmd.AddCommand(rolloutCmd)
+	rolloutCmd.Flags().BoolVar(&rolloutRollback, "rollback", false, "roll back after confirmation (to the recommended or --to-revision revision)")
+	rolloutCmd.Flags().Int64Var(&rolloutToRevision, "to-revision", 0, "revision to roll back to (default: the previous revision)")
+}
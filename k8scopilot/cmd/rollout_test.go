@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestRollingUpdateLimits(t *testing.T) {
+	intOrPercent := func(s string) *intstr.IntOrString {
+		v := intstr.Parse(s)
+		return &v
+	}
+	tests := []struct {
+		name               string
+		replicas           *int32
+		strategy           *appsv1.RollingUpdateDeployment
+		surge, unavailable int32
+	}{
+		{"默认 25%，surge 向上取整、unavailable 向下取整", ptr(int32(10)), nil, 3, 2},
+		{"未设置副本数时按 1 计算", nil, nil, 1, 0},
+		{"固定数值", ptr(int32(4)), &appsv1.RollingUpdateDeployment{MaxSurge: intOrPercent("2"), MaxUnavailable: intOrPercent("0")}, 2, 0},
+		{"百分比", ptr(int32(3)), &appsv1.RollingUpdateDeployment{MaxSurge: intOrPercent("50%"), MaxUnavailable: intOrPercent("50%")}, 2, 1},
+		{"只设置 maxSurge 时 maxUnavailable 取默认值", ptr(int32(8)), &appsv1.RollingUpdateDeployment{MaxSurge: intOrPercent("0")}, 0, 2},
+		{"两者都为 0 时按 maxUnavailable=1 处理", ptr(int32(2)), &appsv1.RollingUpdateDeployment{MaxSurge: intOrPercent("0%"), MaxUnavailable: intOrPercent("0%")}, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{
+				Replicas: tt.replicas,
+				Strategy: appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType, RollingUpdate: tt.strategy},
+			}}
+			surge, unavailable := rollingUpdateLimits(d)
+			if surge != tt.surge || unavailable != tt.unavailable {
+				t.Errorf("maxSurge=%d maxUnavailable=%d，期望 %d %d", surge, unavailable, tt.surge, tt.unavailable)
+			}
+		})
+	}
+}
+
+func TestTemplateChanges(t *testing.T) {
+	template := func(containers ...corev1.Container) *corev1.PodTemplateSpec {
+		return &corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: containers}}
+	}
+	web := corev1.Container{Name: "web", Image: "nginx:1.25"}
+	tests := []struct {
+		name     string
+		old, cur *corev1.PodTemplateSpec
+		want     []string
+	}{
+		{"没有变化", template(web), template(web), nil},
+		{
+			"镜像和其它字段",
+			template(web),
+			template(corev1.Container{
+				Name: "web", Image: "nginx:1.26",
+				Env:       []corev1.EnvVar{{Name: "DB_HOST", Value: "db2"}},
+				Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("256Mi")}},
+			}),
+			[]string{"容器 web 镜像 nginx:1.25 → nginx:1.26", "容器 web 的 env、resources 有变化"},
+		},
+		{
+			"新增和删除的容器按名字排序",
+			template(web, corev1.Container{Name: "sidecar-b"}, corev1.Container{Name: "sidecar-a"}),
+			template(web, corev1.Container{Name: "proxy", Image: "envoy:1.31"}),
+			[]string{"新增容器 proxy（envoy:1.31）", "删除容器 sidecar-a", "删除容器 sidecar-b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := templateChanges(tt.old, tt.cur)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -22,9 +22,11 @@ THE SOFTWARE.
 package cmd
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -40,6 +42,9 @@ var rootCmd = &cobra.Command{
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		printUsageSummary()
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -53,13 +58,24 @@ func Execute() {
 
 var kubeconfig string
 var namespace string
+var cfgFile string
+var lang string
+var noCache bool
+
+// appConfig 是从配置文件加载的全局配置
+var appConfig = &utils.Config{}
+
+// promptSet 是当前使用的提示词模板
+var promptSet = utils.NewPromptSet(utils.PromptConfig{})
 
 func init() {
+	cobra.OnInitialize(initConfig)
+
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.k8scopilot.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", utils.DefaultConfigPath(), "config file")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -68,4 +84,20 @@ func init() {
 	defaultKubeconfig := filepath.Join(homeDir, ".kube", "config")
 	rootCmd.PersistentFlags().StringVarP(&kubeconfig, "kubeconfig", "k", defaultKubeconfig, "path to the kubeconfig file")
 	rootCmd.PersistentFlags().StringVarP(&namespace, "namespace", "n", "default", "The namespace to use")
+	rootCmd.PersistentFlags().StringVar(&lang, "lang", "", "prompt language (zh or en), overrides the config file")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "do not read or write the LLM response cache")
+}
+
+// initConfig 读取配置文件
+func initConfig() {
+	cfg, err := utils.LoadConfig(cfgFile)
+	if err != nil {
+		fmt.Println("读取配置文件失败:", err)
+		os.Exit(1)
+	}
+	appConfig = cfg
+	if lang != "" {
+		appConfig.Prompts.Lang = lang
+	}
+	promptSet = utils.NewPromptSet(appConfig.Prompts)
 }
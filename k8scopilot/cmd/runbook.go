@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+)
+
+// runbookCmd 管理团队的 Markdown 运维手册索引
+var runbookCmd = &cobra.Command{
+	Use:   "runbook",
+	Short: "索引和检索配置文件中 runbooks.dir 指定的运维手册",
+}
+
+var runbookIndexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "读取运维手册，配置了 embedding 模型时计算并缓存各章节的向量",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !loadRunbooks() {
+			return
+		}
+		files := map[string]bool{}
+		for _, s := range runbooks.Sections {
+			files[s.File] = true
+		}
+		fmt.Printf("共 %d 个文件、%d 个章节\n", len(files), len(runbooks.Sections))
+		if !runbooks.UsesEmbeddings() {
+			fmt.Println("未配置 runbooks.embeddingModel，只使用 BM25 检索")
+			return
+		}
+		client, err := newLLMClient()
+		if err != nil {
+			fmt.Println("创建模型客户端失败:", err)
+			return
+		}
+		n, err := runbooks.EmbedSections(context.TODO(), embedder(client))
+		if err != nil {
+			fmt.Printf("计算向量失败（已完成 %d 个章节）: %v\n", n, err)
+			return
+		}
+		fmt.Printf("新计算了 %d 个章节的向量\n", n)
+	},
+}
+
+var runbookSearchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "检索与描述最相关的运维手册章节，用于检查分析时会引用哪些内容",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !loadRunbooks() {
+			return
+		}
+		hits := retrieveRunbooks(strings.Join(args, " "))
+		if len(hits) == 0 {
+			fmt.Println("没有找到相关章节")
+			return
+		}
+		for i, hit := range hits {
+			fmt.Printf("[R%d] %s (%.3f)\n", i+1, hit.Section.Ref(), hit.Score)
+		}
+	},
+}
+
+func loadRunbooks() bool {
+	if err := initSession(); err != nil {
+		fmt.Println("读取运维手册失败:", err)
+		return false
+	}
+	if runbooks == nil {
+		fmt.Println("未配置运维手册目录，请在配置文件中设置 runbooks.dir")
+		return false
+	}
+	return true
+}
+
+func embedder(client *utils.OpenAI) utils.Embedder {
+	return func(ctx context.Context, inputs []string) ([][]float32, error) {
+		return client.CreateEmbeddings(utils.WithOperation(ctx, "embedRunbooks"), appConfig.Runbooks.EmbeddingModel, inputs)
+	}
+}
+
+// retrieveRunbooks 检索与问题相关的运维手册章节，未配置手册时返回空
+// 向量检索失败时退回到只使用 BM25，不影响分析
+func retrieveRunbooks(query string) []utils.RunbookHit {
+	if err := initSession(); err != nil || runbooks == nil {
+		return nil
+	}
+	var queryVector []float32
+	if runbooks.UsesEmbeddings() {
+		if client, err := newLLMClient(); err == nil {
+			embed := embedder(client)
+			_, err = runbooks.EmbedSections(context.TODO(), embed)
+			var vectors [][]float32
+			if err == nil {
+				vectors, err = embed(context.TODO(), []string{query})
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "向量检索失败，只使用 BM25:", err)
+			} else {
+				queryVector = vectors[0]
+			}
+		}
+	}
+	return runbooks.Search(query, queryVector, runbooks.TopK())
+}
+
+// 每个章节放入提示词的最大 token 数
+const maxRunbookSectionTokens = 600
+
+// runbookExcerpts 把检索结果整理成带编号的摘录，编号从 R1 开始
+func runbookExcerpts(model string, hits []utils.RunbookHit) []string {
+	excerpts := make([]string, len(hits))
+	for i, hit := range hits {
+		text := strings.TrimRight(utils.TruncateToTokens(model, hit.Section.Text, maxRunbookSectionTokens, false), "\n")
+		excerpts[i] = fmt.Sprintf("[R%d] %s\n%s", i+1, hit.Section.Ref(), text)
+	}
+	return excerpts
+}
+
+// fitRunbooks 按相关度保留预算内放得下的章节，返回摘录和对应的检索结果
+func fitRunbooks(model string, hits []utils.RunbookHit, maxTokens int) ([]string, []utils.RunbookHit) {
+	excerpts := runbookExcerpts(model, hits)
+	used := 0
+	for i, e := range excerpts {
+		used += utils.CountTokens(model, e) + 2
+		if used > maxTokens {
+			return excerpts[:i], hits[:i]
+		}
+	}
+	return excerpts, hits
+}
+
+var runbookCitation = regexp.MustCompile(`\[R(\d+)\]`)
+
+// citeRunbooks 在分析结果后列出引用的运维手册章节；模型没有引用时列出提供给它的全部章节
+func citeRunbooks(result string, hits []utils.RunbookHit) string {
+	if len(hits) == 0 {
+		return result
+	}
+	cited := map[int]bool{}
+	for _, m := range runbookCitation.FindAllStringSubmatch(result, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && n >= 1 && n <= len(hits) {
+			cited[n] = true
+		}
+	}
+	title := "引用的运维手册："
+	if len(cited) == 0 {
+		title = "相关的运维手册："
+	}
+	var b strings.Builder
+	b.WriteString(strings.TrimRight(result, "\n") + "\n\n" + title)
+	for i, hit := range hits {
+		if len(cited) == 0 || cited[i+1] {
+			fmt.Fprintf(&b, "\n[R%d] %s", i+1, hit.Section.Ref())
+		}
+	}
+	return b.String()
+}
+
+func init() {
+	rootCmd.AddCommand(runbookCmd)
+	runbookCmd.AddCommand(runbookIndexCmd, runbookSearchCmd)
+}
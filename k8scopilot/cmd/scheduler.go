@@ -0,0 +1,470 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// 调度谓词名称，与 kube-scheduler 的插件名保持一致，方便对照官方文档
+const (
+	predicateUnschedulable  = "NodeUnschedulable"
+	predicateNodeAffinity   = "NodeAffinity"
+	predicateTaint          = "TaintToleration"
+	predicateResources      = "NodeResourcesFit"
+	predicatePorts          = "NodePorts"
+	predicateInterPod       = "InterPodAffinity"
+	predicateTopologySpread = "PodTopologySpread"
+	predicateNodeNotReady   = "NodeReady"
+)
+
+const missingTopologyKey = "节点缺少拓扑标签 %s"
+
+// nodeVerdict 是某个节点对 Pod 的调度判断，Reasons 为空表示可以调度
+type nodeVerdict struct {
+	Node    string
+	Reasons []predicateFailure
+}
+
+type predicateFailure struct {
+	Predicate string
+	Message   string
+}
+
+func (v nodeVerdict) String() string {
+	if len(v.Reasons) == 0 {
+		return v.Node + ": ✓ 可调度"
+	}
+	parts := make([]string, 0, len(v.Reasons))
+	for _, r := range v.Reasons {
+		parts = append(parts, r.Predicate+": "+r.Message)
+	}
+	return v.Node + ": ✗ " + strings.Join(parts, "; ")
+}
+
+// schedulerSim 在本地按 kube-scheduler 的过滤规则逐个节点检查 Pod，
+// 只覆盖常见的过滤插件，不考虑打分和抢占
+type schedulerSim struct {
+	nodes []corev1.Node
+	// 已绑定到节点且未结束的 Pod
+	podsByNode map[string][]corev1.Pod
+}
+
+func newSchedulerSim(nodes []corev1.Node, pods []corev1.Pod) *schedulerSim {
+	s := &schedulerSim{nodes: nodes, podsByNode: map[string][]corev1.Pod{}}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		s.podsByNode[pod.Spec.NodeName] = append(s.podsByNode[pod.Spec.NodeName], pod)
+	}
+	return s
+}
+
+// explain 返回每个节点的判断结果，顺序与节点列表一致
+func (s *schedulerSim) explain(pod *corev1.Pod) []nodeVerdict {
+	verdicts := make([]nodeVerdict, 0, len(s.nodes))
+	for i := range s.nodes {
+		node := &s.nodes[i]
+		v := nodeVerdict{Node: node.Name}
+		add := func(predicate, format string, args ...any) {
+			v.Reasons = append(v.Reasons, predicateFailure{predicate, fmt.Sprintf(format, args...)})
+		}
+
+		if !nodeReady(node) {
+			add(predicateNodeNotReady, "节点 NotReady")
+		}
+		if node.Spec.Unschedulable && !tolerates(pod.Spec.Tolerations, corev1.Taint{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule}) {
+			add(predicateUnschedulable, "节点已被 cordon")
+		}
+		if msg := s.checkNodeAffinity(pod, node); msg != "" {
+			add(predicateNodeAffinity, "%s", msg)
+		}
+		for _, taint := range node.Spec.Taints {
+			if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+				continue
+			}
+			if !tolerates(pod.Spec.Tolerations, taint) {
+				add(predicateTaint, "未容忍污点 %s", taint.ToString())
+			}
+		}
+		for _, msg := range s.checkResources(pod, node) {
+			add(predicateResources, "%s", msg)
+		}
+		for _, msg := range s.checkPorts(pod, node) {
+			add(predicatePorts, "%s", msg)
+		}
+		for _, msg := range s.checkInterPodAffinity(pod, node) {
+			add(predicateInterPod, "%s", msg)
+		}
+		for _, msg := range s.checkTopologySpread(pod, node) {
+			add(predicateTopologySpread, "%s", msg)
+		}
+		verdicts = append(verdicts, v)
+	}
+	return verdicts
+}
+
+// summarizeVerdicts 生成类似调度器 FailedScheduling 的汇总，例如 0/3 个节点可用：NodeResourcesFit 2 个...
+func summarizeVerdicts(verdicts []nodeVerdict) string {
+	fit := 0
+	counts := map[string]int{}
+	for _, v := range verdicts {
+		if len(v.Reasons) == 0 {
+			fit++
+			continue
+		}
+		seen := map[string]bool{}
+		for _, r := range v.Reasons {
+			if !seen[r.Predicate] {
+				seen[r.Predicate] = true
+				counts[r.Predicate]++
+			}
+		}
+	}
+	predicates := make([]string, 0, len(counts))
+	for p := range counts {
+		predicates = append(predicates, p)
+	}
+	sort.Slice(predicates, func(i, j int) bool {
+		if counts[predicates[i]] != counts[predicates[j]] {
+			return counts[predicates[i]] > counts[predicates[j]]
+		}
+		return predicates[i] < predicates[j]
+	})
+	parts := make([]string, 0, len(predicates))
+	for _, p := range predicates {
+		parts = append(parts, fmt.Sprintf("%s %d 个", p, counts[p]))
+	}
+	summary := fmt.Sprintf("%d/%d 个节点可用", fit, len(verdicts))
+	if len(parts) > 0 {
+		summary += "，被排除的原因：" + strings.Join(parts, "，")
+	}
+	return summary
+}
+
+func nodeReady(node *corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func tolerates(tolerations []corev1.Toleration, taint corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(&taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeSelector 和 requiredDuringScheduling 节点亲和性
+func (s *schedulerSim) checkNodeAffinity(pod *corev1.Pod, node *corev1.Node) string {
+	for k, v := range pod.Spec.NodeSelector {
+		if actual, ok := node.Labels[k]; !ok || actual != v {
+			return fmt.Sprintf("不满足 nodeSelector %s=%s", k, v)
+		}
+	}
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if !nodeMatchesTerms(node, terms) {
+		return "不满足 requiredDuringScheduling 节点亲和性"
+	}
+	return ""
+}
+
+// 多个 term 之间为或，term 内的表达式为与
+func nodeMatchesTerms(node *corev1.Node, terms []corev1.NodeSelectorTerm) bool {
+	for _, term := range terms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		matched := true
+		for _, req := range term.MatchExpressions {
+			if !nodeRequirementMatches(req, node.Labels) {
+				matched = false
+				break
+			}
+		}
+		for _, req := range term.MatchFields {
+			// 目前 matchFields 只支持 metadata.name
+			if req.Key == metav1.ObjectNameField && !nodeRequirementMatches(req, map[string]string{req.Key: node.Name}) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeRequirementMatches(req corev1.NodeSelectorRequirement, nodeLabels map[string]string) bool {
+	value, ok := nodeLabels[req.Key]
+	switch req.Operator {
+	case corev1.NodeSelectorOpIn:
+		return ok && slices.Contains(req.Values, value)
+	case corev1.NodeSelectorOpNotIn:
+		return !ok || !slices.Contains(req.Values, value)
+	case corev1.NodeSelectorOpExists:
+		return ok
+	case corev1.NodeSelectorOpDoesNotExist:
+		return !ok
+	case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+		if !ok || len(req.Values) != 1 {
+			return false
+		}
+		actual, err1 := strconv.ParseInt(value, 10, 64)
+		expected, err2 := strconv.ParseInt(req.Values[0], 10, 64)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		if req.Operator == corev1.NodeSelectorOpGt {
+			return actual > expected
+		}
+		return actual < expected
+	}
+	return false
+}
+
+// 节点剩余可分配资源是否满足 Pod 的请求
+func (s *schedulerSim) checkResources(pod *corev1.Pod, node *corev1.Node) []string {
+	var msgs []string
+	existing := s.podsByNode[node.Name]
+	if allocatable, ok := node.Status.Allocatable[corev1.ResourcePods]; ok && int64(len(existing)+1) > allocatable.Value() {
+		msgs = append(msgs, fmt.Sprintf("Pod 数量已达上限 %s", allocatable.String()))
+	}
+	used := corev1.ResourceList{}
+	for _, p := range existing {
+		addResourceList(used, podRequests(&p))
+	}
+	requests := podRequests(pod)
+	names := make([]string, 0, len(requests))
+	for name := range requests {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		name := corev1.ResourceName(n)
+		req := requests[name]
+		if req.IsZero() {
+			continue
+		}
+		allocatable := node.Status.Allocatable[name]
+		free := allocatable.DeepCopy()
+		free.Sub(used[name])
+		if req.Cmp(free) > 0 {
+			inUse := used[name]
+			msgs = append(msgs, fmt.Sprintf("Insufficient %s（请求 %s，可分配 %s，已请求 %s）", name, req.String(), allocatable.String(), inUse.String()))
+		}
+	}
+	return msgs
+}
+
+func (s *schedulerSim) checkPorts(pod *corev1.Pod, node *corev1.Node) []string {
+	used := map[string]bool{}
+	for _, p := range s.podsByNode[node.Name] {
+		for _, port := range hostPorts(&p) {
+			used[port] = true
+		}
+	}
+	var msgs []string
+	for _, port := range hostPorts(pod) {
+		if used[port] {
+			msgs = append(msgs, "hostPort "+port+" 已被占用")
+		}
+	}
+	return msgs
+}
+
+func hostPorts(pod *corev1.Pod) []string {
+	var ports []string
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.HostPort == 0 {
+				continue
+			}
+			protocol := p.Protocol
+			if protocol == "" {
+				protocol = corev1.ProtocolTCP
+			}
+			ports = append(ports, fmt.Sprintf("%d/%s", p.HostPort, protocol))
+		}
+	}
+	return ports
+}
+
+// requiredDuringScheduling 的 Pod 亲和、反亲和，以及已有 Pod 的反亲和对新 Pod 的约束
+func (s *schedulerSim) checkInterPodAffinity(pod *corev1.Pod, node *corev1.Node) []string {
+	var msgs []string
+	affinity := pod.Spec.Affinity
+	if affinity != nil && affinity.PodAffinity != nil {
+		for _, term := range affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			value, ok := node.Labels[term.TopologyKey]
+			if !ok {
+				msgs = append(msgs, fmt.Sprintf(missingTopologyKey, term.TopologyKey))
+				continue
+			}
+			matches := s.podsMatchingTerm(pod.Namespace, term)
+			// 集群中没有任何匹配的 Pod 且 Pod 自身满足选择器时允许调度（第一个副本）
+			if len(matches) == 0 && podMatchesTerm(pod, pod.Namespace, term) {
+				continue
+			}
+			if !s.anyInTopology(matches, term.TopologyKey, value) {
+				msgs = append(msgs, fmt.Sprintf("同一 %s=%s 中没有满足 podAffinity %s 的 Pod", term.TopologyKey, value, selectorString(term.LabelSelector)))
+			}
+		}
+	}
+	if affinity != nil && affinity.PodAntiAffinity != nil {
+		for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			value, ok := node.Labels[term.TopologyKey]
+			if !ok {
+				continue
+			}
+			if s.anyInTopology(s.podsMatchingTerm(pod.Namespace, term), term.TopologyKey, value) {
+				msgs = append(msgs, fmt.Sprintf("同一 %s=%s 中已有满足 podAntiAffinity %s 的 Pod", term.TopologyKey, value, selectorString(term.LabelSelector)))
+			}
+		}
+	}
+	// 已有 Pod 声明的反亲和，按节点列表的顺序检查，输出稳定
+	for i := range s.nodes {
+		other := &s.nodes[i]
+		for _, existing := range s.podsByNode[other.Name] {
+			if existing.Spec.Affinity == nil || existing.Spec.Affinity.PodAntiAffinity == nil {
+				continue
+			}
+			for _, term := range existing.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+				value, ok := other.Labels[term.TopologyKey]
+				if !ok || node.Labels[term.TopologyKey] != value || !podMatchesTerm(pod, existing.Namespace, term) {
+					continue
+				}
+				msgs = append(msgs, fmt.Sprintf("与已有 Pod %s/%s 的 podAntiAffinity 冲突", existing.Namespace, existing.Name))
+			}
+		}
+	}
+	return msgs
+}
+
+func (s *schedulerSim) node(name string) *corev1.Node {
+	for i := range s.nodes {
+		if s.nodes[i].Name == name {
+			return &s.nodes[i]
+		}
+	}
+	return nil
+}
+
+func (s *schedulerSim) podsMatchingTerm(namespace string, term corev1.PodAffinityTerm) []corev1.Pod {
+	var matches []corev1.Pod
+	for _, pods := range s.podsByNode {
+		for _, p := range pods {
+			if podMatchesTerm(&p, namespace, term) {
+				matches = append(matches, p)
+			}
+		}
+	}
+	return matches
+}
+
+func (s *schedulerSim) anyInTopology(pods []corev1.Pod, key, value string) bool {
+	for _, p := range pods {
+		if n := s.node(p.Spec.NodeName); n != nil && n.Labels[key] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// podMatchesTerm 判断 pod 是否满足 term，ownerNamespace 为声明该 term 的 Pod 所在命名空间
+// namespaceSelector 只处理空选择器（表示全部命名空间）
+func podMatchesTerm(pod *corev1.Pod, ownerNamespace string, term corev1.PodAffinityTerm) bool {
+	switch {
+	case len(term.Namespaces) > 0:
+		if !slices.Contains(term.Namespaces, pod.Namespace) {
+			return false
+		}
+	case term.NamespaceSelector != nil && len(term.NamespaceSelector.MatchLabels) == 0 && len(term.NamespaceSelector.MatchExpressions) == 0:
+	default:
+		if pod.Namespace != ownerNamespace {
+			return false
+		}
+	}
+	selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(pod.Labels))
+}
+
+func selectorString(selector *metav1.LabelSelector) string {
+	if selector == nil {
+		return "<nil>"
+	}
+	return metav1.FormatLabelSelector(selector)
+}
+
+// whenUnsatisfiable=DoNotSchedule 的拓扑分布约束
+func (s *schedulerSim) checkTopologySpread(pod *corev1.Pod, node *corev1.Node) []string {
+	var msgs []string
+	for _, c := range pod.Spec.TopologySpreadConstraints {
+		if c.WhenUnsatisfiable != corev1.DoNotSchedule {
+			continue
+		}
+		value, ok := node.Labels[c.TopologyKey]
+		if !ok {
+			msgs = append(msgs, fmt.Sprintf(missingTopologyKey, c.TopologyKey))
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(c.LabelSelector)
+		if err != nil {
+			continue
+		}
+		// 只统计满足 Pod 节点亲和性的节点所在的拓扑域（nodeAffinityPolicy 默认为 Honor）
+		counts := map[string]int{}
+		for i := range s.nodes {
+			n := &s.nodes[i]
+			v, ok := n.Labels[c.TopologyKey]
+			if !ok || s.checkNodeAffinity(pod, n) != "" {
+				continue
+			}
+			if _, seen := counts[v]; !seen {
+				counts[v] = 0
+			}
+			for _, p := range s.podsByNode[n.Name] {
+				if p.Namespace == pod.Namespace && selector.Matches(labels.Set(p.Labels)) {
+					counts[v]++
+				}
+			}
+		}
+		minCount := -1
+		for _, n := range counts {
+			if minCount < 0 || n < minCount {
+				minCount = n
+			}
+		}
+		if minCount < 0 || (c.MinDomains != nil && int32(len(counts)) < *c.MinDomains) {
+			minCount = 0
+		}
+		self := 0
+		if selector.Matches(labels.Set(pod.Labels)) {
+			self = 1
+		}
+		if skew := counts[value] + self - minCount; skew > int(c.MaxSkew) {
+			msgs = append(msgs, fmt.Sprintf("调度到 %s=%s 后偏差为 %d，超过 maxSkew %d", c.TopologyKey, value, skew, c.MaxSkew))
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testNode(name string, labels map[string]string, mutate func(*corev1.Node)) corev1.Node {
+	node := corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("4Gi"),
+				corev1.ResourcePods:   resource.MustParse("110"),
+			},
+		},
+	}
+	if mutate != nil {
+		mutate(&node)
+	}
+	return node
+}
+
+func testPod(name, nodeName string, labels map[string]string, cpu string, mutate func(*corev1.Pod)) corev1.Pod {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop", Labels: labels},
+		Spec: corev1.PodSpec{
+			NodeName: nodeName,
+			Containers: []corev1.Container{{
+				Name: "app",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse(cpu)},
+				},
+			}},
+		},
+	}
+	if mutate != nil {
+		mutate(&pod)
+	}
+	return pod
+}
+
+func antiAffinity(app, topologyKey string) *corev1.Affinity {
+	return &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+			LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+			TopologyKey:   topologyKey,
+		}},
+	}}
+}
+
+func TestSchedulerSimExplain(t *testing.T) {
+	zoneA := map[string]string{"topology.kubernetes.io/zone": "a", "disk": "ssd"}
+	tests := []struct {
+		name string
+		node corev1.Node
+		pods []corev1.Pod
+		pod  corev1.Pod
+		want []string
+	}{
+		{
+			name: "可以调度",
+			node: testNode("node-1", zoneA, nil),
+			pod:  testPod("web", "", nil, "500m", nil),
+		},
+		{
+			name: "节点 NotReady",
+			node: testNode("node-1", zoneA, func(n *corev1.Node) { n.Status.Conditions[0].Status = corev1.ConditionFalse }),
+			pod:  testPod("web", "", nil, "500m", nil),
+			want: []string{predicateNodeNotReady},
+		},
+		{
+			name: "节点已被 cordon",
+			node: testNode("node-1", zoneA, func(n *corev1.Node) { n.Spec.Unschedulable = true }),
+			pod:  testPod("web", "", nil, "500m", nil),
+			want: []string{predicateUnschedulable},
+		},
+		{
+			name: "未容忍的污点",
+			node: testNode("node-1", zoneA, func(n *corev1.Node) {
+				n.Spec.Taints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+			}),
+			pod:  testPod("web", "", nil, "500m", nil),
+			want: []string{predicateTaint},
+		},
+		{
+			name: "容忍污点，PreferNoSchedule 不影响",
+			node: testNode("node-1", zoneA, func(n *corev1.Node) {
+				n.Spec.Taints = []corev1.Taint{
+					{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule},
+				}
+			}),
+			pod: testPod("web", "", nil, "500m", func(p *corev1.Pod) {
+				p.Spec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+			}),
+		},
+		{
+			name: "CPU 不足，已有 Pod 的请求计入",
+			node: testNode("node-1", zoneA, nil),
+			pods: []corev1.Pod{testPod("db", "node-1", nil, "1500m", nil)},
+			pod:  testPod("web", "", nil, "1", nil),
+			want: []string{predicateResources},
+		},
+		{
+			name: "已结束的 Pod 不占资源",
+			node: testNode("node-1", zoneA, nil),
+			pods: []corev1.Pod{testPod("job", "node-1", nil, "1500m", func(p *corev1.Pod) { p.Status.Phase = corev1.PodSucceeded })},
+			pod:  testPod("web", "", nil, "1", nil),
+		},
+		{
+			name: "不满足 nodeSelector",
+			node: testNode("node-1", zoneA, nil),
+			pod:  testPod("web", "", nil, "500m", func(p *corev1.Pod) { p.Spec.NodeSelector = map[string]string{"disk": "hdd"} }),
+			want: []string{predicateNodeAffinity},
+		},
+		{
+			name: "满足 requiredDuringScheduling 节点亲和性",
+			node: testNode("node-1", zoneA, nil),
+			pod: testPod("web", "", nil, "500m", func(p *corev1.Pod) {
+				p.Spec.Affinity = &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{
+						{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "disk", Operator: corev1.NodeSelectorOpIn, Values: []string{"nvme"}}}},
+						{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "disk", Operator: corev1.NodeSelectorOpExists}}},
+					}},
+				}}
+			}),
+		},
+		{
+			name: "hostPort 冲突",
+			node: testNode("node-1", zoneA, nil),
+			pods: []corev1.Pod{testPod("proxy", "node-1", nil, "100m", func(p *corev1.Pod) {
+				p.Spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 80, HostPort: 80}}
+			})},
+			pod: testPod("web", "", nil, "100m", func(p *corev1.Pod) {
+				p.Spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 8080, HostPort: 80, Protocol: corev1.ProtocolTCP}}
+			}),
+			want: []string{predicatePorts},
+		},
+		{
+			name: "同一拓扑域已有反亲和的 Pod",
+			node: testNode("node-1", map[string]string{"kubernetes.io/hostname": "node-1"}, nil),
+			pods: []corev1.Pod{testPod("web-1", "node-1", map[string]string{"app": "web"}, "100m", nil)},
+			pod: testPod("web-2", "", map[string]string{"app": "web"}, "100m", func(p *corev1.Pod) {
+				p.Spec.Affinity = antiAffinity("web", "kubernetes.io/hostname")
+			}),
+			want: []string{predicateInterPod},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := newSchedulerSim([]corev1.Node{tt.node}, tt.pods)
+			verdicts := sim.explain(&tt.pod)
+			if len(verdicts) != 1 {
+				t.Fatalf("期望 1 个结果，实际 %d", len(verdicts))
+			}
+			var got []string
+			for _, r := range verdicts[0].Reasons {
+				got = append(got, r.Predicate)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("谓词 %v，期望 %v: %s", got, tt.want, verdicts[0])
+			}
+		})
+	}
+}
+
+func TestSchedulerSimExistingAntiAffinityOrder(t *testing.T) {
+	zone := map[string]string{"topology.kubernetes.io/zone": "a"}
+	nodes := []corev1.Node{
+		testNode("node-1", zone, nil),
+		testNode("node-2", zone, nil),
+		testNode("node-3", zone, nil),
+	}
+	var pods []corev1.Pod
+	for _, n := range []string{"node-3", "node-1", "node-2"} {
+		pods = append(pods, testPod("cache-"+n, n, map[string]string{"app": "cache"}, "100m", func(p *corev1.Pod) {
+			p.Spec.Affinity = antiAffinity("web", "topology.kubernetes.io/zone")
+		}))
+	}
+	pod := testPod("web", "", map[string]string{"app": "web"}, "100m", nil)
+	want := "与已有 Pod shop/cache-node-1 的 podAntiAffinity 冲突; InterPodAffinity: 与已有 Pod shop/cache-node-2 的 podAntiAffinity 冲突; InterPodAffinity: 与已有 Pod shop/cache-node-3 的 podAntiAffinity 冲突"
+	// 多次运行结果相同
+	for i := 0; i < 20; i++ {
+		verdicts := newSchedulerSim(nodes, pods).explain(&pod)
+		if got := verdicts[0].String(); !strings.HasSuffix(got, want) {
+			t.Fatalf("第 %d 次结果不同:\n%s", i, got)
+		}
+	}
+}
+
+func TestSummarizeVerdicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		verdicts []nodeVerdict
+		want     string
+	}{
+		{"全部可用", []nodeVerdict{{Node: "node-1"}}, "1/1 个节点可用"},
+		{
+			"按排除的节点数排序，同一节点的相同谓词只计一次",
+			[]nodeVerdict{
+				{Node: "node-1", Reasons: []predicateFailure{{predicateResources, "Insufficient cpu"}, {predicateResources, "Insufficient memory"}}},
+				{Node: "node-2", Reasons: []predicateFailure{{predicateResources, "Insufficient cpu"}, {predicateTaint, "未容忍污点"}}},
+				{Node: "node-3", Reasons: []predicateFailure{{predicateNodeAffinity, "不满足 nodeSelector"}}},
+				{Node: "node-4"},
+			},
+			"1/4 个节点可用，被排除的原因：NodeResourcesFit 2 个，NodeAffinity 1 个，TaintToleration 1 个",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarizeVerdicts(tt.verdicts); got != tt.want {
+				t.Errorf("got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// analysisScope 决定 analyze 命令检查哪些命名空间、Pod 和事件
+type analysisScope struct {
+	// 为空表示全部命名空间
+	Namespace string
+	Exclude   []string
+	// Pod 标签选择器，为空时匹配所有 Pod
+	Selector labels.Selector
+	// 只保留最后一次发生在该时间之后的事件，为零时不过滤
+	Since time.Time
+}
+
+// scope 由 analyze 的参数生成；report 等其它命令不设置，检查整个集群
+var scope analysisScope
+
+var (
+	allNamespaces     bool
+	podSelector       string
+	excludeNamespaces []string
+	eventsSince       time.Duration
+)
+
+// buildScope 根据命令行参数生成检查范围
+func buildScope() (analysisScope, error) {
+	s := analysisScope{Namespace: namespace, Exclude: excludeNamespaces}
+	if allNamespaces {
+		s.Namespace = metav1.NamespaceAll
+	}
+	if podSelector != "" {
+		selector, err := labels.Parse(podSelector)
+		if err != nil {
+			return s, fmt.Errorf("--selector 无效: %w", err)
+		}
+		s.Selector = selector
+	}
+	if eventsSince > 0 {
+		s.Since = time.Now().Add(-eventsSince)
+	}
+	return s, nil
+}
+
+// includesNamespace 判断命名空间是否在检查范围内
+func (s analysisScope) includesNamespace(ns string) bool {
+	if s.Namespace != "" && ns != s.Namespace {
+		return false
+	}
+	return !slices.Contains(s.Exclude, ns)
+}
+
+// includesPod 判断 Pod 是否在检查范围内
+func (s analysisScope) includesPod(pod *corev1.Pod) bool {
+	if !s.includesNamespace(pod.Namespace) {
+		return false
+	}
+	return s.Selector == nil || s.Selector.Matches(labels.Set(pod.Labels))
+}
+
+// includesTemplate 按 Pod 模板的标签判断工作负载是否在检查范围内
+func (s analysisScope) includesTemplate(ns string, template *corev1.PodTemplateSpec) bool {
+	if !s.includesNamespace(ns) {
+		return false
+	}
+	return s.Selector == nil || s.Selector.Matches(labels.Set(template.Labels))
+}
+
+// podListOptions 把标签选择器交给 apiserver 过滤
+func (s analysisScope) podListOptions() metav1.ListOptions {
+	if s.Selector == nil {
+		return metav1.ListOptions{}
+	}
+	return metav1.ListOptions{LabelSelector: s.Selector.String()}
+}
+
+// recent 判断事件最后一次发生是否在 --since 之后
+func (s analysisScope) recent(e utils.Event) bool {
+	return s.Since.IsZero() || !e.Last.Before(s.Since)
+}
+
+func init() {
+	flags := analyzeCmd.PersistentFlags()
+	flags.BoolVarP(&allNamespaces, "all-namespaces", "A", false, "analyze all namespaces instead of --namespace")
+	flags.StringVarP(&podSelector, "selector", "l", "", "pod label selector, e.g. app=web; workloads are matched by their pod template labels")
+	flags.StringSliceVar(&excludeNamespaces, "exclude-namespace", nil, "skip these namespaces (repeatable or comma-separated)")
+	flags.DurationVar(&eventsSince, "since", 0, "only consider events whose last occurrence is newer than this duration, e.g. 1h")
+}
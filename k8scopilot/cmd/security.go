@@ -0,0 +1,598 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+)
+
+// securityCmd 检查工作负载和 RBAC 的安全配置，按 Pod Security Standards 级别分组
+var securityCmd = &cobra.Command{
+	Use:   "security",
+	Short: "检查特权容器、宿主机命名空间、root 运行、镜像标签、资源限制、Secret 环境变量和过宽的 RBAC 授权",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if securityApply && snapshotFile != "" {
+			fmt.Println("快照是只读的，不能和 --from-snapshot 一起使用 --apply")
+			return
+		}
+		clientGo, err := newClientGo()
+		if err != nil {
+			fmt.Println("连接集群失败:", err)
+			return
+		}
+		issues, err := inspectSecurity(clientGo, scope.Namespace)
+		if err != nil {
+			fmt.Println("获取安全配置失败:", err)
+			return
+		}
+		if len(issues) == 0 {
+			fmt.Println("✅ 未发现安全问题")
+			return
+		}
+
+		for _, level := range securityLevels {
+			printed := false
+			for _, issue := range issues {
+				findings := issue.at(level.Name)
+				if len(findings) == 0 {
+					continue
+				}
+				if !printed {
+					fmt.Printf("\n== %s ==\n", level.Title)
+					printed = true
+				}
+				fmt.Printf("%s %s:\n", issue.Ref.Kind, issue.object())
+				for _, f := range findings {
+					fmt.Println("  " + f.String())
+				}
+			}
+		}
+
+		if !securityFix && !securityApply {
+			return
+		}
+		for _, issue := range issues {
+			// RBAC 绑定和只有提示信息的工作负载不生成补丁
+			if issue.Object == nil || maxSeverity(issue.at(levelBaseline, levelRestricted, levelBestPractice)) == utils.SeverityInfo {
+				continue
+			}
+			fmt.Printf("\n%s %s 修复建议：\n", issue.Ref.Kind, issue.object())
+			result, err := remediateSecurity(issue)
+			if err != nil {
+				fmt.Println("生成修复建议失败:", err)
+				continue
+			}
+			fmt.Println(result.Response)
+			recordIncident(utils.Diagnosis{
+				Kind:          issue.Ref.Kind,
+				Namespace:     issue.Ref.Namespace,
+				Name:          issue.Ref.Name,
+				Severity:      maxSeverity(issue.at(levelBaseline, levelRestricted, levelBestPractice)),
+				Events:        issue.evidence(),
+				Result:        result.Response,
+				PromptVersion: promptSet.Version(utils.PromptSecurityRemediation),
+			})
+			if result.Err != nil {
+				fmt.Println("无法预览补丁:", result.Err)
+				continue
+			}
+			if result.Diff == "" {
+				fmt.Println("补丁不会修改任何字段")
+				continue
+			}
+			fmt.Println("\n补丁预览：")
+			fmt.Print(result.Diff)
+
+			if _, ok := workloadResources[issue.Ref.Kind]; !ok {
+				// 已创建的 Pod 和 Job 的 securityContext、资源和镜像等字段基本不可修改，apiserver 会拒绝补丁
+				fmt.Printf("\n注意：%s 的 Pod 配置创建后不可修改，补丁无法直接应用，请按补丁修改清单后重新创建\n", issue.Ref.Kind)
+				continue
+			}
+			if !securityApply {
+				continue
+			}
+			fmt.Printf("\n确认把补丁应用到 %s %s？(y/N): ", issue.Ref.Kind, issue.object())
+			var answer string
+			_, _ = fmt.Scanln(&answer)
+			record := utils.AuditRecord{
+				Command:   "analyze security",
+				Tool:      "patchWorkload",
+				Arguments: fmt.Sprintf(`{"kind":%q,"namespace":%q,"name":%q,"patch":%s}`, issue.Ref.Kind, issue.Ref.Namespace, issue.Ref.Name, result.Patch),
+				DryRun:    result.Diff,
+				Model:     analysisModel,
+			}
+			if strings.ToLower(answer) != "y" {
+				record.Confirmation, record.Result = "declined", utils.AuditDeclined
+				recordAudit(record)
+				fmt.Println("已跳过")
+				continue
+			}
+			err = patchWorkload(clientGo, issue, result.Patch)
+			record.Confirmation = "confirmed"
+			auditOutcome(&record, "已应用补丁", err)
+			recordAudit(record)
+			if err != nil {
+				fmt.Println("应用补丁失败:", err)
+				continue
+			}
+			fmt.Println("✅ 已应用")
+		}
+		printRedactionReport()
+	},
+}
+
+var (
+	securityFix   bool
+	securityApply bool
+)
+
+// Pod Security Standards 级别，外加 PSS 之外的最佳实践和 RBAC 检查
+const (
+	// 违反 baseline，只有 privileged 级别允许
+	levelBaseline = "baseline"
+	// 满足 baseline 但违反 restricted
+	levelRestricted   = "restricted"
+	levelBestPractice = "best-practice"
+	levelRBAC         = "rbac"
+)
+
+var securityLevels = []struct{ Name, Title string }{
+	{levelBaseline, "违反 Baseline（只有 Privileged 级别允许）"},
+	{levelRestricted, "违反 Restricted"},
+	{levelBestPractice, "最佳实践"},
+	{levelRBAC, "RBAC"},
+}
+
+// securityFinding 是带 PSS 级别的检查结果
+type securityFinding struct {
+	Level string
+	finding
+}
+
+// SecurityIssue 是一个工作负载或 RBAC 绑定的安全检查结果
+type SecurityIssue struct {
+	Ref      workloadRef
+	Findings []securityFinding
+	// 工作负载对象，RBAC 绑定时为空
+	Object runtime.Object
+	// Pod 模板 spec 在对象中的路径，例如 spec.template.spec
+	PodSpecPath string
+	PodSpec     *corev1.PodSpec
+}
+
+func (s SecurityIssue) object() string {
+	if s.Ref.Namespace == "" {
+		return s.Ref.Name
+	}
+	return s.Ref.Namespace + "/" + s.Ref.Name
+}
+
+// evidence 返回记录事故和查找相似事故所用的证据，每条带 PSS 级别
+func (s SecurityIssue) evidence() []string {
+	var out []string
+	for _, f := range s.Findings {
+		out = append(out, fmt.Sprintf("(%s) %s", f.Level, f.String()))
+	}
+	return out
+}
+
+func (s SecurityIssue) at(levels ...string) []finding {
+	var out []finding
+	for _, f := range s.Findings {
+		if slices.Contains(levels, f.Level) {
+			out = append(out, f.finding)
+		}
+	}
+	return out
+}
+
+// 模型回复中的 YAML 代码块
+var yamlBlockPattern = regexp.MustCompile("(?s)```(?:yaml|yml)?\\s*\\n(.*?)```")
+
+// inspectSecurity 检查命名空间中的顶层工作负载，以及授予该命名空间 ServiceAccount 的 RBAC 权限
+func inspectSecurity(clientGo *utils.ClientGo, namespace string) ([]SecurityIssue, error) {
+	targets, err := securityTargets(clientGo, namespace)
+	if err != nil {
+		return nil, err
+	}
+	var issues []SecurityIssue
+	for _, t := range targets {
+		t.Findings = inspectPodSpec(t.PodSpec)
+		if len(t.Findings) > 0 {
+			issues = append(issues, t)
+		}
+	}
+	rbac, err := inspectRBAC(clientGo, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return append(issues, rbac...), nil
+}
+
+// securityTargets 列出命名空间中的顶层工作负载，由控制器管理的 ReplicaSet、Job 和 Pod 不重复检查
+func securityTargets(clientGo *utils.ClientGo, namespace string) ([]SecurityIssue, error) {
+	ctx := context.TODO()
+	var targets []SecurityIssue
+	add := func(obj runtime.Object, meta metav1.ObjectMeta, kind, path string, template *corev1.PodTemplateSpec) {
+		if metav1.GetControllerOfNoCopy(&meta) != nil || !scope.includesTemplate(meta.Namespace, template) {
+			return
+		}
+		targets = append(targets, SecurityIssue{
+			Ref:         workloadRef{Kind: kind, Namespace: meta.Namespace, Name: meta.Name},
+			Object:      obj,
+			PodSpecPath: path,
+			PodSpec:     &template.Spec,
+		})
+	}
+
+	deployments, err := clientGo.ClientSet.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		add(d, d.ObjectMeta, "Deployment", "spec.template.spec", &d.Spec.Template)
+	}
+	statefulSets, err := clientGo.ClientSet.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range statefulSets.Items {
+		s := &statefulSets.Items[i]
+		add(s, s.ObjectMeta, "StatefulSet", "spec.template.spec", &s.Spec.Template)
+	}
+	daemonSets, err := clientGo.ClientSet.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range daemonSets.Items {
+		d := &daemonSets.Items[i]
+		add(d, d.ObjectMeta, "DaemonSet", "spec.template.spec", &d.Spec.Template)
+	}
+	cronJobs, err := clientGo.ClientSet.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range cronJobs.Items {
+		c := &cronJobs.Items[i]
+		add(c, c.ObjectMeta, "CronJob", "spec.jobTemplate.spec.template.spec", &c.Spec.JobTemplate.Spec.Template)
+	}
+	jobs, err := clientGo.ClientSet.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs.Items {
+		j := &jobs.Items[i]
+		add(j, j.ObjectMeta, "Job", "spec.template.spec", &j.Spec.Template)
+	}
+	pods, err := clientGo.ClientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods.Items {
+		p := &pods.Items[i]
+		add(p, p.ObjectMeta, "Pod", "spec", &corev1.PodTemplateSpec{ObjectMeta: p.ObjectMeta, Spec: p.Spec})
+	}
+	return targets, nil
+}
+
+// inspectPodSpec 按 PSS 和常见最佳实践检查 Pod 配置
+func inspectPodSpec(spec *corev1.PodSpec) []securityFinding {
+	var findings []securityFinding
+	add := func(level, severity, format string, args ...any) {
+		findings = append(findings, securityFinding{level, finding{severity, fmt.Sprintf(format, args...)}})
+	}
+
+	if spec.HostNetwork {
+		add(levelBaseline, utils.SeverityCritical, "使用宿主机网络（hostNetwork: true）")
+	}
+	if spec.HostPID {
+		add(levelBaseline, utils.SeverityCritical, "共享宿主机进程命名空间（hostPID: true）")
+	}
+	if spec.HostIPC {
+		add(levelBaseline, utils.SeverityCritical, "共享宿主机 IPC 命名空间（hostIPC: true）")
+	}
+	for _, v := range spec.Volumes {
+		if v.HostPath != nil {
+			add(levelBaseline, utils.SeverityCritical, "卷 %s 挂载了宿主机目录 %s", v.Name, v.HostPath.Path)
+		}
+	}
+
+	podSC := spec.SecurityContext
+	if podSC == nil {
+		podSC = &corev1.PodSecurityContext{}
+	}
+	for _, c := range slices.Concat(spec.InitContainers, spec.Containers) {
+		sc := c.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+		if ptrValue(sc.Privileged, false) {
+			add(levelBaseline, utils.SeverityCritical, "容器 %s 以特权模式运行（privileged: true）", c.Name)
+		}
+
+		// 容器级设置优先于 Pod 级
+		runAsNonRoot := podSC.RunAsNonRoot
+		if sc.RunAsNonRoot != nil {
+			runAsNonRoot = sc.RunAsNonRoot
+		}
+		runAsUser := podSC.RunAsUser
+		if sc.RunAsUser != nil {
+			runAsUser = sc.RunAsUser
+		}
+		if runAsUser != nil && *runAsUser == 0 {
+			add(levelRestricted, utils.SeverityWarning, "容器 %s 以 root 用户运行（runAsUser: 0）", c.Name)
+		} else if !ptrValue(runAsNonRoot, false) {
+			add(levelRestricted, utils.SeverityWarning, "容器 %s 没有设置 runAsNonRoot: true，可能以 root 用户运行", c.Name)
+		}
+
+		if !ptrValue(sc.ReadOnlyRootFilesystem, false) {
+			add(levelBestPractice, utils.SeverityInfo, "容器 %s 的根文件系统可写（readOnlyRootFilesystem 未开启）", c.Name)
+		}
+		if imageUsesLatest(c.Image) {
+			add(levelBestPractice, utils.SeverityWarning, "容器 %s 的镜像 %s 使用 latest 标签或未指定标签", c.Name, c.Image)
+		}
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			if _, ok := c.Resources.Limits[name]; !ok {
+				add(levelBestPractice, utils.SeverityWarning, "容器 %s 没有设置 %s 限制", c.Name, name)
+			}
+		}
+		for _, e := range c.Env {
+			if e.ValueFrom != nil && e.ValueFrom.SecretKeyRef != nil {
+				add(levelBestPractice, utils.SeverityWarning, "容器 %s 通过环境变量 %s 读取 Secret %s，建议改为挂载文件", c.Name, e.Name, e.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+		for _, e := range c.EnvFrom {
+			if e.SecretRef != nil {
+				add(levelBestPractice, utils.SeverityWarning, "容器 %s 通过 envFrom 把 Secret %s 全部导入环境变量，建议改为挂载文件", c.Name, e.SecretRef.Name)
+			}
+		}
+	}
+	return findings
+}
+
+// 没有标签或标签为 latest，且没有指定 digest
+func imageUsesLatest(image string) bool {
+	if strings.Contains(image, "@") {
+		return false
+	}
+	// 去掉镜像仓库地址，避免把端口号当成标签
+	name := image[strings.LastIndex(image, "/")+1:]
+	i := strings.LastIndex(name, ":")
+	return i < 0 || name[i+1:] == "latest"
+}
+
+// inspectRBAC 找出授予命名空间中 ServiceAccount 的 cluster-admin 或通配符权限
+func inspectRBAC(clientGo *utils.ClientGo, namespace string) ([]SecurityIssue, error) {
+	ctx := context.TODO()
+	clusterRoles, err := clientGo.ClientSet.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	wildcard := map[string]bool{}
+	for _, role := range clusterRoles.Items {
+		if rulesWildcard(role.Rules) {
+			wildcard[role.Name] = true
+		}
+	}
+
+	var issues []SecurityIssue
+	check := func(kind, bindingNamespace, name string, roleRef rbacv1.RoleRef, subjects []rbacv1.Subject) {
+		if roleRef.Kind != "ClusterRole" || roleRef.Name != "cluster-admin" && !wildcard[roleRef.Name] {
+			return
+		}
+		// RoleBinding 只在自己的命名空间内生效
+		reach := "整个集群"
+		severity := utils.SeverityCritical
+		if kind == "RoleBinding" {
+			reach = "仅命名空间 " + bindingNamespace
+			severity = utils.SeverityWarning
+		}
+		issue := SecurityIssue{Ref: workloadRef{Kind: kind, Namespace: bindingNamespace, Name: name}}
+		for _, s := range subjects {
+			var subject string
+			switch {
+			case s.Kind == rbacv1.ServiceAccountKind:
+				ns := s.Namespace
+				if ns == "" {
+					ns = bindingNamespace
+				}
+				if namespace != "" && ns != namespace || slices.Contains(scope.Exclude, ns) {
+					continue
+				}
+				subject = fmt.Sprintf("ServiceAccount %s/%s", ns, s.Name)
+			case s.Kind == rbacv1.GroupKind && (s.Name == "system:serviceaccounts" || s.Name == "system:serviceaccounts:"+namespace || namespace == "" && strings.HasPrefix(s.Name, "system:serviceaccounts:")):
+				subject = "组 " + s.Name + " 中的全部 ServiceAccount"
+			default:
+				continue
+			}
+			issue.Findings = append(issue.Findings, securityFinding{levelRBAC, finding{severity, fmt.Sprintf("把 %s 授予 %s（%s）", roleRef.Name, subject, reach)}})
+		}
+		if len(issue.Findings) > 0 {
+			issues = append(issues, issue)
+		}
+	}
+
+	bindings, err := clientGo.ClientSet.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bindings.Items {
+		check("ClusterRoleBinding", "", b.Name, b.RoleRef, b.Subjects)
+	}
+	roleBindings, err := clientGo.ClientSet.RbacV1().RoleBindings(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range roleBindings.Items {
+		check("RoleBinding", b.Namespace, b.Name, b.RoleRef, b.Subjects)
+	}
+	return issues, nil
+}
+
+// 对所有资源拥有所有操作权限
+func rulesWildcard(rules []rbacv1.PolicyRule) bool {
+	for _, r := range rules {
+		if slices.Contains(r.Verbs, "*") && slices.Contains(r.Resources, "*") && slices.Contains(r.APIGroups, "*") {
+			return true
+		}
+	}
+	return false
+}
+
+// securityRemediation 是模型给出的修复建议和本地计算的补丁预览
+type securityRemediation struct {
+	Response string
+	// strategic merge patch（JSON）
+	Patch []byte
+	// 修改前后 Pod 模板的差异
+	Diff string
+	// 无法从回复中解析出可用补丁时的原因
+	Err error
+}
+
+// remediateSecurity 请模型生成修复补丁，并在本地合并到当前对象上预览差异，不会修改集群
+func remediateSecurity(issue SecurityIssue) (securityRemediation, error) {
+	findings := issue.evidence()
+	model := analysisModel
+	budget := utils.NewPromptBudget(model, appConfig.Budget)
+	data := utils.SecurityRemediationData{
+		Kind:        issue.Ref.Kind,
+		Namespace:   issue.Ref.Namespace,
+		Name:        issue.Ref.Name,
+		Findings:    findings,
+		PodSpecPath: issue.PodSpecPath,
+	}
+	skeleton, err := promptSet.Render(utils.PromptSecurityRemediation, data)
+	if err != nil {
+		return securityRemediation{}, err
+	}
+	spec := yamlSummary(issue.PodSpec)
+	hits := retrieveRunbooks(strings.Join(findings, "\n"))
+	alloc := budget.Allocate(utils.CountTokens(model, skeleton), []utils.BudgetSection{
+		{Name: "spec", Need: utils.CountTokens(model, spec), Weight: 1},
+		{Name: "runbooks", Need: eventsTokens(model, runbookExcerpts(model, hits)), Weight: 1},
+	})
+	data.Spec = strings.TrimRight(utils.TruncateToTokens(model, spec, alloc["spec"], false), "\n")
+	data.Runbooks, hits = fitRunbooks(model, hits, alloc["runbooks"])
+
+	response, err := analyzeWithLLM("remediateSecurity", utils.PromptSecurityRemediation, data, budget.ResponseTokens)
+	if err != nil {
+		return securityRemediation{}, err
+	}
+	result := securityRemediation{Response: response}
+	result.Patch, result.Diff, result.Err = previewPatch(issue.Object, response)
+	result.Response = withPastIncidents(citeRunbooks(response, hits), similarIncidents(issue.Ref.Kind, issue.Ref.Namespace, issue.Ref.Name, findings))
+	return result, nil
+}
+
+// previewPatch 从回复中取出 YAML 补丁，合并到对象上并返回 spec 的差异
+func previewPatch(obj runtime.Object, response string) ([]byte, string, error) {
+	m := yamlBlockPattern.FindStringSubmatch(response)
+	if m == nil {
+		return nil, "", errors.New("回复中没有 YAML 代码块")
+	}
+	patch, err := yaml.YAMLToJSON([]byte(m[1]))
+	if err != nil {
+		return nil, "", fmt.Errorf("补丁不是合法的 YAML: %w", err)
+	}
+	original, err := json.Marshal(obj)
+	if err != nil {
+		return nil, "", err
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, patch, obj)
+	if err != nil {
+		return nil, "", fmt.Errorf("无法合并补丁: %w", err)
+	}
+
+	var before, after map[string]any
+	if err := json.Unmarshal(original, &before); err != nil {
+		return nil, "", err
+	}
+	if err := json.Unmarshal(patched, &after); err != nil {
+		return nil, "", err
+	}
+	// 补丁只应该修改 spec
+	for _, key := range []string{"apiVersion", "kind", "metadata"} {
+		if yamlSummary(before[key]) != yamlSummary(after[key]) {
+			return nil, "", fmt.Errorf("补丁修改了 %s，已拒绝", key)
+		}
+	}
+	// 确认合并结果仍能解析为原来的类型
+	check := obj.DeepCopyObject()
+	if err := json.Unmarshal(patched, check); err != nil {
+		return nil, "", fmt.Errorf("合并后的对象无效: %w", err)
+	}
+	return patch, utils.LineDiff(yamlSummary(before["spec"]), yamlSummary(after["spec"])), nil
+}
+
+// --apply 可以修改的工作负载及其资源名
+// Pod 和 Job 的 Pod 模板创建后基本不可修改，只能重新创建，不在其中
+var workloadResources = map[string]schema.GroupVersionResource{
+	"Deployment":  appsv1.SchemeGroupVersion.WithResource("deployments"),
+	"StatefulSet": appsv1.SchemeGroupVersion.WithResource("statefulsets"),
+	"DaemonSet":   appsv1.SchemeGroupVersion.WithResource("daemonsets"),
+	"CronJob":     batchv1.SchemeGroupVersion.WithResource("cronjobs"),
+}
+
+// patchWorkload 以 strategic merge patch 修改工作负载，修改前保存当前对象用于 undo
+func patchWorkload(clientGo *utils.ClientGo, issue SecurityIssue, patch []byte) error {
+	ref := issue.Ref
+	gvr, ok := workloadResources[ref.Kind]
+	if !ok {
+		return fmt.Errorf("不支持修改 %s", ref.Kind)
+	}
+	if err := requireAccess(clientGo, "patchWorkload", gvrAccess("patch", gvr, ref.Namespace, ref.Name)); err != nil {
+		return err
+	}
+	ctx := context.TODO()
+	submit := func(dryRun []string) error {
+		opts := metav1.PatchOptions{DryRun: dryRun}
+		var err error
+		switch ref.Kind {
+		case "Deployment":
+			_, err = clientGo.ClientSet.AppsV1().Deployments(ref.Namespace).Patch(ctx, ref.Name, types.StrategicMergePatchType, patch, opts)
+		case "StatefulSet":
+			_, err = clientGo.ClientSet.AppsV1().StatefulSets(ref.Namespace).Patch(ctx, ref.Name, types.StrategicMergePatchType, patch, opts)
+		case "DaemonSet":
+			_, err = clientGo.ClientSet.AppsV1().DaemonSets(ref.Namespace).Patch(ctx, ref.Name, types.StrategicMergePatchType, patch, opts)
+		case "CronJob":
+			_, err = clientGo.ClientSet.BatchV1().CronJobs(ref.Namespace).Patch(ctx, ref.Name, types.StrategicMergePatchType, patch, opts)
+		}
+		return err
+	}
+	if err := enforcePolicy(clientGo, newPolicyRequest("patchWorkload", "patch", gvr, ref.Namespace, ref.Name), submit); err != nil {
+		return err
+	}
+	if err := captureTypedUndo(utils.UndoUpdate, gvr, ref.Kind, issue.Object); err != nil {
+		return err
+	}
+	if err := saveUndo(); err != nil {
+		return err
+	}
+	return submit(nil)
+}
+
+func init() {
+	analyzeCmd.AddCommand(securityCmd)
+	securityCmd.Flags().BoolVar(&securityFix, "fix", false, "ask the model for remediation patches and preview them as diffs")
+	securityCmd.Flags().BoolVar(&securityApply, "apply", false, "apply each previewed patch after confirmation (implies --fix)")
+}
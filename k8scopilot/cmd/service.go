@@ -0,0 +1,504 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+// serviceCmd 排查 Service 不通的常见原因
+var serviceCmd = &cobra.Command{
+	Use:   "service <name>",
+	Short: "检查 Service 的选择器、EndpointSlice、端口映射、Ingress 和 NetworkPolicy",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		clientGo, err := newClientGo()
+		if err != nil {
+			fmt.Println("连接集群失败:", err)
+			return
+		}
+		issue, err := inspectService(clientGo, namespace, args[0])
+		if err != nil {
+			fmt.Println("获取 Service 失败:", err)
+			return
+		}
+
+		fmt.Printf("Service %s/%s：\n", issue.Namespace, issue.Name)
+		if len(issue.Findings) == 0 {
+			fmt.Println("  ✅ 未发现配置问题")
+		}
+		for _, f := range issue.Findings {
+			fmt.Println("  " + f.String())
+		}
+
+		notifier, err := utils.NewNotifier(appConfig.Notify)
+		if err != nil {
+			fmt.Println("初始化通知渠道失败:", err)
+			return
+		}
+		defer func() {
+			if err := notifier.Flush(context.TODO()); err != nil {
+				fmt.Println("发送通知失败:", err)
+			}
+		}()
+
+		result, err := analyzeService(issue)
+		if err != nil {
+			fmt.Println("分析失败:", err)
+			return
+		}
+		fmt.Println("\n分析结果：")
+		fmt.Println(result)
+
+		sendDiagnosis(notifier, utils.Diagnosis{
+			Kind:          "Service",
+			Namespace:     issue.Namespace,
+			Name:          issue.Name,
+			Severity:      maxSeverity(issue.Findings),
+			Events:        findingStrings(issue.Findings),
+			Result:        result,
+			PromptVersion: promptSet.Version(utils.PromptServiceAnalysis),
+		})
+		printRedactionReport()
+	},
+}
+
+// ServiceIssue 是一个 Service 的检查结果
+type ServiceIssue struct {
+	Namespace string
+	Name      string
+	Findings  []finding
+	// Service 的类型、选择器和端口（YAML）
+	Spec string
+	// 选择器命中的 Pod，格式为 name phase ready
+	Pods []string
+	// EndpointSlice 中的地址及就绪状态
+	Endpoints []string
+	// 引用该 Service 的 Ingress 规则
+	Ingresses []string
+	// 作用于后端 Pod 的 NetworkPolicy
+	NetworkPolicies []string
+}
+
+// serviceObjects 是检查 Service 时用到的同一命名空间下的对象，
+// 报告中同一命名空间的多个 Service 共用一份，避免每个 Service 都重新列一遍
+type serviceObjects struct {
+	pods []corev1.Pod
+	// 列出失败时为 nil，跳过对应的检查
+	endpointSlices *discoveryv1.EndpointSliceList
+	ingresses      *networkingv1.IngressList
+	policies       *networkingv1.NetworkPolicyList
+}
+
+func listServiceObjects(clientGo *utils.ClientGo, namespace string) (*serviceObjects, error) {
+	pods, err := clientGo.ClientSet.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	objs := &serviceObjects{pods: pods.Items}
+	if list, err := clientGo.ClientSet.DiscoveryV1().EndpointSlices(namespace).List(context.TODO(), metav1.ListOptions{}); err == nil {
+		objs.endpointSlices = list
+	}
+	if list, err := clientGo.ClientSet.NetworkingV1().Ingresses(namespace).List(context.TODO(), metav1.ListOptions{}); err == nil {
+		objs.ingresses = list
+	}
+	if list, err := clientGo.ClientSet.NetworkingV1().NetworkPolicies(namespace).List(context.TODO(), metav1.ListOptions{}); err == nil {
+		objs.policies = list
+	}
+	return objs, nil
+}
+
+// inspectService 对 Service 做规则检查
+func inspectService(clientGo *utils.ClientGo, namespace, name string) (ServiceIssue, error) {
+	svc, err := clientGo.ClientSet.CoreV1().Services(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return ServiceIssue{}, err
+	}
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		return checkService(svc, nil), nil
+	}
+	objs, err := listServiceObjects(clientGo, svc.Namespace)
+	if err != nil {
+		return ServiceIssue{Namespace: svc.Namespace, Name: svc.Name, Spec: serviceSpecSummary(svc)}, err
+	}
+	return checkService(svc, objs), nil
+}
+
+// checkService 用已经列出的对象检查 Service，ExternalName 类型时 objs 可以为 nil
+func checkService(svc *corev1.Service, objs *serviceObjects) ServiceIssue {
+	issue := ServiceIssue{Namespace: svc.Namespace, Name: svc.Name, Spec: serviceSpecSummary(svc)}
+	add := func(severity, format string, args ...any) {
+		issue.Findings = append(issue.Findings, finding{severity, fmt.Sprintf(format, args...)})
+	}
+
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		add(utils.SeverityInfo, "ExternalName Service 直接解析到 %s，不经过 Pod 和 Endpoint", svc.Spec.ExternalName)
+		return issue
+	}
+
+	// 1. 选择器与 Pod
+	var pods []corev1.Pod
+	if len(svc.Spec.Selector) == 0 {
+		add(utils.SeverityInfo, "Service 没有选择器，Endpoint 需要手动维护")
+	} else {
+		selector := labels.SelectorFromSet(svc.Spec.Selector)
+		for _, pod := range objs.pods {
+			if selector.Matches(labels.Set(pod.Labels)) {
+				pods = append(pods, pod)
+			}
+		}
+		if len(pods) == 0 {
+			add(utils.SeverityCritical, "选择器 %s 没有匹配任何 Pod%s", selector, nearMissHint(svc.Spec.Selector, objs.pods))
+		}
+		ready := 0
+		for _, pod := range pods {
+			r := podReady(&pod)
+			if r {
+				ready++
+			}
+			issue.Pods = append(issue.Pods, fmt.Sprintf("%s phase=%s ready=%t", pod.Name, pod.Status.Phase, r))
+		}
+		if len(pods) > 0 && ready == 0 {
+			add(utils.SeverityCritical, "选择器匹配的 %d 个 Pod 都未就绪", len(pods))
+		}
+	}
+
+	// 2. EndpointSlice
+	if objs.endpointSlices != nil {
+		readyEndpoints := 0
+		for _, slice := range objs.endpointSlices.Items {
+			if slice.Labels[discoveryv1.LabelServiceName] != svc.Name {
+				continue
+			}
+			for _, ep := range slice.Endpoints {
+				ready := ep.Conditions.Ready == nil || *ep.Conditions.Ready
+				if ready {
+					readyEndpoints++
+				}
+				target := ""
+				if ep.TargetRef != nil {
+					target = " " + ep.TargetRef.Name
+				}
+				issue.Endpoints = append(issue.Endpoints, fmt.Sprintf("%s%s ready=%t", strings.Join(ep.Addresses, ","), target, ready))
+			}
+		}
+		switch {
+		case len(issue.Endpoints) == 0:
+			add(utils.SeverityCritical, "没有任何 Endpoint，流量无法转发")
+		case readyEndpoints == 0:
+			add(utils.SeverityCritical, "%d 个 Endpoint 均未就绪", len(issue.Endpoints))
+		}
+	}
+
+	// 3. targetPort 与容器端口
+	for _, port := range svc.Spec.Ports {
+		for _, msg := range checkTargetPort(port, pods) {
+			add(msg.Severity, "%s", msg.Message)
+		}
+	}
+
+	// 4. 引用该 Service 的 Ingress
+	if objs.ingresses != nil {
+		for _, ing := range objs.ingresses.Items {
+			for _, ref := range ingressBackends(&ing) {
+				if ref.backend.Name != svc.Name {
+					continue
+				}
+				issue.Ingresses = append(issue.Ingresses, fmt.Sprintf("%s %s -> %s", ing.Name, ref.path, backendPortString(ref.backend.Port)))
+				if !servicePortExists(svc, ref.backend.Port) {
+					add(utils.SeverityCritical, "Ingress %s 的 %s 引用了 Service 不存在的端口 %s", ing.Name, ref.path, backendPortString(ref.backend.Port))
+				}
+			}
+		}
+	}
+
+	// 5. 作用于后端 Pod 的 NetworkPolicy
+	if objs.policies != nil && len(pods) > 0 {
+		for _, np := range objs.policies.Items {
+			if !policySelectsAny(&np, pods) || !policyHasType(&np, networkingv1.PolicyTypeIngress) {
+				continue
+			}
+			issue.NetworkPolicies = append(issue.NetworkPolicies, networkPolicySummary(&np))
+			if len(np.Spec.Ingress) == 0 {
+				add(utils.SeverityWarning, "NetworkPolicy %s 拒绝后端 Pod 的全部入站流量", np.Name)
+				continue
+			}
+			for _, port := range svc.Spec.Ports {
+				if !policyAllowsPort(&np, port, pods) {
+					add(utils.SeverityWarning, "NetworkPolicy %s 的入站规则没有放行端口 %s", np.Name, targetPortString(port))
+				}
+			}
+		}
+	}
+	return issue
+}
+
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// 选择器只差一个标签就能匹配的 Pod，通常是标签拼写或版本不一致
+func nearMissHint(selector map[string]string, pods []corev1.Pod) string {
+	for _, pod := range pods {
+		var missing []string
+		for k, v := range selector {
+			if pod.Labels[k] != v {
+				missing = append(missing, fmt.Sprintf("%s=%s（Pod 上为 %q）", k, v, pod.Labels[k]))
+			}
+		}
+		if len(missing) == 1 && len(selector) > 1 {
+			return fmt.Sprintf("，Pod %s 只差标签 %s", pod.Name, missing[0])
+		}
+	}
+	return ""
+}
+
+// 未设置 targetPort 时与 port 相同
+func serviceTargetPort(port corev1.ServicePort) intstr.IntOrString {
+	if port.TargetPort.Type == intstr.Int && port.TargetPort.IntVal == 0 {
+		return intstr.FromInt32(port.Port)
+	}
+	return port.TargetPort
+}
+
+func targetPortString(port corev1.ServicePort) string {
+	target := serviceTargetPort(port)
+	return fmt.Sprintf("%d->%s", port.Port, target.String())
+}
+
+// checkTargetPort 检查 targetPort 能否在后端 Pod 上找到对应的容器端口
+func checkTargetPort(port corev1.ServicePort, pods []corev1.Pod) []finding {
+	if len(pods) == 0 {
+		return nil
+	}
+	protocol := port.Protocol
+	if protocol == "" {
+		protocol = corev1.ProtocolTCP
+	}
+	target := serviceTargetPort(port)
+	var unresolved []string
+	declared := false
+	for _, pod := range pods {
+		found := false
+		for _, c := range pod.Spec.Containers {
+			for _, cp := range c.Ports {
+				cpProtocol := cp.Protocol
+				if cpProtocol == "" {
+					cpProtocol = corev1.ProtocolTCP
+				}
+				if cpProtocol != protocol {
+					continue
+				}
+				if (target.Type == intstr.String && cp.Name == target.StrVal) || (target.Type == intstr.Int && cp.ContainerPort == target.IntVal) {
+					found = true
+				}
+			}
+		}
+		if found {
+			declared = true
+		} else {
+			unresolved = append(unresolved, pod.Name)
+		}
+	}
+	if len(unresolved) == 0 {
+		return nil
+	}
+	if target.Type == intstr.String {
+		// 命名端口解析不到时该 Pod 不会出现在 Endpoint 中
+		return []finding{{utils.SeverityCritical, fmt.Sprintf("端口 %s：命名端口 %q 在 Pod %s 中不存在", targetPortString(port), target.StrVal, strings.Join(unresolved, ", "))}}
+	}
+	if !declared {
+		// 容器可以监听未声明的端口，因此只给出提示
+		return []finding{{utils.SeverityWarning, fmt.Sprintf("端口 %s：后端容器都没有声明 containerPort %d/%s，请确认进程确实监听该端口", targetPortString(port), target.IntVal, protocol)}}
+	}
+	return []finding{{utils.SeverityWarning, fmt.Sprintf("端口 %s：Pod %s 没有声明 containerPort %d", targetPortString(port), strings.Join(unresolved, ", "), target.IntVal)}}
+}
+
+type ingressBackendRef struct {
+	path    string
+	backend *networkingv1.IngressServiceBackend
+}
+
+func ingressBackends(ing *networkingv1.Ingress) []ingressBackendRef {
+	var refs []ingressBackendRef
+	if b := ing.Spec.DefaultBackend; b != nil && b.Service != nil {
+		refs = append(refs, ingressBackendRef{"defaultBackend", b.Service})
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			if p.Backend.Service != nil {
+				refs = append(refs, ingressBackendRef{rule.Host + p.Path, p.Backend.Service})
+			}
+		}
+	}
+	return refs
+}
+
+func backendPortString(port networkingv1.ServiceBackendPort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return fmt.Sprint(port.Number)
+}
+
+func servicePortExists(svc *corev1.Service, port networkingv1.ServiceBackendPort) bool {
+	for _, p := range svc.Spec.Ports {
+		if (port.Name != "" && p.Name == port.Name) || (port.Name == "" && p.Port == port.Number) {
+			return true
+		}
+	}
+	return false
+}
+
+func policySelectsAny(np *networkingv1.NetworkPolicy, pods []corev1.Pod) bool {
+	selector, err := metav1.LabelSelectorAsSelector(&np.Spec.PodSelector)
+	if err != nil {
+		return false
+	}
+	for _, pod := range pods {
+		if selector.Matches(labels.Set(pod.Labels)) {
+			return true
+		}
+	}
+	return false
+}
+
+// 未声明 policyTypes 时默认包含 Ingress
+func policyHasType(np *networkingv1.NetworkPolicy, t networkingv1.PolicyType) bool {
+	if len(np.Spec.PolicyTypes) == 0 {
+		return t == networkingv1.PolicyTypeIngress || len(np.Spec.Egress) > 0
+	}
+	for _, pt := range np.Spec.PolicyTypes {
+		if pt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// policyAllowsPort 判断是否有入站规则放行 Service 的 targetPort，不关心来源
+func policyAllowsPort(np *networkingv1.NetworkPolicy, port corev1.ServicePort, pods []corev1.Pod) bool {
+	target := serviceTargetPort(port)
+	for _, rule := range np.Spec.Ingress {
+		if len(rule.Ports) == 0 {
+			return true
+		}
+		for _, p := range rule.Ports {
+			if p.Port == nil {
+				return true
+			}
+			if p.Port.String() == target.String() {
+				return true
+			}
+			// 规则中写数字、Service 写命名端口（或反过来）时按容器端口比较
+			if containerPortNumber(pods, *p.Port) == containerPortNumber(pods, target) && containerPortNumber(pods, target) != 0 {
+				return true
+			}
+			if p.Port.Type == intstr.Int && p.EndPort != nil {
+				n := containerPortNumber(pods, target)
+				if n >= p.Port.IntVal && n <= *p.EndPort {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func containerPortNumber(pods []corev1.Pod, port intstr.IntOrString) int32 {
+	if port.Type == intstr.Int {
+		return port.IntVal
+	}
+	for _, pod := range pods {
+		for _, c := range pod.Spec.Containers {
+			for _, cp := range c.Ports {
+				if cp.Name == port.StrVal {
+					return cp.ContainerPort
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func networkPolicySummary(np *networkingv1.NetworkPolicy) string {
+	out, err := yaml.Marshal(map[string]any{"name": np.Name, "spec": np.Spec})
+	if err != nil {
+		return np.Name
+	}
+	return string(out)
+}
+
+func serviceSpecSummary(svc *corev1.Service) string {
+	summary := struct {
+		Type      corev1.ServiceType   `json:"type"`
+		ClusterIP string               `json:"clusterIP,omitempty"`
+		Selector  map[string]string    `json:"selector,omitempty"`
+		Ports     []corev1.ServicePort `json:"ports,omitempty"`
+	}{svc.Spec.Type, svc.Spec.ClusterIP, svc.Spec.Selector, svc.Spec.Ports}
+	out, err := yaml.Marshal(summary)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// analyzeService 把检查结果交给模型解释
+func analyzeService(issue ServiceIssue) (string, error) {
+	model := analysisModel
+	budget := utils.NewPromptBudget(model, appConfig.Budget)
+	data := utils.ServiceAnalysisData{
+		Namespace: issue.Namespace,
+		Name:      issue.Name,
+		Findings:  findingStrings(issue.Findings),
+		Spec:      issue.Spec,
+		Ingresses: issue.Ingresses,
+	}
+	skeleton, err := promptSet.Render(utils.PromptServiceAnalysis, data)
+	if err != nil {
+		return "", err
+	}
+	hits := retrieveRunbooks(strings.Join(data.Findings, "\n"))
+	past := similarIncidents("Service", issue.Namespace, issue.Name, data.Findings)
+	pastLines := pastIncidentLines(model, past)
+	alloc := budget.Allocate(utils.CountTokens(model, skeleton), []utils.BudgetSection{
+		{Name: "pods", Need: eventsTokens(model, issue.Pods), Weight: 1},
+		{Name: "endpoints", Need: eventsTokens(model, issue.Endpoints), Weight: 1},
+		{Name: "policies", Need: eventsTokens(model, issue.NetworkPolicies), Weight: 2},
+		{Name: "runbooks", Need: eventsTokens(model, runbookExcerpts(model, hits)), Weight: 2},
+		{Name: "incidents", Need: eventsTokens(model, pastLines), Weight: 2},
+	})
+	data.Pods = limitEvents(model, issue.Pods, alloc["pods"])
+	data.Endpoints = limitEvents(model, issue.Endpoints, alloc["endpoints"])
+	data.NetworkPolicies = limitEvents(model, issue.NetworkPolicies, alloc["policies"])
+	data.Runbooks, hits = fitRunbooks(model, hits, alloc["runbooks"])
+	data.PastIncidents = limitEvents(model, pastLines, alloc["incidents"])
+
+	result, err := analyzeWithLLM("analyzeService", utils.PromptServiceAnalysis, data, budget.ResponseTokens)
+	if err != nil {
+		return "", err
+	}
+	return withPastIncidents(citeRunbooks(result, hits), past), nil
+}
+
+func init() {
+	analyzeCmd.AddCommand(serviceCmd)
+}
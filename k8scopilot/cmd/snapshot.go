@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// snapshotCmd 把集群状态导出为压缩包，之后可以用 analyze --from-snapshot 离线分析
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "导出集群快照（Pod、事件、工作负载、节点和异常 Pod 的日志）",
+	Run: func(cmd *cobra.Command, args []string) {
+		clientGo, err := newClientGo()
+		if err != nil {
+			fmt.Println("连接集群失败:", err)
+			return
+		}
+		snapshot, err := collectSnapshot(clientGo)
+		if err != nil {
+			fmt.Println("导出快照失败:", err)
+			return
+		}
+
+		output := snapshotOutput
+		if output == "" {
+			output = fmt.Sprintf("snapshot-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Println("创建文件失败:", err)
+			return
+		}
+		defer f.Close()
+		if err := utils.WriteSnapshot(f, snapshot); err != nil {
+			fmt.Println("写入快照失败:", err)
+			return
+		}
+		total := 0
+		for _, n := range snapshot.Meta.Counts {
+			total += n
+		}
+		fmt.Printf("✅ 已导出 %d 个对象、%d 份日志到 %s\n", total, snapshot.Meta.Logs, output)
+	},
+}
+
+var snapshotOutput string
+var snapshotAllNamespaces bool
+var snapshotLogs bool
+
+func collectSnapshot(clientGo *utils.ClientGo) (*utils.Snapshot, error) {
+	ns := namespace
+	if snapshotAllNamespaces {
+		ns = metav1.NamespaceAll
+	}
+	snapshot := &utils.Snapshot{
+		Meta: utils.SnapshotMeta{
+			CreatedAt: time.Now(),
+			Context:   utils.CurrentContext(kubeconfig),
+		},
+		Objects: map[string][]unstructured.Unstructured{},
+		Logs:    map[string]string{},
+	}
+	if ns != "" {
+		snapshot.Meta.Namespaces = []string{ns}
+	}
+
+	list := func(gvr schema.GroupVersionResource, namespaced bool) {
+		var (
+			items *unstructured.UnstructuredList
+			err   error
+		)
+		if namespaced {
+			items, err = clientGo.DynamicClient.Resource(gvr).Namespace(ns).List(context.TODO(), metav1.ListOptions{})
+		} else {
+			items, err = clientGo.DynamicClient.Resource(gvr).List(context.TODO(), metav1.ListOptions{})
+		}
+		if err != nil {
+			// 没有权限的资源跳过，不影响其它资源
+			fmt.Printf("⚠️  跳过 %s: %v\n", gvr.Resource, err)
+			return
+		}
+		key := gvr.Resource
+		if gvr.Group != "" {
+			key += "." + gvr.Group
+		}
+		snapshot.Objects[key] = items.Items
+	}
+	for _, gvr := range utils.SnapshotResources {
+		list(gvr, true)
+	}
+	for _, gvr := range utils.SnapshotClusterResources {
+		list(gvr, false)
+	}
+
+	if !snapshotLogs {
+		return snapshot, nil
+	}
+	// 只导出异常 Pod 的日志，控制快照大小
+	pods, err := clientGo.ClientSet.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	warned := map[string]bool{}
+	if events, err := utils.ListEvents(context.TODO(), clientGo, ns, true); err == nil {
+		for _, e := range events {
+			if e.Kind == "Pod" {
+				warned[e.Namespace+"/"+e.Name] = true
+			}
+		}
+	}
+	for _, pod := range pods.Items {
+		key := pod.Namespace + "/" + pod.Name
+		if !warned[key] && podHealthy(&pod) {
+			continue
+		}
+		podLogs, err := clientGo.PodLogs(context.TODO(), pod.Namespace, pod.Name, podLogOptions())
+		if err != nil {
+			continue
+		}
+		buf := new(bytes.Buffer)
+		_, err = buf.ReadFrom(podLogs)
+		podLogs.Close()
+		if err == nil && buf.Len() > 0 {
+			snapshot.Logs[key] = buf.String()
+		}
+	}
+	return snapshot, nil
+}
+
+// 所有容器都 Ready 且没有重启过的 Pod 视为健康
+func podHealthy(pod *corev1.Pod) bool {
+	if pod.Status.Phase == corev1.PodSucceeded {
+		return true
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	for _, cs := range pod.Status.ContainerStatuses {
+		if !cs.Ready || cs.RestartCount > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+	snapshotCmd.Flags().StringVarP(&snapshotOutput, "output", "o", "", "output file (default snapshot-<time>.tar.gz)")
+	snapshotCmd.Flags().BoolVarP(&snapshotAllNamespaces, "all-namespaces", "A", false, "snapshot all namespaces instead of --namespace")
+	snapshotCmd.Flags().BoolVar(&snapshotLogs, "logs", true, "include logs of unhealthy pods")
+}
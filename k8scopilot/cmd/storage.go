@@ -0,0 +1,419 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// storageCmd 关联 Pod、PVC、PV、StorageClass 和 VolumeAttachment 排查存储问题
+var storageCmd = &cobra.Command{
+	Use:   "storage [pvc]",
+	Short: "分析 PVC 未绑定、StorageClass 缺失、访问模式冲突、可用区不匹配和容量问题",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		clientGo, err := newClientGo()
+		if err != nil {
+			fmt.Println("连接集群失败:", err)
+			return
+		}
+		issues, err := inspectStorage(clientGo, scope.Namespace, args)
+		if err != nil {
+			fmt.Println("获取存储状态失败:", err)
+			return
+		}
+		if len(issues) == 0 {
+			fmt.Println("✅ 存储运行正常")
+			return
+		}
+
+		notifier, err := utils.NewNotifier(appConfig.Notify)
+		if err != nil {
+			fmt.Println("初始化通知渠道失败:", err)
+			return
+		}
+		defer func() {
+			if err := notifier.Flush(context.TODO()); err != nil {
+				fmt.Println("发送通知失败:", err)
+			}
+		}()
+
+		for _, issue := range issues {
+			fmt.Printf("\n%s：\n", issue)
+			for _, f := range issue.Findings {
+				fmt.Println("  " + f.String())
+			}
+			// 只有提示信息时不调用模型
+			if maxSeverity(issue.Findings) == utils.SeverityInfo {
+				continue
+			}
+			result, err := analyzeStorage(issue)
+			if err != nil {
+				fmt.Printf("分析 %s 失败: %v\n", issue, err)
+				continue
+			}
+			fmt.Printf("\n%s 分析结果：\n", issue)
+			fmt.Println(result)
+
+			sendDiagnosis(notifier, utils.Diagnosis{
+				Kind:          issue.Kind,
+				Namespace:     issue.Namespace,
+				Name:          issue.Name,
+				Severity:      maxSeverity(issue.Findings),
+				Events:        issue.evidence(),
+				Result:        result,
+				PromptVersion: promptSet.Version(utils.PromptStorageAnalysis),
+			})
+		}
+		printRedactionReport()
+	},
+}
+
+// StorageIssue 是一个 PVC（或引用了不存在 PVC 的 Pod）的检查结果
+type StorageIssue struct {
+	// PersistentVolumeClaim 或 Pod
+	Kind      string
+	Namespace string
+	Name      string
+	Findings  []finding
+	// PVC、PV、StorageClass 的摘要（YAML）
+	Claim        string
+	Volume       string
+	StorageClass string
+	// 使用该 PVC 的 Pod 及所在节点
+	Pods []string
+	// PVC 和相关 Pod 的 Warning 事件
+	Events []string
+}
+
+func (s StorageIssue) String() string {
+	return fmt.Sprintf("%s %s/%s", s.Kind, s.Namespace, s.Name)
+}
+
+// evidence 返回记录事故和查找相似事故所用的证据：检查结果加上事件
+func (s StorageIssue) evidence() []string {
+	return slices.Concat(findingStrings(s.Findings), s.Events)
+}
+
+// 与存储相关的 Warning 事件
+var storageEventReasons = map[string]bool{
+	"FailedMount":        true,
+	"FailedAttachVolume": true,
+	"FailedBinding":      true,
+	"ProvisioningFailed": true,
+	"VolumeResizeFailed": true,
+	"FailedScheduling":   true,
+}
+
+// inspectStorage 检查命名空间中的 PVC，names 不为空时只检查指定的 PVC
+// 未指定时只返回有问题的 PVC；指定了 --selector 时只检查匹配的 Pod 使用的 PVC
+func inspectStorage(clientGo *utils.ClientGo, namespace string, names []string) ([]StorageIssue, error) {
+	ctx := context.TODO()
+	claims, err := clientGo.ClientSet.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := clientGo.ClientSet.CoreV1().Pods(namespace).List(ctx, scope.podListOptions())
+	if err != nil {
+		return nil, err
+	}
+	// 集群级资源可能没有权限，查询失败时按不存在处理
+	pvs := map[string]*corev1.PersistentVolume{}
+	if list, err := clientGo.ClientSet.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{}); err == nil {
+		for i := range list.Items {
+			pvs[list.Items[i].Name] = &list.Items[i]
+		}
+	}
+	classes := map[string]*storagev1.StorageClass{}
+	defaultClass := ""
+	if list, err := clientGo.ClientSet.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{}); err == nil {
+		for i := range list.Items {
+			sc := &list.Items[i]
+			classes[sc.Name] = sc
+			if sc.Annotations["storageclass.kubernetes.io/is-default-class"] == "true" {
+				defaultClass = sc.Name
+			}
+		}
+	}
+	attachments := map[string][]storagev1.VolumeAttachment{}
+	if list, err := clientGo.ClientSet.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{}); err == nil {
+		for _, va := range list.Items {
+			if pv := va.Spec.Source.PersistentVolumeName; pv != nil {
+				attachments[*pv] = append(attachments[*pv], va)
+			}
+		}
+	}
+	nodes := map[string]*corev1.Node{}
+	if list, err := clientGo.ClientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{}); err == nil {
+		for i := range list.Items {
+			nodes[list.Items[i].Name] = &list.Items[i]
+		}
+	}
+	events := map[string][]string{}
+	// 折叠后的事件已按时间排序
+	if list, err := utils.ListEvents(ctx, clientGo, namespace, true); err == nil {
+		for _, e := range list {
+			if storageEventReasons[e.Reason] && scope.recent(e) {
+				key := e.Object()
+				events[key] = append(events[key], e.String())
+			}
+		}
+	}
+
+	// PVC 被哪些 Pod 使用
+	users := map[string][]corev1.Pod{}
+	var issues []StorageIssue
+	claimNames := map[string]bool{}
+	for _, pvc := range claims.Items {
+		claimNames[pvc.Namespace+"/"+pvc.Name] = true
+	}
+	for _, pod := range pods.Items {
+		if !scope.includesPod(&pod) {
+			continue
+		}
+		for _, v := range pod.Spec.Volumes {
+			if v.PersistentVolumeClaim == nil {
+				continue
+			}
+			name := v.PersistentVolumeClaim.ClaimName
+			if key := pod.Namespace + "/" + name; claimNames[key] {
+				users[key] = append(users[key], pod)
+				continue
+			}
+			if len(names) > 0 {
+				continue
+			}
+			issues = append(issues, StorageIssue{
+				Kind:      "Pod",
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+				Findings:  []finding{{utils.SeverityCritical, fmt.Sprintf("引用的 PVC %s 不存在", name)}},
+				Events:    events["Pod/"+pod.Namespace+"/"+pod.Name],
+			})
+		}
+	}
+
+	for i := range claims.Items {
+		pvc := &claims.Items[i]
+		if len(names) > 0 && pvc.Name != names[0] {
+			continue
+		}
+		claimUsers := users[pvc.Namespace+"/"+pvc.Name]
+		if len(names) == 0 && (!scope.includesNamespace(pvc.Namespace) || scope.Selector != nil && len(claimUsers) == 0) {
+			continue
+		}
+		issue := StorageIssue{Kind: "PersistentVolumeClaim", Namespace: pvc.Namespace, Name: pvc.Name, Claim: claimSummary(pvc)}
+		add := func(severity, format string, args ...any) {
+			issue.Findings = append(issue.Findings, finding{severity, fmt.Sprintf(format, args...)})
+		}
+
+		className := defaultClass
+		if pvc.Spec.StorageClassName != nil {
+			className = *pvc.Spec.StorageClassName
+		}
+		sc := classes[className]
+		if sc != nil {
+			issue.StorageClass = storageClassSummary(sc)
+		}
+		pv := pvs[pvc.Spec.VolumeName]
+		if pv != nil {
+			issue.Volume = volumeSummary(pv)
+		}
+		for _, pod := range claimUsers {
+			issue.Pods = append(issue.Pods, fmt.Sprintf("%s phase=%s node=%s", pod.Name, pod.Status.Phase, pod.Spec.NodeName))
+			issue.Events = append(issue.Events, events["Pod/"+pod.Namespace+"/"+pod.Name]...)
+		}
+		issue.Events = slices.Concat(events["PersistentVolumeClaim/"+pvc.Namespace+"/"+pvc.Name], issue.Events)
+
+		// 1. 绑定状态与 StorageClass
+		switch pvc.Status.Phase {
+		case corev1.ClaimLost:
+			add(utils.SeverityCritical, "PVC 处于 Lost 状态，绑定的 PV %s 已不存在", pvc.Spec.VolumeName)
+		case corev1.ClaimPending:
+			switch {
+			case className == "" && pvc.Spec.StorageClassName == nil:
+				add(utils.SeverityCritical, "PVC 未指定 storageClassName，且集群没有默认 StorageClass")
+			case className == "":
+				add(utils.SeverityWarning, "PVC 未绑定，storageClassName 为空时只能绑定手动创建的 PV")
+			case sc == nil:
+				add(utils.SeverityCritical, "StorageClass %s 不存在", className)
+			case sc.VolumeBindingMode != nil && *sc.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer && len(claimUsers) == 0:
+				add(utils.SeverityInfo, "StorageClass %s 为 WaitForFirstConsumer，没有 Pod 使用前不会创建 PV", className)
+			default:
+				add(utils.SeverityCritical, "PVC 未绑定，StorageClass %s（provisioner %s）没有成功创建 PV", className, sc.Provisioner)
+			}
+		}
+
+		// 2. PV 的容量、访问模式和状态
+		if pv != nil {
+			request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+			capacity := pv.Spec.Capacity[corev1.ResourceStorage]
+			if capacity.Cmp(request) < 0 {
+				allowExpansion := sc != nil && sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion
+				if allowExpansion {
+					add(utils.SeverityWarning, "请求 %s 大于 PV 容量 %s，扩容尚未完成", request.String(), capacity.String())
+				} else {
+					add(utils.SeverityWarning, "请求 %s 大于 PV 容量 %s，且 StorageClass 不允许扩容", request.String(), capacity.String())
+				}
+			}
+			for _, mode := range pvc.Spec.AccessModes {
+				if !slices.Contains(pv.Spec.AccessModes, mode) {
+					add(utils.SeverityWarning, "PVC 请求访问模式 %s，但 PV 只支持 %v", mode, pv.Spec.AccessModes)
+				}
+			}
+			if pv.Status.Phase == corev1.VolumeFailed {
+				add(utils.SeverityCritical, "PV %s 处于 Failed 状态: %s", pv.Name, pv.Status.Message)
+			}
+		}
+		for _, c := range pvc.Status.Conditions {
+			if c.Status == corev1.ConditionTrue {
+				add(utils.SeverityInfo, "PVC 状况 %s: %s", c.Type, c.Message)
+			}
+		}
+
+		// 3. 访问模式与使用方式的冲突
+		usedNodes := map[string]bool{}
+		for _, pod := range claimUsers {
+			if pod.Spec.NodeName != "" {
+				usedNodes[pod.Spec.NodeName] = true
+			}
+		}
+		modes := pvc.Spec.AccessModes
+		if pv != nil {
+			modes = pv.Spec.AccessModes
+		}
+		switch {
+		case slices.Contains(modes, corev1.ReadWriteOncePod) && len(claimUsers) > 1:
+			add(utils.SeverityCritical, "ReadWriteOncePod 卷同时被 %d 个 Pod 使用", len(claimUsers))
+		case len(modes) == 1 && modes[0] == corev1.ReadWriteOnce && len(usedNodes) > 1:
+			add(utils.SeverityCritical, "ReadWriteOnce 卷被调度到 %d 个不同节点的 Pod 使用，只有一个节点能挂载", len(usedNodes))
+		}
+
+		// 4. 可用区：PV 的节点亲和性与使用它的 Pod 所在节点
+		if pv != nil && pv.Spec.NodeAffinity != nil && pv.Spec.NodeAffinity.Required != nil {
+			terms := pv.Spec.NodeAffinity.Required.NodeSelectorTerms
+			for _, pod := range claimUsers {
+				if node := nodes[pod.Spec.NodeName]; node != nil && !nodeMatchesTerms(node, terms) {
+					add(utils.SeverityCritical, "Pod %s 所在节点 %s 不满足 PV %s 的节点亲和性（通常是可用区不一致）", pod.Name, node.Name, pv.Name)
+				}
+			}
+			matched := 0
+			for _, node := range nodes {
+				if nodeMatchesTerms(node, terms) {
+					matched++
+				}
+			}
+			if len(nodes) > 0 && matched == 0 {
+				add(utils.SeverityCritical, "没有任何节点满足 PV %s 的节点亲和性，使用它的 Pod 无法调度", pv.Name)
+			}
+		}
+
+		// 5. VolumeAttachment
+		if pv != nil {
+			for _, va := range attachments[pv.Name] {
+				if va.Status.AttachError != nil {
+					add(utils.SeverityCritical, "挂载到节点 %s 失败: %s", va.Spec.NodeName, va.Status.AttachError.Message)
+				}
+				if va.Status.DetachError != nil {
+					add(utils.SeverityWarning, "从节点 %s 卸载失败: %s", va.Spec.NodeName, va.Status.DetachError.Message)
+				}
+			}
+		}
+
+		if len(issue.Events) > 0 && maxSeverity(issue.Findings) == utils.SeverityInfo {
+			add(utils.SeverityWarning, "相关 Pod 有 %d 条存储相关的 Warning 事件", len(issue.Events))
+		}
+		if len(names) == 0 && len(issue.Findings) == 0 {
+			continue
+		}
+		issues = append(issues, issue)
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		return utils.SeverityRank(maxSeverity(issues[i].Findings)) > utils.SeverityRank(maxSeverity(issues[j].Findings))
+	})
+	return issues, nil
+}
+
+func claimSummary(pvc *corev1.PersistentVolumeClaim) string {
+	return yamlSummary(map[string]any{"spec": pvc.Spec, "status": pvc.Status})
+}
+
+func volumeSummary(pv *corev1.PersistentVolume) string {
+	return yamlSummary(map[string]any{
+		"name":         pv.Name,
+		"capacity":     pv.Spec.Capacity,
+		"accessModes":  pv.Spec.AccessModes,
+		"nodeAffinity": pv.Spec.NodeAffinity,
+		"reclaim":      pv.Spec.PersistentVolumeReclaimPolicy,
+		"status":       pv.Status,
+	})
+}
+
+func storageClassSummary(sc *storagev1.StorageClass) string {
+	return yamlSummary(map[string]any{
+		"name":                 sc.Name,
+		"provisioner":          sc.Provisioner,
+		"volumeBindingMode":    sc.VolumeBindingMode,
+		"allowVolumeExpansion": sc.AllowVolumeExpansion,
+		"allowedTopologies":    sc.AllowedTopologies,
+	})
+}
+
+func yamlSummary(v any) string {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// analyzeStorage 把检查结果交给模型分析
+func analyzeStorage(issue StorageIssue) (string, error) {
+	model := analysisModel
+	budget := utils.NewPromptBudget(model, appConfig.Budget)
+	data := utils.StorageAnalysisData{
+		Kind:         issue.Kind,
+		Namespace:    issue.Namespace,
+		Name:         issue.Name,
+		Findings:     findingStrings(issue.Findings),
+		Claim:        issue.Claim,
+		Volume:       issue.Volume,
+		StorageClass: issue.StorageClass,
+	}
+	skeleton, err := promptSet.Render(utils.PromptStorageAnalysis, data)
+	if err != nil {
+		return "", err
+	}
+	hits := retrieveRunbooks(strings.Join(slices.Concat(data.Findings, issue.Events), "\n"))
+	past := similarIncidents(issue.Kind, issue.Namespace, issue.Name, issue.evidence())
+	pastLines := pastIncidentLines(model, past)
+	alloc := budget.Allocate(utils.CountTokens(model, skeleton), []utils.BudgetSection{
+		{Name: "pods", Need: eventsTokens(model, issue.Pods), Weight: 1},
+		{Name: "events", Need: eventsTokens(model, issue.Events), Weight: 2},
+		{Name: "runbooks", Need: eventsTokens(model, runbookExcerpts(model, hits)), Weight: 2},
+		{Name: "incidents", Need: eventsTokens(model, pastLines), Weight: 2},
+	})
+	data.Pods = limitEvents(model, issue.Pods, alloc["pods"])
+	data.Events = limitEvents(model, issue.Events, alloc["events"])
+	data.Runbooks, hits = fitRunbooks(model, hits, alloc["runbooks"])
+	data.PastIncidents = limitEvents(model, pastLines, alloc["incidents"])
+
+	result, err := analyzeWithLLM("analyzeStorage", utils.PromptStorageAnalysis, data, budget.ResponseTokens)
+	if err != nil {
+		return "", err
+	}
+	return withPastIncidents(citeRunbooks(result, hits), past), nil
+}
+
+func init() {
+	analyzeCmd.AddCommand(storageCmd)
+}
@@ -0,0 +1,337 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// timelineCmd 输出与 Pod 相关的所有对象按时间排序的变化
+var timelineCmd = &cobra.Command{
+	Use:   "timeline <pod>",
+	Short: "把 Pod、所属工作负载、ReplicaSet 版本、HPA、引用的 ConfigMap/Secret 和节点的变化合并成一条时间线",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		clientGo, err := newClientGo()
+		if err != nil {
+			fmt.Println("连接集群失败:", err)
+			return
+		}
+		pod, err := clientGo.ClientSet.CoreV1().Pods(namespace).Get(context.TODO(), args[0], metav1.GetOptions{})
+		if err != nil {
+			fmt.Println("获取 Pod 失败:", err)
+			return
+		}
+		entries := buildIncidentTimeline(clientGo, pod, true)
+		if len(entries) == 0 {
+			fmt.Println("没有找到相关的变化")
+			return
+		}
+		for _, e := range entries {
+			fmt.Println(e)
+		}
+	},
+}
+
+// 时间线条目的来源
+const (
+	timelineEvent     = "事件"
+	timelineRevision  = "版本"
+	timelineChange    = "变更"
+	timelineCondition = "状态"
+)
+
+// timelineEntry 是事故时间线中的一项
+type timelineEntry struct {
+	Time time.Time
+	// 涉及的对象，例如 ConfigMap/app-config
+	Object  string
+	Source  string
+	Message string
+}
+
+func (e timelineEntry) String() string {
+	ts := "时间未知"
+	if !e.Time.IsZero() {
+		ts = e.Time.Local().Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprintf("%s %s [%s] %s", ts, e.Object, e.Source, e.Message)
+}
+
+// 一条时间线中每个对象最多列出的字段路径
+const maxManagedFieldPaths = 6
+
+// buildIncidentTimeline 收集 Pod 的 ownerReferences 链、Deployment 的 ReplicaSet 版本、
+// 指向工作负载的 HPA、引用的 ConfigMap/Secret 和所在节点的变化，按时间排序
+// managedFields 只记录每个 manager 最后一次修改的时间；includePodEvents 为 false 时不包含 Pod 自身的事件
+func buildIncidentTimeline(clientGo *utils.ClientGo, pod *corev1.Pod, includePodEvents bool) []timelineEntry {
+	ctx := context.TODO()
+	ns := pod.Namespace
+	var entries []timelineEntry
+	add := func(t time.Time, object, source, format string, args ...any) {
+		entries = append(entries, timelineEntry{Time: t, Object: object, Source: source, Message: fmt.Sprintf(format, args...)})
+	}
+	// 需要收集事件的对象，key 为 Kind/name
+	objects := map[string]bool{}
+	track := func(kind string, obj metav1.Object) {
+		name := kind + "/" + obj.GetName()
+		objects[name] = true
+		for _, mf := range obj.GetManagedFields() {
+			// status 由控制器频繁更新，只保留对对象本身的修改
+			if mf.Time == nil || mf.Subresource != "" {
+				continue
+			}
+			add(mf.Time.Time, name, timelineChange, "%s %s %s", mf.Manager, mf.Operation, strings.Join(managedFieldPaths(mf.FieldsV1), ", "))
+		}
+	}
+
+	// Pod 本身：创建时间和容器的上一次退出
+	track("Pod", pod)
+	add(pod.CreationTimestamp.Time, "Pod/"+pod.Name, timelineChange, "创建")
+	for _, cs := range pod.Status.ContainerStatuses {
+		if t := cs.LastTerminationState.Terminated; t != nil {
+			add(t.FinishedAt.Time, "Pod/"+pod.Name, timelineCondition, "容器 %s 退出（%s，exit %d），共重启 %d 次", cs.Name, t.Reason, t.ExitCode, cs.RestartCount)
+		}
+	}
+
+	// ownerReferences 链，Deployment 同时列出各个 ReplicaSet 版本
+	var workload metav1.Object
+	workloadKind := ""
+	if ref := metav1.GetControllerOf(pod); ref != nil {
+		switch ref.Kind {
+		case "ReplicaSet":
+			if rs, err := clientGo.ClientSet.AppsV1().ReplicaSets(ns).Get(ctx, ref.Name, metav1.GetOptions{}); err == nil {
+				workload, workloadKind = rs, "ReplicaSet"
+				if owner := metav1.GetControllerOf(rs); owner != nil && owner.Kind == "Deployment" {
+					if d, err := clientGo.ClientSet.AppsV1().Deployments(ns).Get(ctx, owner.Name, metav1.GetOptions{}); err == nil {
+						workload, workloadKind = d, "Deployment"
+						track("Deployment", d)
+						addRevisions(clientGo, d, track, add)
+					}
+				} else {
+					track("ReplicaSet", rs)
+				}
+			}
+		case "StatefulSet":
+			if s, err := clientGo.ClientSet.AppsV1().StatefulSets(ns).Get(ctx, ref.Name, metav1.GetOptions{}); err == nil {
+				workload, workloadKind = s, "StatefulSet"
+				track("StatefulSet", s)
+			}
+		case "DaemonSet":
+			if d, err := clientGo.ClientSet.AppsV1().DaemonSets(ns).Get(ctx, ref.Name, metav1.GetOptions{}); err == nil {
+				track("DaemonSet", d)
+			}
+		case "Job":
+			if job, err := clientGo.ClientSet.BatchV1().Jobs(ns).Get(ctx, ref.Name, metav1.GetOptions{}); err == nil {
+				track("Job", job)
+				if owner := metav1.GetControllerOf(job); owner != nil && owner.Kind == "CronJob" {
+					if c, err := clientGo.ClientSet.BatchV1().CronJobs(ns).Get(ctx, owner.Name, metav1.GetOptions{}); err == nil {
+						track("CronJob", c)
+					}
+				}
+			}
+		}
+	}
+
+	// 伸缩该工作负载的 HPA
+	if workload != nil {
+		if hpas, err := clientGo.ClientSet.AutoscalingV2().HorizontalPodAutoscalers(ns).List(ctx, metav1.ListOptions{}); err == nil {
+			for i := range hpas.Items {
+				hpa := &hpas.Items[i]
+				if hpa.Spec.ScaleTargetRef.Kind != workloadKind || hpa.Spec.ScaleTargetRef.Name != workload.GetName() {
+					continue
+				}
+				track("HorizontalPodAutoscaler", hpa)
+				if hpa.Status.LastScaleTime != nil {
+					add(hpa.Status.LastScaleTime.Time, "HorizontalPodAutoscaler/"+hpa.Name, timelineCondition,
+						"最近一次伸缩，当前 %d 个副本，期望 %d 个（范围 %d-%d）", hpa.Status.CurrentReplicas, hpa.Status.DesiredReplicas, ptrValue(hpa.Spec.MinReplicas, 1), hpa.Spec.MaxReplicas)
+				}
+			}
+		}
+	}
+
+	// 引用的 ConfigMap 和 Secret，通过元数据接口读取，不会拿到其中的内容
+	configMaps, secrets := podReferences(pod)
+	readMetadata := func(resource, kind string, names []string) {
+		client := clientGo.MetadataClient.Resource(corev1.SchemeGroupVersion.WithResource(resource)).Namespace(ns)
+		for _, name := range names {
+			if m, err := client.Get(ctx, name, metav1.GetOptions{}); err == nil {
+				track(kind, m)
+				add(m.CreationTimestamp.Time, kind+"/"+name, timelineChange, "创建")
+			}
+		}
+	}
+	readMetadata("configmaps", "ConfigMap", configMaps)
+	readMetadata("secrets", "Secret", secrets)
+
+	// 所在节点：状况变化、cordon/drain 等修改
+	if pod.Spec.NodeName != "" {
+		if node, err := clientGo.ClientSet.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{}); err == nil {
+			track("Node", node)
+			for _, c := range node.Status.Conditions {
+				msg := fmt.Sprintf("%s=%s", c.Type, c.Status)
+				if c.Reason != "" {
+					msg += "（" + c.Reason + "）"
+				}
+				add(c.LastTransitionTime.Time, "Node/"+node.Name, timelineCondition, "%s", msg)
+			}
+		}
+	}
+
+	// 相关对象的事件，Node 的事件不在 Pod 的命名空间中，单独按对象查询
+	var events []utils.Event
+	if nsEvents, err := utils.ListEvents(ctx, clientGo, ns, false); err == nil {
+		events = nsEvents
+	}
+	if pod.Spec.NodeName != "" {
+		if nodeEvents, err := utils.ListObjectEvents(ctx, clientGo, "Node", "", pod.Spec.NodeName, false); err == nil {
+			events = append(events, nodeEvents...)
+		}
+	}
+	for _, e := range events {
+		if !objects[e.Kind+"/"+e.Name] {
+			continue
+		}
+		if e.Kind == "Pod" && !includePodEvents {
+			continue
+		}
+		msg := e.Summary()
+		if !e.First.IsZero() && e.Last.After(e.First) {
+			msg += "（最后一次 " + e.Last.Local().Format("15:04:05") + "）"
+		}
+		t := e.First
+		if t.IsZero() {
+			t = e.Last
+		}
+		add(t, e.Kind+"/"+e.Name, timelineEvent, "%s", msg)
+	}
+
+	entries = slices.DeleteFunc(entries, func(e timelineEntry) bool {
+		return !e.Time.IsZero() && !scope.Since.IsZero() && e.Time.Before(scope.Since)
+	})
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries
+}
+
+// addRevisions 列出 Deployment 的各个 ReplicaSet 版本
+func addRevisions(clientGo *utils.ClientGo, d *appsv1.Deployment, track func(string, metav1.Object), add func(time.Time, string, string, string, ...any)) {
+	replicaSets, err := deploymentReplicaSets(clientGo, d)
+	if err != nil {
+		return
+	}
+	for i := range replicaSets {
+		rs := &replicaSets[i]
+		name := "ReplicaSet/" + rs.Name
+		track("ReplicaSet", rs)
+		add(rs.CreationTimestamp.Time, name, timelineRevision, "revision %d 创建（镜像 %s），当前 %d/%d 个副本",
+			revisionOf(rs), strings.Join(templateImages(&rs.Spec.Template), ","), rs.Status.ReadyReplicas, ptrValue(rs.Spec.Replicas, 1))
+	}
+}
+
+// podReferences 返回 Pod 通过卷、环境变量和 envFrom 引用的 ConfigMap 和 Secret
+func podReferences(pod *corev1.Pod) (configMaps, secrets []string) {
+	addCM := func(name string) {
+		if name != "" && !slices.Contains(configMaps, name) {
+			configMaps = append(configMaps, name)
+		}
+	}
+	addSecret := func(name string) {
+		if name != "" && !slices.Contains(secrets, name) {
+			secrets = append(secrets, name)
+		}
+	}
+	for _, v := range pod.Spec.Volumes {
+		if v.ConfigMap != nil {
+			addCM(v.ConfigMap.Name)
+		}
+		if v.Secret != nil {
+			addSecret(v.Secret.SecretName)
+		}
+		if v.Projected != nil {
+			for _, s := range v.Projected.Sources {
+				if s.ConfigMap != nil {
+					addCM(s.ConfigMap.Name)
+				}
+				if s.Secret != nil {
+					addSecret(s.Secret.Name)
+				}
+			}
+		}
+	}
+	containers := slices.Concat(pod.Spec.InitContainers, pod.Spec.Containers)
+	for _, c := range containers {
+		for _, from := range c.EnvFrom {
+			if from.ConfigMapRef != nil {
+				addCM(from.ConfigMapRef.Name)
+			}
+			if from.SecretRef != nil {
+				addSecret(from.SecretRef.Name)
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				addCM(ref.Name)
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				addSecret(ref.Name)
+			}
+		}
+	}
+	return configMaps, secrets
+}
+
+// managedFieldPaths 把 managedFields 中的字段集合转换成最多两层的路径，例如 spec.replicas、data
+func managedFieldPaths(fields *metav1.FieldsV1) []string {
+	if fields == nil {
+		return nil
+	}
+	var root map[string]any
+	if err := json.Unmarshal(fields.Raw, &root); err != nil {
+		return nil
+	}
+	var paths []string
+	for _, top := range sortedFieldKeys(root) {
+		children, _ := root["f:"+top].(map[string]any)
+		sub := sortedFieldKeys(children)
+		if len(sub) == 0 {
+			paths = append(paths, top)
+			continue
+		}
+		for _, s := range sub {
+			paths = append(paths, top+"."+s)
+		}
+	}
+	if len(paths) > maxManagedFieldPaths {
+		paths = append(paths[:maxManagedFieldPaths], fmt.Sprintf("等 %d 个字段", len(paths)))
+	}
+	return paths
+}
+
+// 只保留 f: 开头的字段名，列表元素（k:、v:）和 "." 不展开
+func sortedFieldKeys(m map[string]any) []string {
+	var keys []string
+	for k := range m {
+		if name, ok := strings.CutPrefix(k, "f:"); ok {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	analyzeCmd.AddCommand(timelineCmd)
+}
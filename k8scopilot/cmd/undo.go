@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// undoCmd 撤销 copilot 执行过的变更
+var undoCmd = &cobra.Command{
+	Use:   "undo <action-id>",
+	Short: "撤销一次 copilot 操作：重新创建被删除的对象、删除创建的对象或恢复修改前的 spec",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := initSession(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		entry, err := undoStore.Load(args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if entry.Pending {
+			fmt.Println("注意：这次操作在执行过程中中断，对象可能没有被修改")
+		}
+		if !undoYes {
+			fmt.Printf("将%s（操作 %s）。确认撤销？(y/N): ", entry.Describe(), entry.ActionID)
+			var answer string
+			_, _ = fmt.Scanln(&answer)
+			if strings.ToLower(answer) != "y" {
+				fmt.Println("已取消")
+				return
+			}
+		}
+		result, err := undoAction(entry, "undo")
+		if err != nil {
+			fmt.Println("撤销失败:", err)
+			return
+		}
+		fmt.Println(result)
+	},
+}
+
+var undoYes bool
+
+var (
+	// 执行变更前捕获的状态，由 saveUndo 在变更前写入文件，recordAudit 按结果确认或删除
+	pendingUndo *utils.UndoEntry
+	// 本次会话中可撤销的操作，最新的在最后
+	sessionUndo []string
+)
+
+// captureUndo 在变更前记录对象状态，obj 为空表示创建操作
+func captureUndo(operation string, gvr schema.GroupVersionResource, namespace, name string, obj *unstructured.Unstructured) {
+	entry := &utils.UndoEntry{Operation: operation, Namespace: namespace, Name: name}
+	entry.SetGVR(gvr)
+	if obj != nil {
+		entry.Object = utils.StripServerFields(obj)
+	}
+	pendingUndo = entry
+}
+
+// captureTypedUndo 把 client-go 的类型化对象转换后记录，apiVersion 和 kind 需要调用方给出
+func captureTypedUndo(operation string, gvr schema.GroupVersionResource, kind string, obj runtime.Object) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetAPIVersion(gvr.GroupVersion().String())
+	u.SetKind(kind)
+	captureUndo(operation, gvr, u.GetNamespace(), u.GetName(), u)
+	return nil
+}
+
+// saveUndo 在变更前把捕获的状态写入文件，保存失败时不应执行变更，否则变更后无法撤销
+// 没有捕获状态时什么也不做
+func saveUndo() error {
+	if pendingUndo == nil {
+		return nil
+	}
+	if err := initSession(); err != nil {
+		return err
+	}
+	pendingUndo.ActionID, pendingUndo.Time = utils.NewAuditID(), time.Now()
+	pendingUndo.Context, pendingUndo.Pending = clusterContext(), true
+	if err := undoStore.Save(pendingUndo); err != nil {
+		pendingUndo = nil
+		return fmt.Errorf("保存撤销信息失败，已取消操作: %w", err)
+	}
+	return nil
+}
+
+// undoAction 执行撤销并写入审计日志，同一个操作只能撤销一次
+func undoAction(entry *utils.UndoEntry, command string) (string, error) {
+	if entry.UndoneBy != "" {
+		return "", fmt.Errorf("操作 %s 已经被 %s 撤销", entry.ActionID, entry.UndoneBy)
+	}
+	result, err := applyUndo(entry)
+	record := utils.AuditRecord{
+		Command:   command,
+		Tool:      "undo",
+		Arguments: fmt.Sprintf(`{"actionId":%q}`, entry.ActionID),
+		DryRun:    entry.Describe(),
+	}
+	auditOutcome(&record, result, err)
+	record = recordAudit(record)
+	if err != nil {
+		return "", err
+	}
+	entry.UndoneBy = record.ID
+	if err := undoStore.Save(entry); err != nil {
+		fmt.Println("保存撤销信息失败:", err)
+	}
+	return result, nil
+}
+
+func applyUndo(entry *utils.UndoEntry) (string, error) {
+	// 同名对象在另一个集群上也可能存在，撤销到错误的集群会造成新的故障
+	if current := clusterContext(); entry.Context != "" && entry.Context != current {
+		return "", fmt.Errorf("操作 %s 是在集群 %s 上执行的，当前集群为 %s，请切换集群后再撤销", entry.ActionID, entry.Context, current)
+	}
+	clientGo, err := newClientGo()
+	if err != nil {
+		return "", err
+	}
+	gvr := entry.GVR()
+	var client dynamic.ResourceInterface = clientGo.DynamicClient.Resource(gvr)
+	if entry.Namespace != "" {
+		client = clientGo.DynamicClient.Resource(gvr).Namespace(entry.Namespace)
+	}
+
+	switch entry.Operation {
+	case utils.UndoCreate:
+		if err := requireAccess(clientGo, "undo", gvrAccess("delete", gvr, entry.Namespace, entry.Name)); err != nil {
+			return "", err
+		}
+		del := func(dryRun []string) error {
+			return client.Delete(context.TODO(), entry.Name, metav1.DeleteOptions{DryRun: dryRun})
+		}
+		if err := enforcePolicy(clientGo, newPolicyRequest("undo", "delete", gvr, entry.Namespace, entry.Name), del); err != nil {
+			return "", err
+		}
+		if err := del(nil); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("已删除 %s/%s", gvr.Resource, entry.Name), nil
+	case utils.UndoDelete:
+		if err := requireAccess(clientGo, "undo", gvrAccess("create", gvr, entry.Namespace, "")); err != nil {
+			return "", err
+		}
+		obj := &unstructured.Unstructured{Object: entry.Object}
+		create := func(dryRun []string) error {
+			_, err := client.Create(context.TODO(), obj, metav1.CreateOptions{DryRun: dryRun})
+			return err
+		}
+		req := withObject(newPolicyRequest("undo", "create", gvr, entry.Namespace, entry.Name), entry.Object)
+		if err := enforcePolicy(clientGo, req, create); err != nil {
+			return "", err
+		}
+		if err := create(nil); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("已重新创建 %s/%s", gvr.Resource, entry.Name), nil
+	case utils.UndoUpdate:
+		if err := requireAccess(clientGo, "undo",
+			gvrAccess("get", gvr, entry.Namespace, entry.Name),
+			gvrAccess("update", gvr, entry.Namespace, entry.Name),
+		); err != nil {
+			return "", err
+		}
+		current, err := client.Get(context.TODO(), entry.Name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		// 只恢复 spec，保留当前的元数据和 resourceVersion
+		current.Object["spec"] = entry.Object["spec"]
+		update := func(dryRun []string) error {
+			_, err := client.Update(context.TODO(), current, metav1.UpdateOptions{DryRun: dryRun})
+			return err
+		}
+		req := withObject(newPolicyRequest("undo", "update", gvr, entry.Namespace, entry.Name), current.Object)
+		if err := enforcePolicy(clientGo, req, update); err != nil {
+			return "", err
+		}
+		if err := update(nil); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("已恢复 %s/%s 修改前的 spec", gvr.Resource, entry.Name), nil
+	}
+	return "", fmt.Errorf("不支持撤销 %s 操作", entry.Operation)
+}
+
+// undoLast 撤销本次对话中最近一次还没有撤销的操作，id 不为空时撤销指定操作
+func undoLast(id string) string {
+	if err := initSession(); err != nil {
+		return err.Error()
+	}
+	if id == "" {
+		if len(sessionUndo) == 0 {
+			return "本次对话中没有可以撤销的操作"
+		}
+		id = sessionUndo[len(sessionUndo)-1]
+	}
+	entry, err := undoStore.Load(id)
+	if err != nil {
+		return err.Error()
+	}
+	result, err := undoAction(entry, "ask deepseek")
+	if err != nil {
+		return "撤销失败: " + err.Error()
+	}
+	for i, v := range sessionUndo {
+		if v == entry.ActionID {
+			sessionUndo = append(sessionUndo[:i], sessionUndo[i+1:]...)
+			break
+		}
+	}
+	return result
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+	undoCmd.Flags().BoolVarP(&undoYes, "yes", "y", false, "skip the confirmation")
+}
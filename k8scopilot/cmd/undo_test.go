@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// deletePod 通过 deleteResource 工具删除 Pod 并写入审计记录，和对话中的流程相同
+func deletePod(t *testing.T, name string) utils.AuditRecord {
+	t.Helper()
+	client, err := newLLMClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := `{"namespace":"shop","resource_type":"pod","resource_name":"` + name + `"}`
+	result, err := callFunction(client, "deleteResource", args)
+	record := utils.AuditRecord{Command: "ask deepseek", Tool: "deleteResource", Arguments: args}
+	auditOutcome(&record, result, err)
+	return recordAudit(record)
+}
+
+func TestUndoDelete(t *testing.T) {
+	setupOffline(t, "cluster")
+	clientGo, err := newClientGo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 删除时撤销信息应已写入文件
+	var saved *utils.UndoEntry
+	clientGo.DynamicClient.(*fakedynamic.FakeDynamicClient).PrependReactor("delete", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		if pendingUndo != nil {
+			saved, _ = undoStore.Load(pendingUndo.ActionID)
+		}
+		return false, nil, nil
+	})
+
+	record := deletePod(t, "web-abc-1")
+	if record.Result != utils.AuditSucceeded || !record.Undoable {
+		t.Fatalf("删除失败: %+v", record)
+	}
+	if saved == nil || !saved.Pending || saved.Context != "test" {
+		t.Fatalf("删除前应保存未确认的撤销信息: %+v", saved)
+	}
+	entry, err := undoStore.Load(record.ID)
+	if err != nil || entry.Pending {
+		t.Fatalf("删除成功后应确认撤销信息: %+v, %v", entry, err)
+	}
+
+	if _, err := undoAction(entry, "undo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientGo.DynamicClient.Resource(podsResource).Namespace("shop").Get(context.TODO(), "web-abc-1", metav1.GetOptions{}); err != nil {
+		t.Errorf("Pod 应被重新创建: %v", err)
+	}
+	if _, err := undoAction(entry, "undo"); err == nil || !strings.Contains(err.Error(), "已经被") {
+		t.Errorf("同一个操作只能撤销一次: %v", err)
+	}
+}
+
+func TestUndoDeleteFailed(t *testing.T) {
+	setupOffline(t, "cluster")
+	clientGo, err := newClientGo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actionID string
+	clientGo.DynamicClient.(*fakedynamic.FakeDynamicClient).PrependReactor("delete", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		actionID = pendingUndo.ActionID
+		return true, nil, errors.New("etcdserver: request timed out")
+	})
+
+	record := deletePod(t, "web-abc-1")
+	if record.Result != utils.AuditFailed || record.Undoable {
+		t.Fatalf("删除应失败: %+v", record)
+	}
+	// 变更没有执行，撤销信息被删除
+	if _, err := undoStore.Load(actionID); err == nil {
+		t.Errorf("删除失败后不应保留撤销信息 %s", actionID)
+	}
+}
+
+func TestApplyUndoContext(t *testing.T) {
+	setupOffline(t, "cluster")
+	clientGo, err := newClientGo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	configMaps := toolResources["configmap"]
+	tests := []struct {
+		name    string
+		context string
+		gvr     schema.GroupVersionResource
+		object  string
+		wantErr string
+	}{
+		{"其它集群上的操作", "prod", configMaps, "app-config", "请切换集群"},
+		// 旧版本保存的撤销信息没有记录集群
+		{"没有记录集群", "", podsResource, "web-abc-1", ""},
+		{"同一个集群", "test", configMaps, "app-config", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &utils.UndoEntry{ActionID: "20261019-abc", Operation: utils.UndoCreate, Namespace: "shop", Name: tt.object, Context: tt.context}
+			entry.SetGVR(tt.gvr)
+			_, err := applyUndo(entry)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("期望错误 %q，实际 %v", tt.wantErr, err)
+				}
+				// 拒绝时不能修改集群
+				if _, err := clientGo.DynamicClient.Resource(tt.gvr).Namespace("shop").Get(context.TODO(), tt.object, metav1.GetOptions{}); err != nil {
+					t.Errorf("%s 不应被删除: %v", tt.object, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("不应报错: %v", err)
+			}
+			if _, err := clientGo.DynamicClient.Resource(tt.gvr).Namespace("shop").Get(context.TODO(), tt.object, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+				t.Errorf("%s 应已被删除: %v", tt.object, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/spf13/cobra"
+)
+
+// usageCmd 汇总本地账本中的模型用量和费用
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "查看模型调用的 token 用量和费用",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := initSession(); err != nil {
+			fmt.Println("读取账本失败:", err)
+			return
+		}
+		records, err := utils.ReadUsageLedger(usage.Ledger())
+		if err != nil {
+			fmt.Println("读取账本失败:", err)
+			return
+		}
+		if usageSince > 0 {
+			cutoff := time.Now().Add(-usageSince)
+			filtered := records[:0]
+			for _, r := range records {
+				if r.Time.After(cutoff) {
+					filtered = append(filtered, r)
+				}
+			}
+			records = filtered
+		}
+		if len(records) == 0 {
+			fmt.Println("没有用量记录")
+			return
+		}
+		fmt.Println(utils.FormatUsage(records))
+	},
+}
+
+var usageSince time.Duration
+
+func init() {
+	rootCmd.AddCommand(usageCmd)
+	usageCmd.Flags().DurationVar(&usageSince, "since", 0, "only include calls newer than this duration, e.g. 24h")
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// AuditConfig 对应配置文件中的 audit 段
+type AuditConfig struct {
+	// 审计日志文件，默认 <dataDir>/audit.jsonl
+	File string `json:"file"`
+	// 不为空时每条记录同时以 JSON POST 到该地址
+	Webhook string `json:"webhook"`
+	// 配置后附带 HMAC-SHA256 签名，格式同通知渠道的 webhook
+	Secret string `json:"secret"`
+}
+
+// ConfirmationAuto 表示操作由模型在对话中直接执行，没有经过用户确认
+const ConfirmationAuto = "auto"
+
+// 审计记录的结果
+const (
+	AuditSucceeded = "succeeded"
+	AuditFailed    = "failed"
+	// 权限检查未通过，没有请求 apiserver
+	AuditForbidden = "forbidden"
+	// 被策略文件中的规则拒绝
+	AuditDenied = "denied"
+	// 用户在确认时拒绝
+	AuditDeclined = "declined"
+)
+
+// AuditRecord 是审计日志中的一条记录，对应 copilot 执行的一次操作
+type AuditRecord struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// 本机用户和 kubeconfig 上下文
+	User    string `json:"user"`
+	Context string `json:"context,omitempty"`
+	// 触发操作的命令，例如 ask deepseek
+	Command string `json:"command"`
+	// 用户输入，命令行操作时为空
+	Prompt    string `json:"prompt,omitempty"`
+	Tool      string `json:"tool"`
+	Arguments string `json:"arguments,omitempty"`
+	// 执行前的预览，例如补丁的 diff
+	DryRun string `json:"dryRun,omitempty"`
+	// 确认结果：confirmed、declined；对话中的工具由模型直接执行，记为 auto
+	Confirmation string `json:"confirmation,omitempty"`
+	Result       string `json:"result"`
+	// apiserver 的返回或错误信息
+	Response string `json:"response"`
+	// 决定执行该操作的模型，用户直接执行时为空
+	Model string `json:"model,omitempty"`
+	// 保存了变更前的状态，可以用 k8scopilot undo <id> 撤销
+	Undoable bool `json:"undoable,omitempty"`
+}
+
+// AuditLog 以 JSON Lines 追加写入审计记录，不提供修改和删除
+type AuditLog struct {
+	cfg    AuditConfig
+	file   string
+	client *http.Client
+	// 不为空时转发到 webhook 的记录先脱敏，本地文件保留原文
+	Redactor *Redactor
+}
+
+// NewAuditLog 创建审计日志
+func NewAuditLog(cfg AuditConfig, dataDir string) *AuditLog {
+	file := cfg.File
+	if file == "" {
+		file = filepath.Join(dataDir, "audit.jsonl")
+	}
+	return &AuditLog{cfg: cfg, file: expandHome(file), client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// File 返回审计日志文件路径
+func (a *AuditLog) File() string {
+	return a.file
+}
+
+// Record 补全 ID 和时间后追加到文件，并转发到 webhook
+// 转发失败不影响本地记录，返回的错误中会说明
+func (a *AuditLog) Record(ctx context.Context, r AuditRecord) (AuditRecord, error) {
+	if r.ID == "" {
+		r.ID = NewAuditID()
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return r, err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.file), 0o755); err != nil {
+		return r, err
+	}
+	// 审计日志可能包含用户输入，只允许本人读取
+	f, err := os.OpenFile(a.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return r, err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return r, err
+	}
+
+	if a.cfg.Webhook != "" {
+		// 用户输入、参数和预览可能包含密码、token 等，离开本机前脱敏
+		forward := r
+		forward.Prompt = a.Redactor.Redact(r.Prompt)
+		forward.Arguments = a.Redactor.Redact(r.Arguments)
+		forward.DryRun = a.Redactor.Redact(r.DryRun)
+		line, err := json.Marshal(forward)
+		if err != nil {
+			return r, err
+		}
+		headers := map[string]string{}
+		if a.cfg.Secret != "" {
+			headers[WebhookSignatureHeader] = "sha256=" + SignPayload(a.cfg.Secret, line)
+		}
+		if err := postJSON(ctx, a.client, a.cfg.Webhook, line, headers); err != nil {
+			return r, fmt.Errorf("转发审计记录失败: %w", err)
+		}
+	}
+	return r, nil
+}
+
+// NewAuditID 生成审计记录 ID，格式为 日期-随机数
+func NewAuditID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return time.Now().Format("20060102") + "-" + hex.EncodeToString(b)
+}
+
+// ReadAuditLog 读取审计日志，文件不存在时返回空
+func ReadAuditLog(path string) ([]AuditRecord, error) {
+	f, err := os.Open(expandHome(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var records []AuditRecord
+	scanner := bufio.NewScanner(f)
+	// 记录中包含用户输入和 diff，单行可能很长
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// 跳过写了一半的行
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLogRecord(t *testing.T) {
+	redactor, err := NewRedactor(RedactConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := AuditRecord{
+		Command:   "ask deepseek",
+		Prompt:    "用 password=hunter2 创建 Secret",
+		Tool:      "generateAndDeployResource",
+		Arguments: `{"user_input":"token: abc123"}`,
+		Result:    AuditSucceeded,
+	}
+	tests := []struct {
+		name, secret string
+		status       int
+		wantErr      string
+	}{
+		{"签名并脱敏后转发", "s3cret", http.StatusOK, ""},
+		{"没有 secret 时不签名", "", http.StatusOK, ""},
+		{"转发失败不影响本地记录", "", http.StatusInternalServerError, "转发审计记录失败"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			var signature string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				signature = r.Header.Get(WebhookSignatureHeader)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			dir := t.TempDir()
+			log := NewAuditLog(AuditConfig{Webhook: server.URL, Secret: tt.secret}, dir)
+			log.Redactor = redactor
+			got, err := log.Record(context.Background(), record)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("期望错误 %q，实际 %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID == "" || got.Time.IsZero() {
+				t.Errorf("应补全 ID 和时间: %+v", got)
+			}
+
+			// 本地文件保留原文
+			local, err := ReadAuditLog(filepath.Join(dir, "audit.jsonl"))
+			if err != nil || len(local) != 1 || local[0].Prompt != record.Prompt || local[0].ID != got.ID {
+				t.Fatalf("本地记录不对: %+v, %v", local, err)
+			}
+			// 转发的内容已脱敏
+			var forwarded AuditRecord
+			if err := json.Unmarshal(body, &forwarded); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(body), "hunter2") || strings.Contains(string(body), "abc123") || forwarded.ID != got.ID {
+				t.Errorf("转发的记录没有脱敏: %s", body)
+			}
+			want := ""
+			if tt.secret != "" {
+				want = "sha256=" + SignPayload(tt.secret, body)
+			}
+			if signature != want {
+				t.Errorf("签名 %q，期望 %q", signature, want)
+			}
+		})
+	}
+}
+
+func TestReadAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if records, err := ReadAuditLog(path); err != nil || records != nil {
+		t.Errorf("文件不存在时应返回空: %v, %v", records, err)
+	}
+	// 写了一半的行被跳过
+	content := `{"id":"20261019-a","tool":"deleteResource","result":"succeeded"}` + "\n" + `{"id":"20261019-b","to` + "\n" + `{"id":"20261019-c","tool":"undo","result":"failed"}` + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	records, err := ReadAuditLog(path)
+	if err != nil || len(records) != 2 || records[0].ID != "20261019-a" || records[1].ID != "20261019-c" {
+		t.Errorf("ReadAuditLog = %+v, %v", records, err)
+	}
+}
@@ -0,0 +1,262 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CacheConfig 对应配置文件中的 cache 段
+type CacheConfig struct {
+	Disabled bool `json:"disabled"`
+	// 缓存目录，默认 <dataDir>/cache
+	Dir string `json:"dir"`
+	// 缓存有效期，默认 24h
+	TTL *metav1.Duration `json:"ttl"`
+}
+
+// CacheEntry 是缓存目录中的一个文件
+type CacheEntry struct {
+	Key           string                        `json:"key"`
+	Model         string                        `json:"model"`
+	PromptVersion string                        `json:"promptVersion,omitempty"`
+	CreatedAt     time.Time                     `json:"createdAt"`
+	Response      openai.ChatCompletionResponse `json:"response"`
+}
+
+// CacheStats 是缓存的统计信息
+type CacheStats struct {
+	Entries int
+	Expired int
+	Bytes   int64
+	Hits    int `json:"hits"`
+	Misses  int `json:"misses"`
+}
+
+// ResponseCache 按证据指纹缓存模型回复，相同的问题不再重复付费
+type ResponseCache struct {
+	dir string
+	ttl time.Duration
+	mu  sync.Mutex
+}
+
+const cacheStatsFile = "stats.json"
+
+// NewResponseCache 根据配置创建缓存，配置关闭时返回 nil
+func NewResponseCache(cfg CacheConfig, dataDir string) *ResponseCache {
+	if cfg.Disabled {
+		return nil
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Join(dataDir, "cache")
+	}
+	ttl := 24 * time.Hour
+	if cfg.TTL != nil {
+		ttl = cfg.TTL.Duration
+	}
+	return &ResponseCache{dir: expandHome(dir), ttl: ttl}
+}
+
+var (
+	// 事件和日志行开头的时间，事件的时间可能是 "开始 ~ 结束" 的范围
+	leadingTimestampPattern = regexp.MustCompile(`^(\s*-\s*)?` + timestampPattern.String() + `( ~ (\d{4}-\d{2}-\d{2} )?\d{2}:\d{2}:\d{2})?`)
+	// 事件和日志中 Go 格式的时长，例如 back-off 5m0s，必须以 s 结尾，不会匹配 500m 这样的 CPU 数量
+	eventDurationPattern = regexp.MustCompile(`\b(\d+h)?(\d+m)?\d+(\.\d+)?(ms|s)\b`)
+	// 折叠后的事件次数，例如 x12
+	eventCountPattern = regexp.MustCompile(`\bx\d+\b`)
+	// Pod 名字中随机生成的后缀，例如 web-7d9f8c6b4-x2k5z 中的 -7d9f8c6b4-x2k5z
+	// 只匹配 Kubernetes 生成名字时使用的字符（不含元音），不会截掉普通单词
+	podSuffixPattern  = regexp.MustCompile(`-([bcdfghjklmnpqrstvwxz2456789]{6,10}-)?[bcdfghjklmnpqrstvwxz2456789]{5}\b`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// normalizeCacheContent 去掉每次都会变化、但不影响诊断的内容
+// 时间、时长和事件次数只在以时间开头的事件和日志行中去掉，Pod 配置等其余内容原样参与指纹，配置变化后不会命中旧的诊断
+// Pod 名字的随机后缀在所有内容中去掉，同一工作负载重建出的 Pod 仍然命中
+func normalizeCacheContent(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		loc := leadingTimestampPattern.FindStringSubmatchIndex(line)
+		if loc != nil {
+			prefix := ""
+			if loc[2] >= 0 {
+				prefix = line[loc[2]:loc[3]]
+			}
+			rest := eventDurationPattern.ReplaceAllString(line[loc[1]:], "")
+			line = prefix + eventCountPattern.ReplaceAllString(rest, "")
+		}
+		lines[i] = podSuffixPattern.ReplaceAllString(line, "")
+	}
+	return whitespacePattern.ReplaceAllString(strings.TrimSpace(strings.Join(lines, "\n")), " ")
+}
+
+// Key 计算请求的证据指纹：模型、模板版本、生成参数和（已脱敏的）消息内容归一化后的哈希
+// 带工具定义的请求可能触发变更操作，不参与缓存，返回空字符串
+func (c *ResponseCache) Key(ctx context.Context, req openai.ChatCompletionRequest) string {
+	if c == nil || len(req.Tools) > 0 || len(req.Functions) > 0 {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00%g\x00", req.Model, promptVersionFrom(ctx), req.MaxTokens, req.Temperature)
+	for _, m := range req.Messages {
+		h.Write([]byte(m.Role + "\x00" + normalizeCacheContent(m.Content) + "\x00"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get 读取未过期的缓存
+func (c *ResponseCache) Get(key string) (openai.ChatCompletionResponse, bool) {
+	if c == nil || key == "" {
+		return openai.ChatCompletionResponse{}, false
+	}
+	var entry CacheEntry
+	data, err := os.ReadFile(c.path(key))
+	hit := err == nil && json.Unmarshal(data, &entry) == nil && time.Since(entry.CreatedAt) < c.ttl
+	c.count(hit)
+	if !hit {
+		return openai.ChatCompletionResponse{}, false
+	}
+	return entry.Response, true
+}
+
+// Put 写入缓存
+func (c *ResponseCache) Put(ctx context.Context, key string, req openai.ChatCompletionRequest, resp openai.ChatCompletionResponse) error {
+	if c == nil || key == "" {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(CacheEntry{
+		Key:           key,
+		Model:         req.Model,
+		PromptVersion: promptVersionFrom(ctx),
+		CreatedAt:     time.Now(),
+		Response:      resp,
+	})
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再改名，避免并发读到写了一半的内容
+	tmp := c.path(key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path(key))
+}
+
+// Stats 统计缓存条目和命中情况
+func (c *ResponseCache) Stats() (CacheStats, error) {
+	var stats CacheStats
+	if data, err := os.ReadFile(filepath.Join(c.dir, cacheStatsFile)); err == nil {
+		_ = json.Unmarshal(data, &stats)
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stats, nil
+		}
+		return stats, err
+	}
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") || e.Name() == cacheStatsFile {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		stats.Entries++
+		stats.Bytes += info.Size()
+		if time.Since(info.ModTime()) >= c.ttl {
+			stats.Expired++
+		}
+	}
+	return stats, nil
+}
+
+// Clear 删除缓存，expiredOnly 为 true 时只删除过期条目，返回删除的条目数
+func (c *ResponseCache) Clear(expiredOnly bool) (int, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		if e.Name() == cacheStatsFile && expiredOnly {
+			continue
+		}
+		if expiredOnly {
+			info, err := e.Info()
+			if err != nil || time.Since(info.ModTime()) < c.ttl {
+				continue
+			}
+		}
+		if err := os.Remove(filepath.Join(c.dir, e.Name())); err != nil {
+			return removed, err
+		}
+		if e.Name() != cacheStatsFile {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// 命中统计只用于 cache stats 展示，写失败时忽略
+func (c *ResponseCache) count(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	path := filepath.Join(c.dir, cacheStatsFile)
+	var stats CacheStats
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &stats)
+	}
+	if hit {
+		stats.Hits++
+	} else {
+		stats.Misses++
+	}
+	data, _ := json.Marshal(struct {
+		Hits   int `json:"hits"`
+		Misses int `json:"misses"`
+	}{stats.Hits, stats.Misses})
+	if err := os.MkdirAll(c.dir, 0o755); err == nil {
+		_ = os.WriteFile(path, data, 0o644)
+	}
+}
+
+type promptVersionKey struct{}
+
+// WithPromptVersion 记录本次请求使用的提示词模板版本，模板变化后缓存自动失效
+func WithPromptVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, promptVersionKey{}, version)
+}
+
+func promptVersionFrom(ctx context.Context) string {
+	if v, ok := ctx.Value(promptVersionKey{}).(string); ok {
+		return v
+	}
+	return ""
+}
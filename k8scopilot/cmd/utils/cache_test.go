@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestNormalizeCacheContent(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{
+			"- 2026-10-19 08:00:00 ~ 08:06:00 Pod/web-7d9f8c6b4-x2k5z Warning BackOff (kubelet) x12: Back-off 5m0s restarting failed container",
+			"- Pod/web Warning BackOff (kubelet) : Back-off restarting failed container",
+		},
+		{"2026-10-19T08:05:00Z ERROR dial tcp db2:5432: connection refused", "ERROR dial tcp db2:5432: connection refused"},
+		// 不以时间开头的行保留次数和时长
+		{"restartCount: 4\nlimits: {cpu: 500m, memory: 2s}", "restartCount: 4 limits: {cpu: 500m, memory: 2s}"},
+		{"所属工作负载: Deployment/web，共 2 个 Pod 出现异常（web-7d9f8c6b4-x2k5z, web-7d9f8c6b4-q8wvn）", "所属工作负载: Deployment/web，共 2 个 Pod 出现异常（web, web）"},
+		// 普通单词和 StatefulSet 的序号不是随机后缀
+		{"Pod: shop/cache-server-0 node-exporter", "Pod: shop/cache-server-0 node-exporter"},
+	}
+	for _, tt := range tests {
+		if got := normalizeCacheContent(tt.in); got != tt.want {
+			t.Errorf("normalizeCacheContent(%q)\n got %q\nwant %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	cache := NewResponseCache(CacheConfig{}, t.TempDir())
+	ctx := WithPromptVersion(context.Background(), "pod_analysis@3")
+	request := func(prompt string) openai.ChatCompletionRequest {
+		return openai.ChatCompletionRequest{
+			Model:    "gpt-4o",
+			Messages: []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleUser, Content: prompt}},
+		}
+	}
+	run1 := "Pod: shop/web-7d9f8c6b4-x2k5z\n事件时间线:\n- 2026-10-19 08:00:00 ~ 08:06:00 Pod/web-7d9f8c6b4-x2k5z Warning BackOff (kubelet) x4: Back-off restarting failed container\nrestartCount: 4"
+	// 只有时间、事件次数和 Pod 后缀不同
+	run2 := "Pod: shop/web-7d9f8c6b4-q8wvn\n事件时间线:\n- 2026-10-19 09:10:00 ~ 09:31:00 Pod/web-7d9f8c6b4-q8wvn Warning BackOff (kubelet) x27: Back-off restarting failed container\nrestartCount: 4"
+	key := cache.Key(ctx, request(run1))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		req  openai.ChatCompletionRequest
+		same bool
+	}{
+		{"只有次数不同", ctx, request(run2), true},
+		{"Pod 配置不同", ctx, request(run1[:len(run1)-1] + "5"), false},
+		{"模板版本不同", WithPromptVersion(context.Background(), "pod_analysis@4"), request(run1), false},
+		{"生成参数不同", ctx, func() openai.ChatCompletionRequest { r := request(run1); r.MaxTokens = 100; return r }(), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cache.Key(tt.ctx, tt.req); (got == key) != tt.same {
+				t.Errorf("key 相同=%v，期望 %v", got == key, tt.same)
+			}
+		})
+	}
+
+	// 带工具的请求不缓存
+	withTools := request(run1)
+	withTools.Tools = []openai.Tool{{Type: openai.ToolTypeFunction, Function: &openai.FunctionDefinition{Name: "deleteResource"}}}
+	if k := cache.Key(ctx, withTools); k != "" {
+		t.Errorf("带工具的请求不应缓存: %q", k)
+	}
+}
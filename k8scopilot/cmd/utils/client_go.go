@@ -1,20 +1,35 @@
 package utils
 
 import (
+	"context"
+	"io"
 	"path/filepath"
 	"strings"
+	"sync"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/discovery"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
 )
 
 type ClientGo struct {
-	ClientSet       *kubernetes.Clientset
+	ClientSet       kubernetes.Interface
 	DynamicClient   dynamic.Interface
 	DiscoveryClient discovery.DiscoveryInterface
+	// MetadataClient 只读取对象的元数据，用于不需要也不应该读取内容的对象（例如 Secret）
+	MetadataClient metadata.Interface
+	// LogReader 不为空时代替 apiserver 读取容器日志，用于假集群
+	LogReader func(namespace, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error)
+	// NoDryRun 表示不支持 dry-run 请求，假集群会直接写入 dry-run 的对象
+	NoDryRun bool
+
+	// HasEventsV1 的结果，只查询一次 discovery
+	eventsV1Once sync.Once
+	eventsV1     bool
 }
 
 func NewClientGo(kubeconfig string) (*ClientGo, error) {
@@ -39,9 +54,34 @@ func NewClientGo(kubeconfig string) (*ClientGo, error) {
 	if err != nil {
 		return nil, err
 	}
+	metadataClient, err := metadata.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
 	return &ClientGo{
 		ClientSet:       clientSet,
 		DynamicClient:   dynamicClient,
 		DiscoveryClient: discoveryClient,
+		MetadataClient:  metadataClient,
 	}, nil
 }
+
+// PodLogs 读取容器日志
+func (c *ClientGo) PodLogs(ctx context.Context, namespace, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	if c.LogReader != nil {
+		return c.LogReader(namespace, podName, opts)
+	}
+	return c.ClientSet.CoreV1().Pods(namespace).GetLogs(podName, opts).Stream(ctx)
+}
+
+// CurrentContext 返回 kubeconfig 中当前使用的 context 名称，读取失败时返回空字符串
+func CurrentContext(kubeconfig string) string {
+	if strings.HasPrefix(kubeconfig, "~") {
+		kubeconfig = filepath.Join(homedir.HomeDir(), kubeconfig[1:])
+	}
+	config, err := clientcmd.LoadFromFile(kubeconfig)
+	if err != nil {
+		return ""
+	}
+	return config.CurrentContext
+}
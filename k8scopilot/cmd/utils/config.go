@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
+)
+
+// Config 对应 ~/.k8scopilot.yaml 配置文件
+type Config struct {
+	// 账本、缓存等本地数据的目录，默认 ~/.k8scopilot
+	DataDir string `json:"dataDir"`
+
+	Notify    NotifyConfig   `json:"notify"`
+	Redact    RedactConfig   `json:"redact"`
+	Prompts   PromptConfig   `json:"prompts"`
+	Budget    BudgetConfig   `json:"budget"`
+	Usage     UsageConfig    `json:"usage"`
+	Cache     CacheConfig    `json:"cache"`
+	Audit     AuditConfig    `json:"audit"`
+	Policy    PolicyConfig   `json:"policy"`
+	Runbooks  RunbookConfig  `json:"runbooks"`
+	Incidents IncidentConfig `json:"incidents"`
+}
+
+// LoadConfig 读取配置文件，文件不存在时返回空配置
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if path == "" {
+		return cfg, nil
+	}
+	path = expandHome(path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// DataPath 返回本地数据目录下的路径
+func (c *Config) DataPath(elem ...string) string {
+	dir := c.DataDir
+	if dir == "" {
+		dir = filepath.Join(homedir.HomeDir(), ".k8scopilot")
+	}
+	return filepath.Join(append([]string{expandHome(dir)}, elem...)...)
+}
+
+// DefaultConfigPath 返回默认配置文件路径 $HOME/.k8scopilot.yaml
+func DefaultConfigPath() string {
+	return filepath.Join(homedir.HomeDir(), ".k8scopilot.yaml")
+}
+
+// 展开 ~ 开头的路径
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~") {
+		return filepath.Join(homedir.HomeDir(), path[1:])
+	}
+	return path
+}
@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 差异前后保留的上下文行数
+const diffContext = 3
+
+// LineDiff 按行比较两段文本，输出类似 diff -u 的结果，没有差异时返回空串
+func LineDiff(before, after string) string {
+	a := strings.Split(strings.TrimRight(before, "\n"), "\n")
+	b := strings.Split(strings.TrimRight(after, "\n"), "\n")
+
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+		// 在 before/after 中的行号，从 1 开始
+		a, b int
+	}
+	var lines []line
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i], i + 1, j + 1})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i], i + 1, j + 1})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j], i + 1, j + 1})
+			j++
+		}
+	}
+
+	var sb strings.Builder
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+		// 向后合并间隔不超过两倍上下文的改动
+		end := start
+		for k := start; k < len(lines) && k-end <= 2*diffContext; k++ {
+			if lines[k].op != ' ' {
+				end = k
+			}
+		}
+		from := max(start-diffContext, 0)
+		to := min(end+diffContext+1, len(lines))
+		var na, nb int
+		for _, l := range lines[from:to] {
+			if l.op != '+' {
+				na++
+			}
+			if l.op != '-' {
+				nb++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", lines[from].a, na, lines[from].b, nb)
+		for _, l := range lines[from:to] {
+			fmt.Fprintf(&sb, "%c %s\n", l.op, l.text)
+		}
+		start = to
+	}
+	return sb.String()
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Event 是从 core/v1 或 events.k8s.io/v1 读取的事件
+// 同一对象上类型、原因和内容都相同的事件（包括事件序列）折叠为一条
+type Event struct {
+	// 事件涉及的对象
+	Kind      string
+	Namespace string
+	Name      string
+	// 相关的另一个对象，例如抢占时的 Node，格式为 Kind/name
+	Related string
+	Type    string
+	Reason  string
+	Message string
+	// 上报事件的组件，例如 kubelet、default-scheduler
+	Source string
+	// 折叠后的总次数
+	Count int32
+	// 第一次和最后一次发生的时间，旧版事件可能为零
+	First time.Time
+	Last  time.Time
+}
+
+// Object 返回事件涉及的对象，例如 Pod/shop/web-1
+func (e Event) Object() string {
+	if e.Namespace == "" {
+		return e.Kind + "/" + e.Name
+	}
+	return e.Kind + "/" + e.Namespace + "/" + e.Name
+}
+
+// String 返回时间线中的一行，例如
+// 2026-10-19 10:00:01 ~ 10:05:30 Pod/web-1 Warning BackOff (kubelet) x12: Back-off restarting failed container
+func (e Event) String() string {
+	return fmt.Sprintf("%s %s/%s %s", e.timeRange(), e.Kind, e.Name, e.Summary())
+}
+
+// Summary 返回不含时间和对象的事件描述，例如 Warning BackOff (kubelet) x12: Back-off restarting failed container
+func (e Event) Summary() string {
+	var b strings.Builder
+	b.WriteString(e.Type)
+	if e.Reason != "" {
+		b.WriteString(" " + e.Reason)
+	}
+	if e.Source != "" {
+		fmt.Fprintf(&b, " (%s)", e.Source)
+	}
+	if e.Count > 1 {
+		fmt.Fprintf(&b, " x%d", e.Count)
+	}
+	b.WriteString(": " + e.Message)
+	if e.Related != "" {
+		b.WriteString(" [相关对象 " + e.Related + "]")
+	}
+	return b.String()
+}
+
+func (e Event) timeRange() string {
+	const layout = "2006-01-02 15:04:05"
+	switch {
+	case e.Last.IsZero():
+		return "时间未知"
+	case e.First.IsZero() || !e.First.Before(e.Last):
+		return e.Last.Local().Format(layout)
+	case e.First.Local().YearDay() == e.Last.Local().YearDay() && e.First.Year() == e.Last.Year():
+		return e.First.Local().Format(layout) + " ~ " + e.Last.Local().Format("15:04:05")
+	}
+	return e.First.Local().Format(layout) + " ~ " + e.Last.Local().Format(layout)
+}
+
+// NewCoreEvent 转换 core/v1 事件，没有 lastTimestamp 时依次使用事件序列、eventTime 和创建时间
+func NewCoreEvent(e *corev1.Event) Event {
+	out := Event{
+		Kind:      e.InvolvedObject.Kind,
+		Namespace: e.InvolvedObject.Namespace,
+		Name:      e.InvolvedObject.Name,
+		Type:      e.Type,
+		Reason:    e.Reason,
+		Message:   e.Message,
+		Source:    e.ReportingController,
+		Count:     e.Count,
+		First:     e.FirstTimestamp.Time,
+		Last:      e.LastTimestamp.Time,
+	}
+	if out.Source == "" {
+		out.Source = e.Source.Component
+	}
+	if e.Related != nil {
+		out.Related = e.Related.Kind + "/" + e.Related.Name
+	}
+	if e.Series != nil {
+		out.Count = max(out.Count, e.Series.Count)
+		if out.Last.IsZero() {
+			out.Last = e.Series.LastObservedTime.Time
+		}
+	}
+	if out.First.IsZero() {
+		out.First = e.EventTime.Time
+	}
+	if out.Last.IsZero() {
+		out.Last = out.First
+	}
+	if out.Last.IsZero() {
+		out.First, out.Last = e.CreationTimestamp.Time, e.CreationTimestamp.Time
+	}
+	return out
+}
+
+// NewEventsV1 转换 events.k8s.io/v1 事件
+func NewEventsV1(e *eventsv1.Event) Event {
+	out := Event{
+		Kind:      e.Regarding.Kind,
+		Namespace: e.Regarding.Namespace,
+		Name:      e.Regarding.Name,
+		Type:      e.Type,
+		Reason:    e.Reason,
+		Message:   e.Note,
+		Source:    e.ReportingController,
+		Count:     e.DeprecatedCount,
+		First:     e.EventTime.Time,
+		Last:      e.DeprecatedLastTimestamp.Time,
+	}
+	if out.Source == "" {
+		out.Source = e.DeprecatedSource.Component
+	}
+	if e.Related != nil {
+		out.Related = e.Related.Kind + "/" + e.Related.Name
+	}
+	if out.First.IsZero() {
+		out.First = e.DeprecatedFirstTimestamp.Time
+	}
+	if e.Series != nil {
+		out.Count = max(out.Count, e.Series.Count)
+		out.Last = e.Series.LastObservedTime.Time
+	}
+	if out.Last.IsZero() {
+		out.Last = out.First
+	}
+	if out.Last.IsZero() {
+		out.First, out.Last = e.CreationTimestamp.Time, e.CreationTimestamp.Time
+	}
+	return out
+}
+
+// HasEventsV1 判断 apiserver 是否提供 events.k8s.io/v1，结果在 ClientGo 上缓存
+func (c *ClientGo) HasEventsV1() bool {
+	c.eventsV1Once.Do(func() {
+		_, err := c.DiscoveryClient.ServerResourcesForGroupVersion(eventsv1.SchemeGroupVersion.String())
+		c.eventsV1 = err == nil
+	})
+	return c.eventsV1
+}
+
+// ListEvents 读取命名空间中的事件并折叠，namespace 为空表示全部命名空间
+// 优先使用 events.k8s.io/v1，不可用或没有权限时使用 core/v1；warningOnly 时只返回 Warning 事件
+func ListEvents(ctx context.Context, c *ClientGo, namespace string, warningOnly bool) ([]Event, error) {
+	return listEvents(ctx, c, namespace, nil, nil, warningOnly)
+}
+
+// ListObjectEvents 只读取单个对象的事件并折叠，按对象过滤，不需要列出整个命名空间的事件
+// 集群级对象（例如 Node）的 namespace 为空
+func ListObjectEvents(ctx context.Context, c *ClientGo, kind, namespace, name string, warningOnly bool) ([]Event, error) {
+	events, err := listEvents(ctx, c, namespace,
+		[]string{"regarding.kind=" + kind, "regarding.name=" + name},
+		[]string{"involvedObject.kind=" + kind, "involvedObject.name=" + name},
+		warningOnly)
+	if err != nil {
+		return nil, err
+	}
+	// 不支持字段选择器的客户端（例如离线调试用的 fake）会返回全部事件
+	var out []Event
+	for _, e := range events {
+		if e.Kind == kind && e.Name == name {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// listEvents 按两个 API 各自的字段选择器读取事件
+func listEvents(ctx context.Context, c *ClientGo, namespace string, v1Fields, coreFields []string, warningOnly bool) ([]Event, error) {
+	if warningOnly {
+		v1Fields = append(v1Fields, "type="+corev1.EventTypeWarning)
+		coreFields = append(coreFields, "type="+corev1.EventTypeWarning)
+	}
+	var events []Event
+	if c.HasEventsV1() {
+		opts := metav1.ListOptions{FieldSelector: strings.Join(v1Fields, ",")}
+		if list, err := c.ClientSet.EventsV1().Events(namespace).List(ctx, opts); err == nil {
+			for i := range list.Items {
+				events = append(events, NewEventsV1(&list.Items[i]))
+			}
+			return FoldEvents(events, warningOnly), nil
+		}
+	}
+	opts := metav1.ListOptions{FieldSelector: strings.Join(coreFields, ",")}
+	list, err := c.ClientSet.CoreV1().Events(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list.Items {
+		events = append(events, NewCoreEvent(&list.Items[i]))
+	}
+	return FoldEvents(events, warningOnly), nil
+}
+
+// FoldEvents 合并同一对象上类型、原因和内容相同的事件，累加次数并取最早和最晚的时间
+// 返回结果按最后一次发生的时间排序
+func FoldEvents(events []Event, warningOnly bool) []Event {
+	type key struct{ object, typ, reason, message string }
+	index := map[key]int{}
+	var out []Event
+	for _, e := range events {
+		if warningOnly && e.Type != corev1.EventTypeWarning {
+			continue
+		}
+		e.Count = max(e.Count, 1)
+		k := key{e.Object(), e.Type, e.Reason, e.Message}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(out)
+			out = append(out, e)
+			continue
+		}
+		folded := &out[i]
+		folded.Count += e.Count
+		if !e.First.IsZero() && (folded.First.IsZero() || e.First.Before(folded.First)) {
+			folded.First = e.First
+		}
+		if e.Last.After(folded.Last) {
+			folded.Last = e.Last
+		}
+		if folded.Source == "" {
+			folded.Source = e.Source
+		}
+		if folded.Related == "" {
+			folded.Related = e.Related
+		}
+	}
+	SortEvents(out)
+	return out
+}
+
+// SortEvents 按时间排序，时间相同或未知时保持原来的顺序
+func SortEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Last.Before(events[j].Last)
+	})
+}
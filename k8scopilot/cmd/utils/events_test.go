@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+)
+
+func TestFoldEvents(t *testing.T) {
+	at := func(minute int) time.Time { return time.Date(2026, 10, 19, 8, minute, 0, 0, time.UTC) }
+	backOff := Event{Kind: "Pod", Namespace: "shop", Name: "web-1", Type: "Warning", Reason: "BackOff", Message: "Back-off restarting failed container"}
+	tests := []struct {
+		name        string
+		events      []Event
+		warningOnly bool
+		want        []string
+	}{
+		{
+			name: "相同事件累加次数并取最早和最晚的时间",
+			events: []Event{
+				with(backOff, 3, at(5), at(6)),
+				with(backOff, 2, at(1), at(2)),
+			},
+			want: []string{"2026-10-19 08:01:00 ~ 08:06:00 Pod/web-1 Warning BackOff x5: Back-off restarting failed container"},
+		},
+		{
+			name: "不同对象不合并，按最后发生的时间排序",
+			events: []Event{
+				with(backOff, 1, at(5), at(5)),
+				with(Event{Kind: "Pod", Namespace: "shop", Name: "web-2", Type: "Warning", Reason: "BackOff", Message: "Back-off restarting failed container"}, 1, at(3), at(3)),
+			},
+			want: []string{
+				"2026-10-19 08:03:00 Pod/web-2 Warning BackOff: Back-off restarting failed container",
+				"2026-10-19 08:05:00 Pod/web-1 Warning BackOff: Back-off restarting failed container",
+			},
+		},
+		{
+			name: "只保留 Warning",
+			events: []Event{
+				with(Event{Kind: "Deployment", Namespace: "shop", Name: "web", Type: "Normal", Reason: "ScalingReplicaSet", Message: "Scaled up"}, 1, at(1), at(1)),
+				with(backOff, 1, at(2), at(2)),
+			},
+			warningOnly: true,
+			want:        []string{"2026-10-19 08:02:00 Pod/web-1 Warning BackOff: Back-off restarting failed container"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FoldEvents(tt.events, tt.warningOnly)
+			if len(got) != len(tt.want) {
+				t.Fatalf("期望 %d 条，实际 %d 条: %v", len(tt.want), len(got), got)
+			}
+			for i, e := range got {
+				if e.String() != tt.want[i] {
+					t.Errorf("第 %d 条:\n got %s\nwant %s", i, e, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func with(e Event, count int32, first, last time.Time) Event {
+	e.Count, e.First, e.Last = count, first, last
+	return e
+}
+
+func TestListObjectEvents(t *testing.T) {
+	event := func(name, kind, object string) runtime.Object {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "shop"},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Name: object, Namespace: "shop"},
+			Type:           corev1.EventTypeWarning,
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container",
+		}
+	}
+	c := NewFakeClientGo([]runtime.Object{
+		event("e1", "Pod", "web-1"),
+		event("e2", "Pod", "web-2"),
+		event("e3", "Deployment", "web-1"),
+	}, nil)
+
+	events, err := ListObjectEvents(context.TODO(), c, "Pod", "shop", "web-1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Object() != "Pod/shop/web-1" {
+		t.Errorf("应只返回 Pod/shop/web-1 的事件: %v", events)
+	}
+
+	// discovery 只查询一次
+	if _, err := ListEvents(context.TODO(), c, "shop", false); err != nil {
+		t.Fatal(err)
+	}
+	discovery := c.DiscoveryClient.(*fakediscovery.FakeDiscovery)
+	lookups := 0
+	for _, action := range discovery.Actions() {
+		if action.GetResource().Resource == "resource" {
+			lookups++
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("HasEventsV1 应只查询一次 discovery，实际 %d 次", lookups)
+	}
+}
@@ -0,0 +1,252 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	fakemetadata "k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/testing"
+)
+
+// 假集群的 discovery 信息，覆盖 k8scopilot 会用到的资源
+var fakeAPIResources = []*metav1.APIResourceList{
+	{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "pods", Kind: "Pod", Namespaced: true, Verbs: allVerbs},
+			{Name: "services", Kind: "Service", Namespaced: true, Verbs: allVerbs},
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: allVerbs},
+			{Name: "secrets", Kind: "Secret", Namespaced: true, Verbs: allVerbs},
+			{Name: "events", Kind: "Event", Namespaced: true, Verbs: allVerbs},
+			{Name: "persistentvolumeclaims", Kind: "PersistentVolumeClaim", Namespaced: true, Verbs: allVerbs},
+			{Name: "serviceaccounts", Kind: "ServiceAccount", Namespaced: true, Verbs: allVerbs},
+			{Name: "endpoints", Kind: "Endpoints", Namespaced: true, Verbs: allVerbs},
+			{Name: "nodes", Kind: "Node", Verbs: allVerbs},
+			{Name: "namespaces", Kind: "Namespace", Verbs: allVerbs},
+			{Name: "persistentvolumes", Kind: "PersistentVolume", Verbs: allVerbs},
+		},
+	},
+	{
+		GroupVersion: "apps/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: allVerbs},
+			{Name: "statefulsets", Kind: "StatefulSet", Namespaced: true, Verbs: allVerbs},
+			{Name: "daemonsets", Kind: "DaemonSet", Namespaced: true, Verbs: allVerbs},
+			{Name: "replicasets", Kind: "ReplicaSet", Namespaced: true, Verbs: allVerbs},
+		},
+	},
+	{
+		GroupVersion: "discovery.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "endpointslices", Kind: "EndpointSlice", Namespaced: true, Verbs: allVerbs},
+		},
+	},
+	{
+		GroupVersion: "networking.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "ingresses", Kind: "Ingress", Namespaced: true, Verbs: allVerbs},
+			{Name: "networkpolicies", Kind: "NetworkPolicy", Namespaced: true, Verbs: allVerbs},
+		},
+	},
+	{
+		GroupVersion: "storage.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "storageclasses", Kind: "StorageClass", Verbs: allVerbs},
+			{Name: "volumeattachments", Kind: "VolumeAttachment", Verbs: allVerbs},
+		},
+	},
+	{
+		GroupVersion: "rbac.authorization.k8s.io/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "roles", Kind: "Role", Namespaced: true, Verbs: allVerbs},
+			{Name: "rolebindings", Kind: "RoleBinding", Namespaced: true, Verbs: allVerbs},
+			{Name: "clusterroles", Kind: "ClusterRole", Verbs: allVerbs},
+			{Name: "clusterrolebindings", Kind: "ClusterRoleBinding", Verbs: allVerbs},
+		},
+	},
+	{
+		GroupVersion: "batch/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "jobs", Kind: "Job", Namespaced: true, Verbs: allVerbs},
+			{Name: "cronjobs", Kind: "CronJob", Namespaced: true, Verbs: allVerbs},
+		},
+	},
+}
+
+var allVerbs = metav1.Verbs{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+// NewFakeClientGo 用给定对象构造一个内存中的假集群，logs 的 key 为 namespace/pod
+// 注意 ClientSet 和 DynamicClient 各自维护一份对象，通过其中一个做的修改另一个看不到
+func NewFakeClientGo(objects []runtime.Object, logs map[string]string) *ClientGo {
+	typed := make([]runtime.Object, 0, len(objects))
+	dynamicObjs := make([]runtime.Object, 0, len(objects))
+	metadataObjs := make([]runtime.Object, 0, len(objects))
+	for _, obj := range objects {
+		if m := partialMetadata(obj); m != nil {
+			metadataObjs = append(metadataObjs, m)
+		}
+		// CRD 等未知类型只能放进 DynamicClient
+		if _, ok := obj.(*unstructured.Unstructured); !ok {
+			typed = append(typed, obj.DeepCopyObject())
+		}
+		dynamicObjs = append(dynamicObjs, obj.DeepCopyObject())
+	}
+	clientSet := fake.NewSimpleClientset(typed...)
+	// 默认允许所有操作，测试可以再添加按 RBAC 对象回答的 reactor
+	clientSet.PrependReactor("create", "selfsubjectaccessreviews", allowAccessReactor)
+	discoveryClient := clientSet.Discovery().(*fakediscovery.FakeDiscovery)
+	discoveryClient.Resources = fakeAPIResources
+	metadataScheme := fakemetadata.NewTestScheme()
+	metav1.AddMetaToScheme(metadataScheme)
+
+	return &ClientGo{
+		ClientSet:       clientSet,
+		DynamicClient:   fakedynamic.NewSimpleDynamicClient(scheme.Scheme, dynamicObjs...),
+		DiscoveryClient: discoveryClient,
+		MetadataClient:  fakemetadata.NewSimpleMetadataClient(metadataScheme, metadataObjs...),
+		NoDryRun:        true,
+		LogReader: func(namespace, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+			content, ok := logs[namespace+"/"+podName]
+			if !ok {
+				return nil, fmt.Errorf("pod %s/%s 没有日志", namespace, podName)
+			}
+			return io.NopCloser(strings.NewReader(tailLines(content, opts))), nil
+		},
+	}
+}
+
+// NewReadOnlyClientGo 和 NewFakeClientGo 相同，但拒绝所有写操作，用于回放快照
+// 权限检查对读操作返回允许、对写操作返回拒绝，因此不会向模型提供修改集群的工具
+func NewReadOnlyClientGo(objects []runtime.Object, logs map[string]string) *ClientGo {
+	c := NewFakeClientGo(objects, logs)
+	clientSet := c.ClientSet.(*fake.Clientset)
+	for _, f := range []*testing.Fake{&clientSet.Fake, &c.DynamicClient.(*fakedynamic.FakeDynamicClient).Fake, &c.MetadataClient.(*fakemetadata.FakeMetadataClient).Fake} {
+		for _, verb := range []string{"create", "update", "patch", "delete", "delete-collection"} {
+			f.PrependReactor(verb, "*", rejectWriteReactor)
+		}
+	}
+	// 在拒绝写操作的 reactor 之前处理，否则权限检查本身会被拒绝
+	clientSet.PrependReactor("create", "selfsubjectaccessreviews", readOnlyAccessReactor)
+	return c
+}
+
+func rejectWriteReactor(action testing.Action) (bool, runtime.Object, error) {
+	resource := action.GetResource()
+	return true, nil, apierrors.NewForbidden(resource.GroupResource(), "", errors.New("快照是只读的"))
+}
+
+func readOnlyAccessReactor(action testing.Action) (bool, runtime.Object, error) {
+	review := action.(testing.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+	if attrs := review.Spec.ResourceAttributes; attrs != nil && !slices.Contains([]string{"get", "list", "watch"}, attrs.Verb) {
+		review.Status.Reason = "快照是只读的"
+	} else {
+		review.Status.Allowed = true
+	}
+	return true, review, nil
+}
+
+// DecodeObjects 解析多文档 YAML/JSON，内置类型解析为具体类型，List 会被展开
+func DecodeObjects(data []byte) ([]runtime.Object, error) {
+	var objects []runtime.Object
+	reader := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var u unstructured.Unstructured
+		if err := reader.Decode(&u.Object); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if len(u.Object) == 0 {
+			continue
+		}
+		if u.IsList() {
+			list, err := u.ToList()
+			if err != nil {
+				return nil, err
+			}
+			for i := range list.Items {
+				obj, err := toTyped(&list.Items[i])
+				if err != nil {
+					return nil, err
+				}
+				objects = append(objects, obj)
+			}
+			continue
+		}
+		obj, err := toTyped(&u)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+func allowAccessReactor(action testing.Action) (bool, runtime.Object, error) {
+	review := action.(testing.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+	review.Status.Allowed = true
+	return true, review, nil
+}
+
+// partialMetadata 取出对象的元数据，类型未知时返回 nil
+func partialMetadata(obj runtime.Object) *metav1.PartialObjectMetadata {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return nil
+	}
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvks, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil || len(gvks) == 0 {
+			return nil
+		}
+		gvk = gvks[0]
+	}
+	m := meta.AsPartialObjectMetadata(accessor).DeepCopy()
+	m.SetGroupVersionKind(gvk)
+	return m
+}
+
+func toTyped(u *unstructured.Unstructured) (runtime.Object, error) {
+	obj, err := scheme.Scheme.New(u.GroupVersionKind())
+	if err != nil {
+		// 不认识的类型（例如 CRD）保留为 Unstructured
+		return u, nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj); err != nil {
+		return nil, err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(u.GroupVersionKind())
+	return obj, nil
+}
+
+func tailLines(content string, opts *corev1.PodLogOptions) string {
+	if opts == nil || opts.TailLines == nil {
+		return content
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if n := int(*opts.TailLines); n < len(lines) {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "")
+}
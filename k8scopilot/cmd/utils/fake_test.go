@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"context"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReadOnlyClientGo(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "shop"}}
+	c := NewReadOnlyClientGo([]runtime.Object{pod}, nil)
+	ctx := context.TODO()
+	pods := schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+	if _, err := c.ClientSet.CoreV1().Pods("shop").Get(ctx, "web-1", metav1.GetOptions{}); err != nil {
+		t.Fatalf("读操作应成功: %v", err)
+	}
+	if _, err := c.DynamicClient.Resource(pods).Namespace("shop").List(ctx, metav1.ListOptions{}); err != nil {
+		t.Fatalf("读操作应成功: %v", err)
+	}
+
+	writes := []struct {
+		name string
+		do   func() error
+	}{
+		{"typed delete", func() error {
+			return c.ClientSet.CoreV1().Pods("shop").Delete(ctx, "web-1", metav1.DeleteOptions{})
+		}},
+		{"typed create", func() error {
+			_, err := c.ClientSet.CoreV1().ConfigMaps("shop").Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "x"}}, metav1.CreateOptions{})
+			return err
+		}},
+		{"dynamic delete", func() error {
+			return c.DynamicClient.Resource(pods).Namespace("shop").Delete(ctx, "web-1", metav1.DeleteOptions{})
+		}},
+		{"dynamic patch", func() error {
+			_, err := c.DynamicClient.Resource(pods).Namespace("shop").Patch(ctx, "web-1", types.MergePatchType, []byte(`{"metadata":{"labels":{"a":"b"}}}`), metav1.PatchOptions{})
+			return err
+		}},
+		{"metadata delete", func() error {
+			return c.MetadataClient.Resource(pods).Namespace("shop").Delete(ctx, "web-1", metav1.DeleteOptions{})
+		}},
+	}
+	for _, tt := range writes {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.do(); !apierrors.IsForbidden(err) {
+				t.Errorf("写操作应被拒绝: %v", err)
+			}
+		})
+	}
+	if _, err := c.ClientSet.CoreV1().Pods("shop").Get(ctx, "web-1", metav1.GetOptions{}); err != nil {
+		t.Errorf("被拒绝的删除不应生效: %v", err)
+	}
+
+	for verb, want := range map[string]bool{"get": true, "list": true, "delete": false, "patch": false} {
+		review, err := c.ClientSet.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &authorizationv1.ResourceAttributes{Verb: verb, Resource: "pods", Namespace: "shop"}},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if review.Status.Allowed != want {
+			t.Errorf("%s: allowed=%v，期望 %v", verb, review.Status.Allowed, want)
+		}
+	}
+}
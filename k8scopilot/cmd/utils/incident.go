@@ -0,0 +1,380 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// IncidentConfig 对应配置文件中的 incidents 段
+type IncidentConfig struct {
+	// 事故库文件，默认 <dataDir>/incidents.db
+	File string `json:"file"`
+	// 为 true 时不记录分析结果，也不查找相似的历史事故
+	Disabled bool `json:"disabled"`
+	// 证据的相似度达到该值才视为相似事故，默认 0.5
+	MinSimilarity float64 `json:"minSimilarity"`
+}
+
+// Incident 是事故库中的一条记录，对应一次分析
+type Incident struct {
+	ID string `json:"id"`
+	// 第一次记录的时间
+	Time time.Time `json:"time"`
+	// 同一对象上相同的故障再次出现时只更新原记录
+	LastSeen time.Time `json:"lastSeen,omitempty"`
+	Count    int       `json:"count,omitempty"`
+	// 本机用户和 kubeconfig 上下文
+	User    string `json:"user"`
+	Context string `json:"context,omitempty"`
+	// 分析的对象，Pod 问题为所属工作负载
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Severity  string `json:"severity"`
+	// 交给模型的证据，例如事件或规则检查结果
+	Evidence []string `json:"evidence"`
+	// 去掉时间、次数、IP 和对象名后的证据摘要，相同表示同一种故障
+	Fingerprint   string `json:"fingerprint"`
+	Diagnosis     string `json:"diagnosis"`
+	PromptVersion string `json:"promptVersion,omitempty"`
+	// 用户确认的实际修复方法
+	Resolution string    `json:"resolution,omitempty"`
+	ResolvedAt time.Time `json:"resolvedAt,omitempty"`
+	ResolvedBy string    `json:"resolvedBy,omitempty"`
+}
+
+// Object 返回事故涉及的对象，例如 Deployment/shop/web
+func (i Incident) Object() string {
+	if i.Namespace == "" {
+		return i.Kind + "/" + i.Name
+	}
+	return i.Kind + "/" + i.Namespace + "/" + i.Name
+}
+
+// Resolved 返回是否已经记录了修复方法
+func (i Incident) Resolved() bool {
+	return i.Resolution != ""
+}
+
+// IncidentMatch 是一条相似的历史事故
+type IncidentMatch struct {
+	Incident
+	// 0 到 1，指纹相同时为 1
+	Similarity float64
+}
+
+// 默认的相似度下限
+const defaultIncidentSimilarity = 0.5
+
+var incidentBucket = []byte("incidents")
+
+// IncidentStore 把事故保存在本地的 bbolt 数据库中
+// 每次操作单独打开数据库，不会在分析期间一直持有文件锁
+type IncidentStore struct {
+	path          string
+	minSimilarity float64
+}
+
+// NewIncidentStore 创建事故库，配置为禁用时返回 nil
+func NewIncidentStore(cfg IncidentConfig, dataDir string) *IncidentStore {
+	if cfg.Disabled {
+		return nil
+	}
+	path := cfg.File
+	if path == "" {
+		path = filepath.Join(dataDir, "incidents.db")
+	}
+	s := &IncidentStore{path: expandHome(path), minSimilarity: cfg.MinSimilarity}
+	if s.minSimilarity <= 0 {
+		s.minSimilarity = defaultIncidentSimilarity
+	}
+	return s
+}
+
+// Path 返回数据库文件路径
+func (s *IncidentStore) Path() string {
+	return s.path
+}
+
+func (s *IncidentStore) open(readOnly bool) (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return nil, err
+	}
+	// 另一个 k8scopilot 进程正在写入时最多等待几秒
+	db, err := bolt.Open(s.path, 0o600, &bolt.Options{Timeout: 3 * time.Second, ReadOnly: readOnly})
+	if err != nil {
+		return nil, fmt.Errorf("打开事故库 %s 失败: %w", s.path, err)
+	}
+	return db, nil
+}
+
+func (s *IncidentStore) update(fn func(b *bolt.Bucket) error) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(incidentBucket)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+// Record 补全 ID 和指纹后保存事故
+// 同一对象上已有指纹相同且尚未解决的事故时更新该事故，不新增记录
+func (s *IncidentStore) Record(inc *Incident) error {
+	inc.Fingerprint = EvidenceFingerprint(inc.Namespace, inc.Name, inc.Evidence)
+	now := time.Now()
+	return s.update(func(b *bolt.Bucket) error {
+		inc.Count = 1
+		err := b.ForEach(func(k, v []byte) error {
+			var old Incident
+			if err := json.Unmarshal(v, &old); err != nil {
+				// 格式错误的记录由 List 报告，不影响写入
+				return nil
+			}
+			if old.sameOpenIncident(inc.Object(), inc.Fingerprint) {
+				inc.ID, inc.Time = old.ID, old.Time
+				inc.Count = max(old.Count, 1) + 1
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if inc.ID == "" {
+			// 格式和审计记录相同
+			inc.ID = NewAuditID()
+		}
+		if inc.Time.IsZero() {
+			inc.Time = now
+		}
+		inc.LastSeen = now
+		data, err := json.Marshal(inc)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(inc.ID), data)
+	})
+}
+
+// sameOpenIncident 返回是否为同一对象上尚未解决的同一种故障
+func (i Incident) sameOpenIncident(object, fingerprint string) bool {
+	return !i.Resolved() && i.Fingerprint == fingerprint && i.Object() == object
+}
+
+// List 返回全部事故，最早的在前；数据库不存在时返回空
+func (s *IncidentStore) List() ([]Incident, error) {
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	db, err := s.open(true)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	var incidents []Incident
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(incidentBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var inc Incident
+			if err := json.Unmarshal(v, &inc); err != nil {
+				return fmt.Errorf("事故 %s 格式错误: %w", k, err)
+			}
+			incidents = append(incidents, inc)
+			return nil
+		})
+	})
+	sort.SliceStable(incidents, func(i, j int) bool { return incidents[i].Time.Before(incidents[j].Time) })
+	return incidents, err
+}
+
+// ErrIncidentNotFound 表示没有 ID 以该前缀开头的事故
+var ErrIncidentNotFound = errors.New("找不到事故")
+
+// Find 按 ID 前缀查找事故，匹配到多条时报错
+func (s *IncidentStore) Find(prefix string) (Incident, error) {
+	incidents, err := s.List()
+	if err != nil {
+		return Incident{}, err
+	}
+	var matched []Incident
+	for _, inc := range incidents {
+		if strings.HasPrefix(inc.ID, prefix) {
+			matched = append(matched, inc)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return Incident{}, fmt.Errorf("%w %s", ErrIncidentNotFound, prefix)
+	case 1:
+		return matched[0], nil
+	}
+	return Incident{}, fmt.Errorf("前缀 %s 匹配到 %d 条事故，请写出更长的 id", prefix, len(matched))
+}
+
+// Resolve 记录事故实际的修复方法，重复执行时覆盖之前的记录
+func (s *IncidentStore) Resolve(prefix, resolution, user string) (Incident, error) {
+	inc, err := s.Find(prefix)
+	if err != nil {
+		return inc, err
+	}
+	inc.Resolution = strings.TrimSpace(resolution)
+	inc.ResolvedAt = time.Now()
+	inc.ResolvedBy = user
+	data, err := json.Marshal(inc)
+	if err != nil {
+		return inc, err
+	}
+	return inc, s.update(func(b *bolt.Bucket) error {
+		return b.Put([]byte(inc.ID), data)
+	})
+}
+
+// Similar 查找证据相似的历史事故，已记录修复方法的排在前面，其次按相似度排序
+// 规范化后没有证据时不返回结果；同一对象上尚未解决的同一种故障就是本次事故，不算历史事故
+func (s *IncidentStore) Similar(kind, namespace, name string, evidence []string, k int) ([]IncidentMatch, error) {
+	normalizer := newEvidenceNormalizer(namespace, name)
+	if len(normalizer.lines(evidence)) == 0 {
+		return nil, nil
+	}
+	incidents, err := s.List()
+	if err != nil || len(incidents) == 0 {
+		return nil, err
+	}
+	object := Incident{Kind: kind, Namespace: namespace, Name: name}.Object()
+	fingerprint := normalizer.fingerprint(evidence)
+	terms := normalizer.terms(evidence)
+	var matches []IncidentMatch
+	for _, inc := range incidents {
+		if inc.sameOpenIncident(object, fingerprint) {
+			continue
+		}
+		similarity := 1.0
+		if inc.Fingerprint != fingerprint {
+			similarity = jaccard(terms, newEvidenceNormalizer(inc.Namespace, inc.Name).terms(inc.Evidence))
+		}
+		if similarity >= s.minSimilarity {
+			matches = append(matches, IncidentMatch{Incident: inc, Similarity: similarity})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Resolved() != matches[j].Resolved() {
+			return matches[i].Resolved()
+		}
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].Time.After(matches[j].Time)
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+var (
+	evidenceTimestamp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(\.\d+)?Z?( ~ (\d{4}-\d{2}-\d{2} )?\d{2}:\d{2}:\d{2})?`)
+	evidenceIP        = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`)
+	evidenceID        = regexp.MustCompile(`\b[0-9a-f]{8}(-?[0-9a-f]{4}){3}-?[0-9a-f]{12}\b|\b[0-9a-f]{12,}\b`)
+	evidenceCount     = regexp.MustCompile(`\bx\d+\b`)
+)
+
+// evidenceNormalizer 规范化同一对象的证据，对象名的正则只编译一次
+type evidenceNormalizer struct {
+	namespace string
+	// 工作负载下的 Pod 名字以工作负载名开头，后缀是随机的
+	name *regexp.Regexp
+}
+
+func newEvidenceNormalizer(namespace, name string) evidenceNormalizer {
+	n := evidenceNormalizer{namespace: namespace}
+	if name != "" {
+		n.name = regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `(-[a-z0-9]+)*`)
+	}
+	return n
+}
+
+// line 去掉每次发生都会变化的部分：时间、次数、IP、UID，以及对象和 Pod 的名字
+func (n evidenceNormalizer) line(line string) string {
+	line = evidenceTimestamp.ReplaceAllString(line, "")
+	line = evidenceIP.ReplaceAllString(line, "<ip>")
+	line = evidenceID.ReplaceAllString(line, "<id>")
+	line = evidenceCount.ReplaceAllString(line, "")
+	if n.name != nil {
+		line = n.name.ReplaceAllString(line, "<name>")
+	}
+	if n.namespace != "" {
+		// 例如 shop/web-1 和 kubelet 消息中的 web-1_shop(uid)
+		line = strings.ReplaceAll(line, n.namespace+"/", "")
+		line = strings.ReplaceAll(line, "_"+n.namespace, "")
+	}
+	return strings.Join(strings.Fields(line), " ")
+}
+
+// lines 返回规范化、排序并去重后的非空证据行
+func (n evidenceNormalizer) lines(evidence []string) []string {
+	lines := make([]string, 0, len(evidence))
+	for _, e := range evidence {
+		if l := n.line(e); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	slices.Sort(lines)
+	return slices.Compact(lines)
+}
+
+func (n evidenceNormalizer) fingerprint(evidence []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(n.lines(evidence), "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (n evidenceNormalizer) terms(evidence []string) map[string]bool {
+	terms := map[string]bool{}
+	for _, e := range evidence {
+		for _, t := range RunbookTerms(n.line(e)) {
+			terms[t] = true
+		}
+	}
+	// 占位符不代表故障的特征
+	delete(terms, "name")
+	delete(terms, "id")
+	delete(terms, "ip")
+	return terms
+}
+
+// EvidenceFingerprint 返回证据的指纹，证据行的顺序和重复不影响结果
+func EvidenceFingerprint(namespace, name string, evidence []string) string {
+	return newEvidenceNormalizer(namespace, name).fingerprint(evidence)
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range a {
+		if b[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
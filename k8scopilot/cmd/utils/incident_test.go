@@ -0,0 +1,118 @@
+package utils
+
+import "testing"
+
+func TestEvidenceFingerprint(t *testing.T) {
+	base := []string{
+		"2026-10-19 08:00:00 ~ 08:06:00 Pod/web-7d9f8-abcde Warning BackOff (kubelet) x4: Back-off restarting failed container web in pod web-7d9f8-abcde_shop(0f8e2c1a-5b6d-4e7f-8a9b-0c1d2e3f4a5b)",
+		"dial tcp 10.0.3.17:5432: connection refused",
+	}
+	tests := []struct {
+		name     string
+		evidence []string
+		same     bool
+	}{
+		{"顺序和重复不影响", []string{base[1], base[0], base[1]}, true},
+		{
+			"时间、次数、Pod 后缀、UID 和 IP 不同",
+			[]string{
+				"2026-10-20 11:30:00 ~ 11:45:00 Pod/web-55c6b-xyz12 Warning BackOff (kubelet) x27: Back-off restarting failed container web in pod web-55c6b-xyz12_shop(9a8b7c6d-1e2f-4a3b-8c4d-5e6f7a8b9c0d)",
+				"dial tcp 10.0.9.2:5432: connection refused",
+			},
+			true,
+		},
+		{"故障不同", []string{base[0], "dial tcp 10.0.3.17:5432: i/o timeout"}, false},
+		{"少了一条证据", base[:1], false},
+	}
+	want := EvidenceFingerprint("shop", "web", base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EvidenceFingerprint("shop", "web", tt.evidence)
+			if (got == want) != tt.same {
+				t.Errorf("指纹相同=%v，期望 %v", got == want, tt.same)
+			}
+		})
+	}
+}
+
+func TestNormalizeEvidence(t *testing.T) {
+	tests := []struct {
+		namespace, name, line, want string
+	}{
+		{"shop", "web", "2026-10-19 08:00:00 Pod/web-abc-1 Warning BackOff x12: Back-off restarting", "Pod/<name> Warning BackOff : Back-off restarting"},
+		{"shop", "web", "Deployment shop/web 有 0/2 个 Pod 就绪", "Deployment <name> 有 0/2 个 Pod 就绪"},
+		// 其它对象的名字不会被替换
+		{"shop", "web", "Service shop/db 没有 endpoints", "Service db 没有 endpoints"},
+		{"", "node-1", "Node/node-1 MemoryPressure=True", "Node/<name> MemoryPressure=True"},
+		{"shop", "", "  ", ""},
+	}
+	for _, tt := range tests {
+		n := newEvidenceNormalizer(tt.namespace, tt.name)
+		if got := n.line(tt.line); got != tt.want {
+			t.Errorf("line(%q) = %q，期望 %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestIncidentStoreRecordAndSimilar(t *testing.T) {
+	store := NewIncidentStore(IncidentConfig{}, t.TempDir())
+	evidence := func(count string) []string {
+		return []string{"Pod/web-abc-1 Warning BackOff " + count + ": Back-off restarting failed container", "dial tcp db2:5432: connection refused"}
+	}
+
+	first := &Incident{Kind: "Deployment", Namespace: "shop", Name: "web", Evidence: evidence("x4")}
+	if err := store.Record(first); err != nil {
+		t.Fatal(err)
+	}
+	// 同一对象上相同的故障再次出现时更新原记录
+	again := &Incident{Kind: "Deployment", Namespace: "shop", Name: "web", Evidence: evidence("x9")}
+	if err := store.Record(again); err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID || again.Count != 2 {
+		t.Errorf("应更新原事故: id %s/%s，count %d", again.ID, first.ID, again.Count)
+	}
+	// 尚未解决的同一事故不算历史事故
+	matches, err := store.Similar("Deployment", "shop", "web", evidence("x12"), 3)
+	if err != nil || len(matches) != 0 {
+		t.Fatalf("不应返回本次事故: %v, %v", matches, err)
+	}
+
+	if _, err := store.Resolve(first.ID, "恢复 ConfigMap 中的 DB_HOST", "alice"); err != nil {
+		t.Fatal(err)
+	}
+	other := &Incident{Kind: "Deployment", Namespace: "shop", Name: "api", Evidence: []string{"Pod/api-xyz-1 Warning BackOff x2: Back-off restarting failed container", "dial tcp db2:5432: i/o timeout"}}
+	if err := store.Record(other); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		evidence []string
+		want     []string
+	}{
+		{"已解决的相同事故排在前面", evidence("x1"), []string{first.ID, other.ID}},
+		{"没有证据", []string{" "}, nil},
+		{"完全不同的证据", []string{"ImagePullBackOff: manifest unknown"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := store.Similar("Deployment", "shop", "web", tt.evidence, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("期望 %v，实际 %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("第 %d 条: %s，期望 %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// 错误相关的日志行
+	errorLinePattern = regexp.MustCompile(`(?i)(error|exception|fatal|panic|fail|traceback|caused by|oom|killed|refused|timed? ?out|denied|unauthorized|no such|not found)`)
+	// 堆栈的续行：Java/Python/Go 的常见格式
+	stackLinePattern = regexp.MustCompile(`^(\s+at |\s+File "|\s+\.\.\. \d+ more|goroutine \d+|\t|\s{4,}\S|[\w./*()\[\]-]+\(.*\)$)`)
+
+	// 去重前的归一化：时间戳和十六进制 ID，其余数字（状态码、序号等）保留
+	timestampPattern = regexp.MustCompile(`\d{4}[-/]\d{2}[-/]\d{2}[T ]\d{2}:\d{2}:\d{2}([.,]\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+	hexPattern       = regexp.MustCompile(`\b(0x)?[0-9a-fA-F]{8,}\b`)
+)
+
+type logLine struct {
+	text      string
+	repeat    int
+	important bool
+}
+
+// CondenseLogs 把日志压缩到 maxTokens 以内
+// 先合并连续的重复行，预算仍不够时优先保留错误行和堆栈，再用最新的日志填满剩余预算
+func CondenseLogs(model, logs string, maxTokens int) string {
+	if maxTokens <= 0 || strings.TrimSpace(logs) == "" {
+		return ""
+	}
+	lines := dedupeLogLines(strings.Split(strings.TrimRight(logs, "\n"), "\n"))
+
+	tokens := make([]int, len(lines))
+	total := 0
+	for i, l := range lines {
+		tokens[i] = CountTokens(model, l.render()) + 1
+		total += tokens[i]
+	}
+	if total <= maxTokens {
+		return renderLogLines(lines, nil)
+	}
+
+	keep := make(map[int]bool)
+	used := 0
+	// 错误行最多占用 60% 的预算，从最新的开始保留
+	errorBudget := maxTokens * 6 / 10
+	for i := len(lines) - 1; i >= 0; i-- {
+		if lines[i].important && used+tokens[i] <= errorBudget {
+			keep[i] = true
+			used += tokens[i]
+		}
+	}
+	// 剩余预算留给最新的日志，为省略标记预留一些空间
+	for i := len(lines) - 1; i >= 0; i-- {
+		if keep[i] {
+			continue
+		}
+		if used+tokens[i]+10 > maxTokens {
+			break
+		}
+		keep[i] = true
+		used += tokens[i]
+	}
+	return renderLogLines(lines, keep)
+}
+
+// dedupeLogLines 只合并连续的重复行，保持日志原来的顺序，不同堆栈中相同的帧不会被合并
+func dedupeLogLines(raw []string) []logLine {
+	var lines []logLine
+	prevKey := ""
+	inStack := false
+	for _, text := range raw {
+		stack := stackLinePattern.MatchString(text)
+		important := errorLinePattern.MatchString(text) || (inStack && stack)
+		// 错误行之后紧跟的缩进行视为堆栈
+		inStack = important
+
+		key := normalizeLogLine(text)
+		if len(lines) > 0 && key != "" && key == prevKey {
+			lines[len(lines)-1].repeat++
+			continue
+		}
+		prevKey = key
+		lines = append(lines, logLine{text: text, repeat: 1, important: important})
+	}
+	return lines
+}
+
+func normalizeLogLine(text string) string {
+	text = timestampPattern.ReplaceAllString(text, "")
+	text = hexPattern.ReplaceAllString(text, "#")
+	return strings.TrimSpace(text)
+}
+
+func (l logLine) render() string {
+	if l.repeat > 1 {
+		return fmt.Sprintf("%s  [重复 %d 次]", l.text, l.repeat)
+	}
+	return l.text
+}
+
+// keep 为空表示全部保留，被省略的连续行用一行标记代替
+func renderLogLines(lines []logLine, keep map[int]bool) string {
+	var b strings.Builder
+	if keep == nil {
+		for _, l := range lines {
+			b.WriteString(l.render())
+			b.WriteString("\n")
+		}
+		return b.String()
+	}
+	indexes := make([]int, 0, len(keep))
+	for i := range keep {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	prev := -1
+	for _, i := range indexes {
+		if i-prev > 1 {
+			fmt.Fprintf(&b, "... (省略 %d 行)\n", i-prev-1)
+		}
+		b.WriteString(lines[i].render())
+		b.WriteString("\n")
+		prev = i
+	}
+	if len(lines)-prev > 1 {
+		fmt.Fprintf(&b, "... (省略 %d 行)\n", len(lines)-prev-1)
+	}
+	return b.String()
+}
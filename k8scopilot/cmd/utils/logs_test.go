@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDedupeLogLines(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []string
+		want []string
+	}{
+		{
+			name: "只合并连续的重复行，时间戳和十六进制 ID 不同也算重复",
+			raw: []string{
+				"2026-10-19T08:05:00Z ERROR request 0x7f3a9c21e0 failed",
+				"2026-10-19T08:05:01Z ERROR request 0x7f3a9c21e8 failed",
+				"2026-10-19T08:05:02Z INFO retrying",
+				"2026-10-19T08:05:03Z ERROR request 0x7f3a9c21f0 failed",
+			},
+			want: []string{
+				"2026-10-19T08:05:00Z ERROR request 0x7f3a9c21e0 failed  [重复 2 次]",
+				"2026-10-19T08:05:02Z INFO retrying",
+				"2026-10-19T08:05:03Z ERROR request 0x7f3a9c21f0 failed",
+			},
+		},
+		{
+			name: "其它数字不同的行不合并",
+			raw:  []string{"GET /orders status 500", "GET /orders status 200", "connect db-1", "connect db-2"},
+			want: []string{"GET /orders status 500", "GET /orders status 200", "connect db-1", "connect db-2"},
+		},
+		{
+			name: "不同堆栈中相同的帧不合并",
+			raw: []string{
+				"ERROR java.lang.NullPointerException",
+				"    at com.shop.Order.total(Order.java:42)",
+				"    at com.shop.Api.handle(Api.java:7)",
+				"INFO request done",
+				"ERROR java.lang.IllegalStateException",
+				"    at com.shop.Order.total(Order.java:42)",
+			},
+			want: []string{
+				"ERROR java.lang.NullPointerException",
+				"    at com.shop.Order.total(Order.java:42)",
+				"    at com.shop.Api.handle(Api.java:7)",
+				"INFO request done",
+				"ERROR java.lang.IllegalStateException",
+				"    at com.shop.Order.total(Order.java:42)",
+			},
+		},
+		{
+			name: "空行不合并",
+			raw:  []string{"a", "", "", "b"},
+			want: []string{"a", "", "", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := dedupeLogLines(tt.raw)
+			var got []string
+			for _, l := range lines {
+				got = append(got, l.render())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestDedupeLogLinesMarksStack(t *testing.T) {
+	lines := dedupeLogLines([]string{
+		"INFO starting",
+		"panic: runtime error: index out of range",
+		"goroutine 1 [running]:",
+		"\tmain.go:12 +0x1d",
+		"INFO next",
+		"\tnot a stack frame",
+	})
+	want := []bool{false, true, true, true, false, false}
+	for i, l := range lines {
+		if l.important != want[i] {
+			t.Errorf("%q: important=%v，期望 %v", l.text, l.important, want[i])
+		}
+	}
+}
+
+func TestCondenseLogs(t *testing.T) {
+	var raw []string
+	for i := 0; i < 200; i++ {
+		raw = append(raw, "INFO handled request "+strings.Repeat("x", i%7)+" ok")
+	}
+	raw[50] = "ERROR dial tcp db2:5432: connection refused"
+	raw = append(raw, "INFO shutting down")
+	logs := strings.Join(raw, "\n")
+
+	tests := []struct {
+		name      string
+		maxTokens int
+		contains  []string
+		omitted   bool
+	}{
+		{"预算足够时全部保留", 100000, []string{"INFO handled request  ok", "ERROR dial tcp", "INFO shutting down"}, false},
+		{"预算不足时保留错误行和最新的日志", 60, []string{"ERROR dial tcp", "INFO shutting down", "... (省略"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CondenseLogs("gpt-4o", logs, tt.maxTokens)
+			for _, s := range tt.contains {
+				if !strings.Contains(got, s) {
+					t.Errorf("结果中没有 %q:\n%s", s, got)
+				}
+			}
+			if tt.omitted && CountTokens("gpt-4o", got) > tt.maxTokens {
+				t.Errorf("超出预算: %d > %d", CountTokens("gpt-4o", got), tt.maxTokens)
+			}
+			// 保留的行仍按原来的顺序排列
+			if i, j := strings.Index(got, "ERROR dial tcp"), strings.Index(got, "INFO shutting down"); i > j {
+				t.Errorf("行的顺序被打乱:\n%s", got)
+			}
+		})
+	}
+	if got := CondenseLogs("gpt-4o", logs, 0); got != "" {
+		t.Errorf("预算为 0 时应返回空: %q", got)
+	}
+}
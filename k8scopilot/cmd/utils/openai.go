@@ -2,7 +2,9 @@ package utils
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/go-errors/errors"
 	"github.com/sashabaranov/go-openai"
@@ -10,7 +12,13 @@ import (
 
 type OpenAI struct {
 	Client *openai.Client
-	ctx    context.Context
+	// Redactor 不为空时，所有发送给模型的内容都会先脱敏
+	Redactor *Redactor
+	// Usage 不为空时记录每次调用的用量，并在超出费用上限时拒绝调用
+	Usage *UsageTracker
+	// Cache 不为空时，相同证据的请求直接返回缓存的回复
+	Cache *ResponseCache
+	ctx   context.Context
 }
 
 func NewOpenAIClient() (*OpenAI, error) {
@@ -20,6 +28,10 @@ func NewOpenAIClient() (*OpenAI, error) {
 	}
 	config := openai.DefaultConfig(apiKey)
 	config.BaseURL = "https://api.mixrai.com/v1"
+	// 允许指向其它兼容 OpenAI 的服务，例如本地的 mock-llm
+	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+		config.BaseURL = baseURL
+	}
 	client := openai.NewClientWithConfig(config)
 
 	ctx := context.Background()
@@ -45,7 +57,7 @@ func (o *OpenAI) SendMessage(prompt, content string) (string, error) {
 		},
 	}
 
-	resp, err := o.Client.CreateChatCompletion(o.ctx, req)
+	resp, err := o.ChatCompletion(WithOperation(o.ctx, "SendMessage"), req)
 	if err != nil {
 		return "", err
 	}
@@ -54,3 +66,74 @@ func (o *OpenAI) SendMessage(prompt, content string) (string, error) {
 	}
 	return resp.Choices[0].Message.Content, nil
 }
+
+// ChatCompletion 是所有 LLM 调用的统一入口
+func (o *OpenAI) ChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	req.Messages = o.Redactor.RedactMessages(req.Messages)
+
+	// 缓存按脱敏后的内容计算指纹，缓存文件中不会出现敏感信息
+	key := o.Cache.Key(ctx, req)
+	if resp, ok := o.Cache.Get(key); ok {
+		o.record(ctx, req, resp, time.Now(), true)
+		return resp, nil
+	}
+
+	if err := o.Usage.Check(); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	start := time.Now()
+	resp, err := o.Client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	o.record(ctx, req, resp, start, false)
+	if err := o.Cache.Put(ctx, key, req, resp); err != nil {
+		fmt.Fprintln(os.Stderr, "写入缓存失败:", err)
+	}
+	return resp, nil
+}
+
+// CreateEmbeddings 计算文本的向量，输入同样先脱敏，用量记入账本
+func (o *OpenAI) CreateEmbeddings(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	redacted := make([]string, len(inputs))
+	for i, in := range inputs {
+		redacted[i] = o.Redactor.Redact(in)
+	}
+	if err := o.Usage.Check(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := o.Client.CreateEmbeddings(ctx, openai.EmbeddingRequestStrings{
+		Input: redacted,
+		Model: openai.EmbeddingModel(model),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) != len(inputs) {
+		return nil, fmt.Errorf("embedding 返回了 %d 个向量，请求了 %d 个", len(resp.Data), len(inputs))
+	}
+	o.record(ctx, openai.ChatCompletionRequest{Model: model}, openai.ChatCompletionResponse{Usage: resp.Usage}, start, false)
+	vectors := make([][]float32, len(resp.Data))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding 返回了无效的序号 %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+func (o *OpenAI) record(ctx context.Context, req openai.ChatCompletionRequest, resp openai.ChatCompletionResponse, start time.Time, cached bool) {
+	if err := o.Usage.Record(UsageRecord{
+		Time:             start,
+		Operation:        operationFrom(ctx),
+		Model:            req.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		LatencyMs:        time.Since(start).Milliseconds(),
+		Cached:           cached,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, "记录用量失败:", err)
+	}
+}
@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/google/cel-go/cel"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// PolicyConfig 对应配置文件中的 policy 段
+type PolicyConfig struct {
+	// 策略文件，默认 <dataDir>/policy.yaml，文件不存在时不做限制
+	File string `json:"file"`
+}
+
+// PolicyRule 是策略文件中的一条规则
+// 各个匹配条件之间是“且”的关系，没有填写的条件匹配所有请求
+type PolicyRule struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// 匹配的工具名，例如 deleteResource
+	Tools []string `json:"tools"`
+	// 匹配的动作，默认只匹配变更操作 create、update、patch、delete
+	Verbs []string `json:"verbs"`
+	// 匹配的命名空间，和 namespaceSelector 同时填写时满足其一即可
+	Namespaces        []string              `json:"namespaces"`
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector"`
+	// 匹配的资源，写法为 persistentvolumeclaims 或 deployments.apps，* 匹配所有资源
+	Resources []string `json:"resources"`
+	// true 只匹配 CRD 等自定义资源，false 只匹配内置资源
+	Custom *bool `json:"custom"`
+	// CEL 表达式，变量 request 包含 tool、verb、group、resource、namespace、name、
+	// namespaceLabels、custom，以及可能存在的 replicas 和 object，返回 true 表示匹配
+	Expression string `json:"expression"`
+
+	// 命中规则后的处理，默认拒绝
+	// 副本数超过 maxReplicas 时拒绝，副本数未知时不拒绝
+	MaxReplicas int64 `json:"maxReplicas"`
+	// 不拒绝，但要求先用 dry-run 提交一次，失败时拒绝
+	RequireDryRun bool `json:"requireDryRun"`
+
+	selector labels.Selector
+	program  cel.Program
+}
+
+// PolicyRequest 描述工具将要执行的一次操作
+type PolicyRequest struct {
+	Tool      string
+	Verb      string
+	Group     string
+	Resource  string
+	Namespace string
+	Name      string
+	// 所在命名空间的标签，集群级资源为空
+	NamespaceLabels map[string]string
+	// 是否为 CRD 等非内置资源
+	Custom bool
+	// 对象的副本数，为空表示未知或没有副本数
+	Replicas *int64
+	// 将要提交的对象，删除和查询时为空
+	Object map[string]any
+}
+
+// PolicyDecision 是策略的判定结果
+type PolicyDecision struct {
+	// 拒绝时为命中的规则名和原因
+	DeniedBy string
+	Reason   string
+	// 要求先 dry-run 的规则名，为空表示不需要
+	DryRunBy string
+}
+
+// Denied 返回操作是否被拒绝
+func (d PolicyDecision) Denied() bool {
+	return d.DeniedBy != ""
+}
+
+// 未填写 verbs 的规则只约束变更操作
+var mutatingVerbs = []string{"create", "update", "patch", "delete"}
+
+// Policy 是加载后的策略文件，为空时允许所有操作
+type Policy struct {
+	Rules []*PolicyRule `json:"rules"`
+}
+
+// LoadPolicy 读取并编译策略文件
+func LoadPolicy(cfg PolicyConfig, dataDir string) (*Policy, error) {
+	path := cfg.File
+	if path == "" {
+		path = filepath.Join(dataDir, "policy.yaml")
+	}
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		// 显式配置的文件必须存在，避免路径写错时静默放行
+		if os.IsNotExist(err) && cfg.File == "" {
+			return &Policy{}, nil
+		}
+		return nil, fmt.Errorf("读取策略文件失败: %w", err)
+	}
+	p := &Policy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("解析策略文件 %s 失败: %w", path, err)
+	}
+
+	env, err := cel.NewEnv(cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)))
+	if err != nil {
+		return nil, err
+	}
+	for i, rule := range p.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if rule.NamespaceSelector != nil {
+			if rule.selector, err = metav1.LabelSelectorAsSelector(rule.NamespaceSelector); err != nil {
+				return nil, fmt.Errorf("规则 %s 的 namespaceSelector 无效: %w", rule.Name, err)
+			}
+		}
+		if rule.Expression != "" {
+			ast, issues := env.Compile(rule.Expression)
+			if issues.Err() != nil {
+				return nil, fmt.Errorf("规则 %s 的表达式无效: %w", rule.Name, issues.Err())
+			}
+			if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+				return nil, fmt.Errorf("规则 %s 的表达式必须返回 bool，实际为 %s", rule.Name, ast.OutputType())
+			}
+			if rule.program, err = env.Program(ast); err != nil {
+				return nil, fmt.Errorf("规则 %s 的表达式无效: %w", rule.Name, err)
+			}
+		}
+	}
+	return p, nil
+}
+
+// UsesNamespaceLabels 返回是否有规则需要命名空间标签，没有时不必请求 apiserver
+func (p *Policy) UsesNamespaceLabels() bool {
+	return slices.ContainsFunc(p.Rules, func(r *PolicyRule) bool {
+		return r.selector != nil || r.program != nil
+	})
+}
+
+// Evaluate 按顺序检查所有规则，命中第一条拒绝规则时返回
+func (p *Policy) Evaluate(req PolicyRequest) PolicyDecision {
+	var decision PolicyDecision
+	for _, rule := range p.Rules {
+		matched, err := rule.matches(req)
+		if err != nil {
+			// 表达式求值失败时拒绝，避免规则写错时静默放行
+			return PolicyDecision{DeniedBy: rule.Name, Reason: "表达式求值失败: " + err.Error()}
+		}
+		if !matched {
+			continue
+		}
+		switch {
+		case rule.MaxReplicas > 0:
+			if req.Replicas != nil && *req.Replicas > rule.MaxReplicas {
+				return PolicyDecision{DeniedBy: rule.Name, Reason: fmt.Sprintf("副本数 %d 超过上限 %d", *req.Replicas, rule.MaxReplicas)}
+			}
+		case rule.RequireDryRun:
+			if decision.DryRunBy == "" {
+				decision.DryRunBy = rule.Name
+			}
+		default:
+			return PolicyDecision{DeniedBy: rule.Name, Reason: rule.Description}
+		}
+	}
+	return decision
+}
+
+func (r *PolicyRule) matches(req PolicyRequest) (bool, error) {
+	if len(r.Tools) > 0 && !slices.Contains(r.Tools, req.Tool) {
+		return false, nil
+	}
+	verbs := r.Verbs
+	if len(verbs) == 0 {
+		verbs = mutatingVerbs
+	}
+	if !slices.Contains(verbs, req.Verb) && !slices.Contains(verbs, "*") {
+		return false, nil
+	}
+	if len(r.Namespaces) > 0 || r.selector != nil {
+		inList := slices.Contains(r.Namespaces, req.Namespace)
+		selected := r.selector != nil && req.Namespace != "" && r.selector.Matches(labels.Set(req.NamespaceLabels))
+		if !inList && !selected {
+			return false, nil
+		}
+	}
+	if len(r.Resources) > 0 {
+		qualified := req.Resource
+		if req.Group != "" {
+			qualified += "." + req.Group
+		}
+		if !slices.Contains(r.Resources, "*") && !slices.Contains(r.Resources, req.Resource) && !slices.Contains(r.Resources, qualified) {
+			return false, nil
+		}
+	}
+	if r.Custom != nil && *r.Custom != req.Custom {
+		return false, nil
+	}
+	if r.program == nil {
+		return true, nil
+	}
+	out, _, err := r.program.Eval(map[string]any{"request": req.celValue()})
+	if err != nil {
+		return false, err
+	}
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("表达式返回了 %v，不是 bool", out.Value())
+	}
+	return matched, nil
+}
+
+// celValue 把请求转换成表达式中的 request 变量，未知的字段不出现，可以用 has() 判断
+func (req PolicyRequest) celValue() map[string]any {
+	nsLabels := req.NamespaceLabels
+	if nsLabels == nil {
+		nsLabels = map[string]string{}
+	}
+	v := map[string]any{
+		"tool":            req.Tool,
+		"verb":            req.Verb,
+		"group":           req.Group,
+		"resource":        req.Resource,
+		"namespace":       req.Namespace,
+		"name":            req.Name,
+		"namespaceLabels": nsLabels,
+		"custom":          req.Custom,
+	}
+	if req.Replicas != nil {
+		v["replicas"] = *req.Replicas
+	}
+	if req.Object != nil {
+		v["object"] = req.Object
+	}
+	return v
+}
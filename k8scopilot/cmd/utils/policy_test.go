@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func loadTestPolicy(t *testing.T, content string) (*Policy, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadPolicy(PolicyConfig{File: path}, "")
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy, err := loadTestPolicy(t, `
+rules:
+- name: protect-prod
+  description: 生产环境只读
+  namespaceSelector: {matchLabels: {env: prod}}
+- name: keep-volumes
+  description: 不允许删除 PVC
+  verbs: [delete]
+  resources: [persistentvolumeclaims]
+- name: replica-limit
+  resources: [deployments.apps]
+  maxReplicas: 10
+- name: crd-dry-run
+  custom: true
+  requireDryRun: true
+- name: protect-db
+  description: 不允许删除 db- 开头的资源
+  expression: request.verb == "delete" && request.name.startsWith("db-")
+- name: no-secret-list
+  tools: [queryResource]
+  verbs: [list]
+  resources: [secrets]
+- name: large-objects
+  expression: has(request.object) && request.object.metadata.name == "bad"
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !policy.UsesNamespaceLabels() {
+		t.Error("有 namespaceSelector 和表达式时需要命名空间标签")
+	}
+	replicas := func(n int64) *int64 { return &n }
+	tests := []struct {
+		name             string
+		req              PolicyRequest
+		deniedBy, dryRun string
+	}{
+		{"生产命名空间的变更", PolicyRequest{Verb: "delete", Resource: "pods", Namespace: "shop", NamespaceLabels: map[string]string{"env": "prod"}}, "protect-prod", ""},
+		{"生产命名空间的查询默认不受限制", PolicyRequest{Tool: "queryResource", Verb: "list", Resource: "pods", Namespace: "shop", NamespaceLabels: map[string]string{"env": "prod"}}, "", ""},
+		{"删除 PVC", PolicyRequest{Verb: "delete", Resource: "persistentvolumeclaims", Namespace: "dev", Name: "data"}, "keep-volumes", ""},
+		{"创建 PVC", PolicyRequest{Verb: "create", Resource: "persistentvolumeclaims", Namespace: "dev", Name: "data"}, "", ""},
+		{"副本数超过上限", PolicyRequest{Verb: "update", Group: "apps", Resource: "deployments", Namespace: "dev", Replicas: replicas(20)}, "replica-limit", ""},
+		{"副本数未超过上限", PolicyRequest{Verb: "update", Group: "apps", Resource: "deployments", Namespace: "dev", Replicas: replicas(3)}, "", ""},
+		{"副本数未知", PolicyRequest{Verb: "update", Group: "apps", Resource: "deployments", Namespace: "dev"}, "", ""},
+		{"自定义资源要求 dry-run", PolicyRequest{Verb: "create", Group: "example.com", Resource: "widgets", Namespace: "dev", Custom: true}, "", "crd-dry-run"},
+		{"表达式命中", PolicyRequest{Verb: "delete", Resource: "services", Namespace: "dev", Name: "db-primary"}, "protect-db", ""},
+		{"表达式未命中", PolicyRequest{Verb: "delete", Resource: "services", Namespace: "dev", Name: "web"}, "", ""},
+		{"只匹配指定的工具和动作", PolicyRequest{Tool: "queryResource", Verb: "list", Resource: "secrets", Namespace: "dev"}, "no-secret-list", ""},
+		{"表达式读取对象", PolicyRequest{Verb: "create", Resource: "configmaps", Namespace: "dev", Object: map[string]any{"metadata": map[string]any{"name": "bad"}}}, "large-objects", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := policy.Evaluate(tt.req)
+			if d.DeniedBy != tt.deniedBy || d.DryRunBy != tt.dryRun {
+				t.Errorf("Evaluate = %+v，期望 deniedBy=%q dryRunBy=%q", d, tt.deniedBy, tt.dryRun)
+			}
+			if d.Denied() != (tt.deniedBy != "") {
+				t.Errorf("Denied() = %v", d.Denied())
+			}
+		})
+	}
+}
+
+func TestPolicyExpressionError(t *testing.T) {
+	// 运行时求值失败时拒绝
+	policy, err := loadTestPolicy(t, `
+rules:
+- name: by-replicas
+  expression: request.replicas > 5
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := policy.Evaluate(PolicyRequest{Verb: "delete", Resource: "pods"})
+	if d.DeniedBy != "by-replicas" || !strings.Contains(d.Reason, "表达式求值失败") {
+		t.Errorf("求值失败时应拒绝: %+v", d)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name, content, wantErr string
+	}{
+		{"表达式语法错误", "rules:\n- name: bad\n  expression: request.verb ==\n", "表达式无效"},
+		{"表达式不返回 bool", "rules:\n- name: str\n  expression: size(request)\n", "必须返回 bool"},
+		{"选择器无效", "rules:\n- name: sel\n  namespaceSelector: {matchExpressions: [{key: env, operator: Foo}]}\n", "namespaceSelector 无效"},
+		{"YAML 无效", "rules: [", "解析策略文件"},
+		{"规则没有名字时自动编号", "rules:\n- verbs: [delete]\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := loadTestPolicy(t, tt.content)
+			if tt.wantErr == "" {
+				if err != nil || p.Rules[0].Name != "rule-1" {
+					t.Errorf("LoadPolicy = %+v, %v", p, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("期望错误 %q，实际 %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// 默认位置的文件不存在时不做限制，显式配置的文件必须存在
+	if p, err := LoadPolicy(PolicyConfig{}, t.TempDir()); err != nil || len(p.Rules) != 0 {
+		t.Errorf("默认文件不存在时应返回空策略: %v", err)
+	}
+	if _, err := LoadPolicy(PolicyConfig{File: filepath.Join(t.TempDir(), "missing.yaml")}, ""); err == nil {
+		t.Error("显式配置的文件不存在时应报错")
+	}
+}
@@ -0,0 +1,357 @@
+package utils
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//go:embed prompts
+var builtinPrompts embed.FS
+
+// 内置的提示词模板名称
+const (
+	// 系统提示词，无数据
+	PromptSystem = "system"
+	// 单个 Pod 的分析提示词，数据为 PodAnalysisData
+	PromptPodAnalysis = "pod_analysis"
+	// 单个节点的分析提示词，数据为 NodeAnalysisData
+	PromptNodeAnalysis = "node_analysis"
+	// Pending Pod 的调度分析提示词，数据为 SchedulingAnalysisData
+	PromptSchedulingAnalysis = "scheduling_analysis"
+	// Service 连通性分析提示词，数据为 ServiceAnalysisData
+	PromptServiceAnalysis = "service_analysis"
+	// 存储问题分析提示词，数据为 StorageAnalysisData
+	PromptStorageAnalysis = "storage_analysis"
+	// Deployment 滚动更新分析提示词，数据为 RolloutAnalysisData
+	PromptRolloutAnalysis = "rollout_analysis"
+	// 安全问题修复补丁提示词，数据为 SecurityRemediationData
+	PromptSecurityRemediation = "security_remediation"
+	// 健康报告摘要提示词，数据为 ReportSummaryData
+	PromptReportSummary = "report_summary"
+	// YAML 生成器的系统提示词，无数据
+	PromptYAMLGenerator = "yaml_generator"
+)
+
+// PodAnalysisData 是 pod_analysis 模板的数据模型
+type PodAnalysisData struct {
+	// Pod 所在命名空间
+	Namespace string
+	// Pod 名称
+	Name string
+	// 按时间排序的 Warning 事件时间线，每行一条折叠后的事件，见 Event.String
+	Events []string
+	// 所属工作负载、ReplicaSet 版本、HPA、引用的 ConfigMap/Secret 和节点按时间排序的变化，
+	// 见 timelineEntry.String，可能为空
+	Timeline []string
+	// 容器日志，已按预算精简
+	Logs string
+	// 容器配置和状态摘要（YAML），可能为空
+	Spec string
+	// 所属工作负载，例如 Deployment/web；独立 Pod 时为空
+	Workload string
+	// 该工作负载下出现异常的 Pod 数量，以及其中的一部分名称
+	Affected     int
+	AffectedPods []string
+	// 检索到的运维手册章节，每项为 "[R1] 文件#标题" 加正文，未配置手册时为空
+	Runbooks []string
+	// 证据相似且已确认修复方法的历史事故，每项包含当时的诊断摘要和修复方法，可能为空
+	PastIncidents []string
+}
+
+// NodeAnalysisData 是 node_analysis 模板的数据模型
+type NodeAnalysisData struct {
+	// 节点名称
+	Name string
+	// 规则检查发现的问题
+	Findings []string
+	// 节点状况，格式为 Type=Status (Reason): Message
+	Conditions []string
+	// 节点污点
+	Taints []string
+	// 可分配资源和已请求资源的对比
+	Resources string
+	// 节点相关事件，已按预算截断
+	Events []string
+	// 在该节点上被驱逐的 Pod
+	Evicted []string
+	// 检索到的运维手册章节，每项为 "[R1] 文件#标题" 加正文，未配置手册时为空
+	Runbooks []string
+	// 证据相似且已确认修复方法的历史事故，每项包含当时的诊断摘要和修复方法，可能为空
+	PastIncidents []string
+}
+
+// SchedulingAnalysisData 是 scheduling_analysis 模板的数据模型
+type SchedulingAnalysisData struct {
+	Namespace string
+	Name      string
+	// 资源请求、nodeSelector、亲和性、容忍和拓扑分布约束（YAML）
+	Constraints string
+	// FailedScheduling 事件
+	Events []string
+	// 可用节点数和各谓词排除的节点数
+	Summary string
+	// 每个节点的判断结果，已按预算截断
+	Nodes []string
+	// 检索到的运维手册章节，每项为 "[R1] 文件#标题" 加正文，未配置手册时为空
+	Runbooks []string
+	// 证据相似且已确认修复方法的历史事故，每项包含当时的诊断摘要和修复方法，可能为空
+	PastIncidents []string
+}
+
+// ServiceAnalysisData 是 service_analysis 模板的数据模型
+type ServiceAnalysisData struct {
+	Namespace string
+	Name      string
+	// 规则检查发现的问题
+	Findings []string
+	// Service 的类型、选择器和端口（YAML）
+	Spec string
+	// 选择器命中的 Pod 及就绪状态
+	Pods []string
+	// EndpointSlice 中的地址及就绪状态
+	Endpoints []string
+	// 引用该 Service 的 Ingress 规则
+	Ingresses []string
+	// 作用于后端 Pod 的 NetworkPolicy（YAML）
+	NetworkPolicies []string
+	// 检索到的运维手册章节，每项为 "[R1] 文件#标题" 加正文，未配置手册时为空
+	Runbooks []string
+	// 证据相似且已确认修复方法的历史事故，每项包含当时的诊断摘要和修复方法，可能为空
+	PastIncidents []string
+}
+
+// StorageAnalysisData 是 storage_analysis 模板的数据模型
+type StorageAnalysisData struct {
+	// PersistentVolumeClaim 或 Pod
+	Kind      string
+	Namespace string
+	Name      string
+	// 规则检查发现的问题
+	Findings []string
+	// PVC、PV、StorageClass 的摘要（YAML），可能为空
+	Claim        string
+	Volume       string
+	StorageClass string
+	// 使用该 PVC 的 Pod 及所在节点
+	Pods []string
+	// 存储相关的 Warning 事件
+	Events []string
+	// 检索到的运维手册章节，每项为 "[R1] 文件#标题" 加正文，未配置手册时为空
+	Runbooks []string
+	// 证据相似且已确认修复方法的历史事故，每项包含当时的诊断摘要和修复方法，可能为空
+	PastIncidents []string
+}
+
+// RolloutAnalysisData 是 rollout_analysis 模板的数据模型
+type RolloutAnalysisData struct {
+	Namespace string
+	Name      string
+	// 规则检查发现的问题
+	Findings []string
+	// 各版本 ReplicaSet 的概况，新版本在前
+	Revisions []string
+	// 新版本相对上一版本的变化
+	Changes []string
+	// 副本数、更新策略和状况（YAML）
+	Status string
+	// 规则给出的回滚建议
+	Recommendation string
+	// 新版本中失败的代表 Pod，没有时为空；以下字段均来自该 Pod
+	PodName string
+	Events  []string
+	Logs    string
+	Spec    string
+	// 检索到的运维手册章节，每项为 "[R1] 文件#标题" 加正文，未配置手册时为空
+	Runbooks []string
+	// 证据相似且已确认修复方法的历史事故，每项包含当时的诊断摘要和修复方法，可能为空
+	PastIncidents []string
+}
+
+// SecurityRemediationData 是 security_remediation 模板的数据模型
+type SecurityRemediationData struct {
+	// 工作负载类型，例如 Deployment
+	Kind      string
+	Namespace string
+	Name      string
+	// 检查发现的问题，带 PSS 级别
+	Findings []string
+	// Pod 模板 spec 在对象中的路径，例如 spec.template.spec
+	PodSpecPath string
+	// 当前的 Pod 模板 spec（YAML），已按预算截断
+	Spec string
+	// 检索到的运维手册章节，每项为 "[R1] 文件#标题" 加正文，未配置手册时为空
+	Runbooks []string
+}
+
+// ReportSummaryData 是 report_summary 模板的数据模型
+type ReportSummaryData struct {
+	// kubeconfig 当前上下文
+	Context string
+	// 各级别的问题数量
+	Critical int
+	Warning  int
+	// 按严重级别和影响范围排好序的问题，已按预算截断
+	Items []string
+}
+
+// PromptConfig 对应配置文件中的 prompts 段
+type PromptConfig struct {
+	// 自定义模板目录，结构为 <dir>/<lang>/<name>.tmpl，存在时覆盖内置模板
+	Dir string `json:"dir"`
+	// 模板语言，zh 或 en，默认 zh
+	Lang string `json:"lang"`
+}
+
+// PromptInfo 描述一个模板的来源和版本
+type PromptInfo struct {
+	Name    string
+	Lang    string
+	Version string
+	// builtin 或自定义模板的文件路径
+	Source string
+}
+
+// PromptSet 负责加载和渲染提示词模板
+type PromptSet struct {
+	dir  string
+	lang string
+}
+
+var (
+	versionPattern = regexp.MustCompile(`^\{\{-?\s*/\*\s*version:\s*(\S+)`)
+	promptFuncs    = template.FuncMap{"join": strings.Join}
+)
+
+// NewPromptSet 根据配置创建模板集合
+func NewPromptSet(cfg PromptConfig) *PromptSet {
+	lang := cfg.Lang
+	if lang == "" {
+		lang = "zh"
+	}
+	dir := cfg.Dir
+	if dir != "" {
+		dir = expandHome(dir)
+	}
+	return &PromptSet{dir: dir, lang: lang}
+}
+
+// Lang 返回当前使用的模板语言
+func (p *PromptSet) Lang() string {
+	return p.lang
+}
+
+// Render 渲染指定模板
+func (p *PromptSet) Render(name string, data any) (string, error) {
+	text, _, err := p.load(name)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New(name).Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("解析模板 %s 失败: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("渲染模板 %s 失败: %w", name, err)
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+// Version 返回模板的版本标识，例如 pod_analysis@zh/1，自定义模板带 +custom 后缀
+func (p *PromptSet) Version(name string) string {
+	info, err := p.Info(name)
+	if err != nil {
+		return name + "@unknown"
+	}
+	v := fmt.Sprintf("%s@%s/%s", info.Name, info.Lang, info.Version)
+	if info.Source != "builtin" {
+		v += "+custom"
+	}
+	return v
+}
+
+// Info 返回模板的来源和版本
+func (p *PromptSet) Info(name string) (PromptInfo, error) {
+	text, source, err := p.load(name)
+	if err != nil {
+		return PromptInfo{}, err
+	}
+	version := "0"
+	if m := versionPattern.FindStringSubmatch(text); m != nil {
+		version = strings.TrimSuffix(m[1], "*/")
+	}
+	return PromptInfo{Name: name, Lang: p.lang, Version: version, Source: source}, nil
+}
+
+// List 返回当前语言下的所有模板
+func (p *PromptSet) List() ([]PromptInfo, error) {
+	names, err := BuiltinPromptNames()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]PromptInfo, 0, len(names))
+	for _, name := range names {
+		info, err := p.Info(name)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// BuiltinPromptNames 返回内置模板名称
+func BuiltinPromptNames() ([]string, error) {
+	entries, err := fs.ReadDir(builtinPrompts, "prompts/zh")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".tmpl"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ExportBuiltinPrompts 把内置模板导出到目录，方便用户在此基础上修改
+func ExportBuiltinPrompts(dir string) error {
+	return fs.WalkDir(builtinPrompts, "prompts", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := builtinPrompts.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(expandHome(dir), strings.TrimPrefix(path, "prompts/"))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+}
+
+// 依次查找用户目录和内置模板，返回模板内容和来源
+func (p *PromptSet) load(name string) (string, string, error) {
+	file := name + ".tmpl"
+	if p.dir != "" {
+		path := filepath.Join(p.dir, p.lang, file)
+		if data, err := os.ReadFile(path); err == nil {
+			return string(data), path, nil
+		}
+	}
+	data, err := builtinPrompts.ReadFile("prompts/" + p.lang + "/" + file)
+	if err != nil {
+		return "", "", fmt.Errorf("未找到模板 %s (语言 %s)", name, p.lang)
+	}
+	return string(data), "builtin", nil
+}
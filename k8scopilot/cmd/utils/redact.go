@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sashabaranov/go-openai"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// RedactConfig 对应配置文件中的 redact 段
+type RedactConfig struct {
+	// 关闭脱敏（不建议）
+	Disabled bool `json:"disabled"`
+	// 跳过的内置检测器，例如 ip、email
+	Skip []string `json:"skip"`
+	// 自定义检测规则
+	Patterns []RedactPattern `json:"patterns"`
+	// 高熵字符串的阈值（bit/字符），默认 4.5，小于 0 表示关闭；十六进制字符串按 2/3 换算
+	EntropyThreshold float64 `json:"entropyThreshold"`
+}
+
+// RedactPattern 是一条自定义的正则脱敏规则
+type RedactPattern struct {
+	Name  string `json:"name"`
+	Regex string `json:"regex"`
+}
+
+type detector struct {
+	name string
+	re   *regexp.Regexp
+	// 保留的前缀分组序号，例如 password=xxx 只遮盖 xxx
+	keepGroup int
+	// 匹配结尾需要原样保留的分隔符
+	suffix string
+	// 相同的原文替换成相同的占位符，方便模型关联同一个 IP/邮箱
+	stable bool
+}
+
+var builtinDetectors = []detector{
+	{name: "private_key", re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{name: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}`)},
+	{name: "aws_access_key", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "bearer", re: regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9._~+/-]+=*`), keepGroup: 1},
+	{name: "url_credential", re: regexp.MustCompile(`(\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@]+:)[^\s@/]+@`), keepGroup: 1, suffix: "@"},
+	{name: "password", re: regexp.MustCompile(`(?i)(\b(?:aws_secret_access_key|password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key)\b["']?\s*[:=]\s*["']?)[^\s"',;&]+`), keepGroup: 1},
+	{name: "email", re: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`), stable: true},
+	{name: "ip", re: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`), stable: true},
+}
+
+// 高熵检测的候选：足够长的 token/base64 片段
+var entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/_=-]{32,}`)
+
+// Redactor 在内容发送给 LLM 之前遮盖敏感信息，并统计遮盖情况
+type Redactor struct {
+	detectors        []detector
+	entropyThreshold float64
+
+	mu       sync.Mutex
+	counts   map[string]int
+	stableID map[string]string
+}
+
+// NewRedactor 根据配置创建 Redactor，配置关闭时返回 nil
+func NewRedactor(cfg RedactConfig) (*Redactor, error) {
+	if cfg.Disabled {
+		return nil, nil
+	}
+	skip := map[string]bool{}
+	for _, s := range cfg.Skip {
+		skip[strings.ToLower(s)] = true
+	}
+	r := &Redactor{
+		entropyThreshold: cfg.EntropyThreshold,
+		counts:           map[string]int{},
+		stableID:         map[string]string{},
+	}
+	if r.entropyThreshold == 0 {
+		r.entropyThreshold = 4.5
+	}
+	if skip["entropy"] {
+		r.entropyThreshold = -1
+	}
+	for _, d := range builtinDetectors {
+		if !skip[d.name] {
+			r.detectors = append(r.detectors, d)
+		}
+	}
+	for _, p := range cfg.Patterns {
+		re, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("脱敏规则 %s 无效: %w", p.Name, err)
+		}
+		r.detectors = append(r.detectors, detector{name: p.Name, re: re})
+	}
+	return r, nil
+}
+
+// Redact 遮盖文本中的敏感信息
+func (r *Redactor) Redact(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, d := range r.detectors {
+		text = d.re.ReplaceAllStringFunc(text, func(match string) string {
+			prefix := ""
+			if d.keepGroup > 0 {
+				sub := d.re.FindStringSubmatch(match)
+				if len(sub) > d.keepGroup {
+					prefix = sub[d.keepGroup]
+				}
+			}
+			r.counts[d.name]++
+			if d.stable {
+				return prefix + r.placeholder(d.name, match) + d.suffix
+			}
+			return prefix + "[REDACTED:" + d.name + "]" + d.suffix
+		})
+	}
+	if r.entropyThreshold > 0 {
+		text = r.redactEntropy(text)
+	}
+	return text
+}
+
+// 十六进制字符串每个字符最多 4 bit，base64 最多 6 bit，阈值按字符集的上限等比例换算，
+// 否则十六进制的密钥永远达不到 base64 的阈值
+const hexEntropyScale = 4.0 / 6.0
+
+// 镜像 digest 和容器 ID 是十六进制的，但不是敏感信息，诊断时还需要用到
+var digestPrefixes = []string{"sha256:", "sha512:"}
+
+func (r *Redactor) redactEntropy(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range entropyCandidate.FindAllStringIndex(text, -1) {
+		match := text[loc[0]:loc[1]]
+		threshold := r.entropyThreshold
+		if isHex(match) {
+			if slices.ContainsFunc(digestPrefixes, func(p string) bool { return strings.HasSuffix(text[:loc[0]], p) }) {
+				continue
+			}
+			threshold *= hexEntropyScale
+		}
+		if strings.Contains(match, "REDACTED") || shannonEntropy(match) < threshold {
+			continue
+		}
+		r.counts["entropy"]++
+		b.WriteString(text[last:loc[0]])
+		b.WriteString("[REDACTED:entropy]")
+		last = loc[1]
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// RedactMessages 遮盖对话消息中的文本内容
+func (r *Redactor) RedactMessages(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	if r == nil {
+		return messages
+	}
+	out := make([]openai.ChatCompletionMessage, len(messages))
+	for i, m := range messages {
+		m.Content = r.Redact(m.Content)
+		if len(m.MultiContent) > 0 {
+			parts := make([]openai.ChatMessagePart, len(m.MultiContent))
+			for j, p := range m.MultiContent {
+				p.Text = r.Redact(p.Text)
+				parts[j] = p
+			}
+			m.MultiContent = parts
+		}
+		out[i] = m
+	}
+	return out
+}
+
+// Report 返回各检测器的命中次数，例如 "email×2, jwt×1"，没有命中时返回空字符串
+func (r *Redactor) Report() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.counts))
+	for name := range r.counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s×%d", name, r.counts[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (r *Redactor) placeholder(kind, value string) string {
+	key := kind + "\x00" + value
+	if id, ok := r.stableID[key]; ok {
+		return id
+	}
+	n := 1
+	for k := range r.stableID {
+		if strings.HasPrefix(k, kind+"\x00") {
+			n++
+		}
+	}
+	id := fmt.Sprintf("<%s-%d>", kind, n)
+	r.stableID[key] = id
+	return id
+}
+
+func shannonEntropy(s string) float64 {
+	freq := map[rune]float64{}
+	for _, c := range s {
+		freq[c]++
+	}
+	var entropy float64
+	total := float64(len(s))
+	for _, n := range freq {
+		p := n / total
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// SecretKeys 返回 Secret 中的 key 列表，value 永远不会离开集群
+func SecretKeys(obj *unstructured.Unstructured) []string {
+	var keys []string
+	for _, field := range []string{"data", "stringData"} {
+		m, _, _ := unstructured.NestedMap(obj.Object, field)
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RedactSecretObject 清空 Secret 的 data/stringData 取值，只保留 key
+func RedactSecretObject(obj *unstructured.Unstructured) {
+	if obj.GetKind() != "Secret" {
+		return
+	}
+	for _, field := range []string{"data", "stringData"} {
+		m, found, _ := unstructured.NestedMap(obj.Object, field)
+		if !found {
+			continue
+		}
+		for k := range m {
+			// data 的取值必须是合法的 base64，否则无法再解析为 Secret
+			if field == "data" {
+				m[k] = base64.StdEncoding.EncodeToString([]byte("[REDACTED]"))
+			} else {
+				m[k] = "[REDACTED]"
+			}
+		}
+		_ = unstructured.SetNestedMap(obj.Object, m, field)
+	}
+	// kubectl apply 会把完整内容存在注解里
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+		annotations["kubectl.kubernetes.io/last-applied-configuration"] = "[REDACTED]"
+		obj.SetAnnotations(annotations)
+	}
+}
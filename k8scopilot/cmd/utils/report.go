@@ -0,0 +1,156 @@
+package utils
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ReportItem 是健康报告中的一个问题对象
+type ReportItem struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Severity  string
+	// 受影响的 Pod 数量，同级别的问题按它排序
+	Impact   int
+	Findings []string
+}
+
+// Object 返回 Kind namespace/name，集群级对象省略命名空间
+func (i ReportItem) Object() string {
+	if i.Namespace == "" {
+		return i.Kind + " " + i.Name
+	}
+	return i.Kind + " " + i.Namespace + "/" + i.Name
+}
+
+// Report 是集群健康报告
+type Report struct {
+	Context     string
+	GeneratedAt time.Time
+	// 模型生成的摘要，可能为空
+	Summary string
+	Items   []ReportItem
+	// 执行失败的检查
+	Errors []string
+}
+
+// Sort 按严重级别、影响范围排序
+func (r *Report) Sort() {
+	sort.SliceStable(r.Items, func(i, j int) bool {
+		a, b := r.Items[i], r.Items[j]
+		if SeverityRank(a.Severity) != SeverityRank(b.Severity) {
+			return SeverityRank(a.Severity) > SeverityRank(b.Severity)
+		}
+		return a.Impact > b.Impact
+	})
+}
+
+// Count 返回指定严重级别的问题数量
+func (r *Report) Count(severity string) int {
+	n := 0
+	for _, item := range r.Items {
+		if item.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+var reportFuncs = map[string]any{
+	"upper": strings.ToUpper,
+	"time":  func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+	"cell":  func(s string) string { return strings.ReplaceAll(strings.ReplaceAll(s, "|", "\\|"), "\n", " ") },
+}
+
+var markdownReport = template.Must(template.New("report").Funcs(reportFuncs).Parse(`# 集群健康报告
+
+- 集群: {{ if .Context }}{{ .Context }}{{ else }}-{{ end }}
+- 生成时间: {{ time .GeneratedAt }}
+- 问题: critical {{ .Count "critical" }}，warning {{ .Count "warning" }}
+{{- if .Summary }}
+
+## 摘要
+
+{{ .Summary }}
+{{- end }}
+
+## 问题列表
+{{ if .Items }}
+| 级别 | 对象 | 影响 Pod | 问题 |
+| --- | --- | --- | --- |
+{{- range .Items }}
+| {{ upper .Severity }} | {{ cell .Object }} | {{ .Impact }} | {{ range $i, $f := .Findings }}{{ if $i }}<br>{{ end }}{{ cell $f }}{{ end }} |
+{{- end }}
+{{ else }}
+✅ 未发现问题
+{{ end }}
+{{- if .Errors }}
+## 未完成的检查
+{{ range .Errors }}
+- {{ . }}
+{{- end }}
+{{ end -}}
+`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>集群健康报告</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ddd; padding: 6px; text-align: left; vertical-align: top; }
+.critical { color: #c62828; font-weight: bold; }
+.warning { color: #ef6c00; }
+.summary { white-space: pre-wrap; background: #f6f8fa; padding: 1em; }
+</style>
+</head>
+<body>
+<h1>集群健康报告</h1>
+<ul>
+<li>集群: {{ if .Context }}{{ .Context }}{{ else }}-{{ end }}</li>
+<li>生成时间: {{ time .GeneratedAt }}</li>
+<li>问题: critical {{ .Count "critical" }}，warning {{ .Count "warning" }}</li>
+</ul>
+{{- if .Summary }}
+<h2>摘要</h2>
+<div class="summary">{{ .Summary }}</div>
+{{- end }}
+<h2>问题列表</h2>
+{{- if .Items }}
+<table>
+<tr><th>级别</th><th>对象</th><th>影响 Pod</th><th>问题</th></tr>
+{{- range .Items }}
+<tr><td class="{{ .Severity }}">{{ upper .Severity }}</td><td>{{ .Object }}</td><td>{{ .Impact }}</td><td>{{ range $i, $f := .Findings }}{{ if $i }}<br>{{ end }}{{ $f }}{{ end }}</td></tr>
+{{- end }}
+</table>
+{{- else }}
+<p>✅ 未发现问题</p>
+{{- end }}
+{{- if .Errors }}
+<h2>未完成的检查</h2>
+<ul>
+{{- range .Errors }}
+<li>{{ . }}</li>
+{{- end }}
+</ul>
+{{- end }}
+</body>
+</html>
+`))
+
+// RenderMarkdown 输出 Markdown 格式的报告
+func RenderMarkdown(w io.Writer, r *Report) error {
+	return markdownReport.Execute(w, r)
+}
+
+// RenderHTML 输出 HTML 格式的报告，适合作为邮件正文
+func RenderHTML(w io.Writer, r *Report) error {
+	return htmlReport.Execute(w, r)
+}
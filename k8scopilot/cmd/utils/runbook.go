@@ -0,0 +1,401 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// RunbookConfig 对应配置文件中的 runbooks 段
+type RunbookConfig struct {
+	// Markdown 运维手册所在目录，会递归读取 .md 文件；为空时不启用
+	Dir string `json:"dir"`
+	// 每次分析最多放入提示词的章节数，默认 3
+	TopK int `json:"topK"`
+	// 向量检索使用的 embedding 模型，例如 text-embedding-3-small；为空时只使用 BM25
+	EmbeddingModel string `json:"embeddingModel"`
+}
+
+// RunbookSection 是运维手册中一个标题下的内容
+type RunbookSection struct {
+	// 相对于手册目录的路径，使用 / 分隔
+	File string `json:"file"`
+	// 从一级标题开始的标题路径，例如 OOM > 排查步骤
+	Heading string `json:"heading"`
+	Text    string `json:"text"`
+	// 标题和内容的摘要，用于缓存向量
+	Hash string `json:"hash"`
+
+	terms []string
+}
+
+// Ref 返回引用章节时使用的位置，例如 pods/oom.md#OOM > 排查步骤
+func (s RunbookSection) Ref() string {
+	if s.Heading == "" {
+		return s.File
+	}
+	return s.File + "#" + s.Heading
+}
+
+// RunbookHit 是一条检索结果
+type RunbookHit struct {
+	Section RunbookSection
+	Score   float64
+}
+
+// Embedder 把文本转换成向量，由 OpenAI.CreateEmbeddings 实现
+type Embedder func(ctx context.Context, inputs []string) ([][]float32, error)
+
+// RunbookIndex 是运维手册的本地索引：BM25 在加载时建立，向量缓存在 <dataDir>/runbooks.json
+type RunbookIndex struct {
+	Sections []RunbookSection
+	topK     int
+	model    string
+	// 每个词出现在多少个章节中
+	df     map[string]int
+	avgLen float64
+	// 按章节摘要保存的向量
+	vectors   map[string][]float32
+	cachePath string
+}
+
+// 默认放入提示词的章节数
+const defaultRunbookTopK = 3
+
+// 向量检索的最低相似度，低于该值的章节不作为候选，避免引入无关内容
+const minRunbookSimilarity = 0.3
+
+// LoadRunbooks 读取手册目录并建立 BM25 索引，未配置目录时返回 nil
+func LoadRunbooks(cfg RunbookConfig, dataDir string) (*RunbookIndex, error) {
+	if cfg.Dir == "" {
+		return nil, nil
+	}
+	dir := expandHome(cfg.Dir)
+	idx := &RunbookIndex{
+		topK:      cfg.TopK,
+		model:     cfg.EmbeddingModel,
+		df:        map[string]int{},
+		vectors:   map[string][]float32{},
+		cachePath: filepath.Join(dataDir, "runbooks.json"),
+	}
+	if idx.topK <= 0 {
+		idx.topK = defaultRunbookTopK
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if ext := strings.ToLower(filepath.Ext(path)); ext != ".md" && ext != ".markdown" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		idx.Sections = append(idx.Sections, splitMarkdown(filepath.ToSlash(rel), string(data))...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取运维手册失败: %w", err)
+	}
+
+	total := 0
+	for i := range idx.Sections {
+		s := &idx.Sections[i]
+		// 标题比正文更能说明章节的主题，计两次
+		s.terms = slices.Concat(RunbookTerms(s.Heading), RunbookTerms(s.Heading), RunbookTerms(s.Text))
+		total += len(s.terms)
+		seen := map[string]bool{}
+		for _, t := range s.terms {
+			if !seen[t] {
+				seen[t] = true
+				idx.df[t]++
+			}
+		}
+	}
+	if len(idx.Sections) > 0 {
+		idx.avgLen = float64(total) / float64(len(idx.Sections))
+	}
+	if idx.model != "" {
+		idx.loadVectors()
+	}
+	return idx, nil
+}
+
+// splitMarkdown 按标题把文档切分成章节，代码块中的 # 不视为标题
+func splitMarkdown(file, content string) []RunbookSection {
+	var sections []RunbookSection
+	// 各级标题，下标为级别减一
+	var headings [6]string
+	var body []string
+	inCode := false
+	flush := func() {
+		text := strings.TrimSpace(strings.Join(body, "\n"))
+		body = nil
+		if text == "" {
+			return
+		}
+		heading := strings.Join(slices.DeleteFunc(slices.Clone(headings[:]), func(h string) bool { return h == "" }), " > ")
+		sum := sha256.Sum256([]byte(heading + "\n" + text))
+		sections = append(sections, RunbookSection{File: file, Heading: heading, Text: text, Hash: hex.EncodeToString(sum[:8])})
+	}
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+		}
+		level := 0
+		if !inCode {
+			for level < len(trimmed) && level < 6 && trimmed[level] == '#' {
+				level++
+			}
+		}
+		if level == 0 || len(trimmed) == level || trimmed[level] != ' ' {
+			body = append(body, line)
+			continue
+		}
+		flush()
+		// 保留上级标题，清除同级及以下的标题
+		headings[level-1] = strings.TrimSpace(strings.TrimRight(trimmed[level:], "#"))
+		clear(headings[level:])
+	}
+	flush()
+	return sections
+}
+
+// 不参与检索的常见英文词
+var runbookStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "this": true, "that": true,
+	"from": true, "are": true, "was": true, "not": true, "is": true, "to": true,
+	"of": true, "in": true, "on": true, "an": true, "be": true, "or": true,
+	"by": true, "it": true, "at": true, "as": true, "if": true,
+}
+
+// RunbookTerms 把文本切分成检索用的词：英文和数字按单词切分并转成小写，纯数字丢弃；
+// 中文没有分隔符，按相邻两个字切分
+func RunbookTerms(text string) []string {
+	var terms []string
+	var word []rune
+	var han []rune
+	flushWord := func() {
+		w := string(word)
+		word = word[:0]
+		if len(w) < 2 || runbookStopWords[w] || strings.Trim(w, "0123456789") == "" {
+			return
+		}
+		terms = append(terms, w)
+	}
+	flushHan := func() {
+		if len(han) == 1 {
+			terms = append(terms, string(han))
+		}
+		for i := 0; i+1 < len(han); i++ {
+			terms = append(terms, string(han[i:i+2]))
+		}
+		han = han[:0]
+	}
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r):
+			flushWord()
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushHan()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushHan()
+		}
+	}
+	flushWord()
+	flushHan()
+	return terms
+}
+
+// TopK 返回每次分析放入提示词的章节数
+func (x *RunbookIndex) TopK() int {
+	return x.topK
+}
+
+// UsesEmbeddings 返回是否配置了向量检索
+func (x *RunbookIndex) UsesEmbeddings() bool {
+	return x.model != ""
+}
+
+// Search 返回与查询最相关的 k 个章节
+// 只有 BM25 时按 BM25 得分排序；query 有向量时用倒数排名融合合并 BM25 和向量相似度的排名
+func (x *RunbookIndex) Search(query string, queryVector []float32, k int) []RunbookHit {
+	if x == nil || len(x.Sections) == 0 {
+		return nil
+	}
+	bm25 := x.bm25(RunbookTerms(query))
+	if len(queryVector) == 0 {
+		return topHits(x.Sections, bm25, k)
+	}
+	similarity := make([]float64, len(x.Sections))
+	for i, s := range x.Sections {
+		if v, ok := x.vectors[s.Hash]; ok {
+			if c := cosine(queryVector, v); c >= minRunbookSimilarity {
+				similarity[i] = c
+			}
+		}
+	}
+	// 倒数排名融合，60 是常用的平滑常数
+	fused := make([]float64, len(x.Sections))
+	for _, scores := range [][]float64{bm25, similarity} {
+		for rank, i := range rankIndices(scores) {
+			fused[i] += 1 / float64(60+rank+1)
+		}
+	}
+	return topHits(x.Sections, fused, k)
+}
+
+// bm25 计算每个章节的 BM25 得分，k1=1.2，b=0.75
+func (x *RunbookIndex) bm25(query []string) []float64 {
+	const k1, b = 1.2, 0.75
+	n := float64(len(x.Sections))
+	scores := make([]float64, len(x.Sections))
+	unique := slices.Compact(slices.Sorted(slices.Values(query)))
+	for i, s := range x.Sections {
+		tf := map[string]int{}
+		for _, t := range s.terms {
+			tf[t]++
+		}
+		for _, q := range unique {
+			f := float64(tf[q])
+			if f == 0 {
+				continue
+			}
+			df := float64(x.df[q])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			scores[i] += idf * f * (k1 + 1) / (f + k1*(1-b+b*float64(len(s.terms))/x.avgLen))
+		}
+	}
+	return scores
+}
+
+// topHits 返回得分大于 0 的前 k 个章节
+func topHits(sections []RunbookSection, scores []float64, k int) []RunbookHit {
+	var hits []RunbookHit
+	for _, i := range rankIndices(scores) {
+		if len(hits) == k {
+			break
+		}
+		hits = append(hits, RunbookHit{Section: sections[i], Score: scores[i]})
+	}
+	return hits
+}
+
+// rankIndices 按得分从高到低返回得分大于 0 的下标，得分相同时保持手册中的顺序
+func rankIndices(scores []float64) []int {
+	var ranked []int
+	for i, score := range scores {
+		if score > 0 {
+			ranked = append(ranked, i)
+		}
+	}
+	sort.SliceStable(ranked, func(a, b int) bool { return scores[ranked[a]] > scores[ranked[b]] })
+	return ranked
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// runbookVectors 是向量缓存文件的格式，更换模型后缓存失效
+type runbookVectors struct {
+	Model   string               `json:"model"`
+	Vectors map[string][]float32 `json:"vectors"`
+}
+
+func (x *RunbookIndex) loadVectors() {
+	data, err := os.ReadFile(x.cachePath)
+	if err != nil {
+		return
+	}
+	var cached runbookVectors
+	if json.Unmarshal(data, &cached) != nil || cached.Model != x.model {
+		return
+	}
+	x.vectors = cached.Vectors
+}
+
+// 每次 embedding 请求最多包含的章节数
+const embedBatchSize = 64
+
+// EmbedSections 为还没有向量的章节计算向量并写入缓存，返回新计算的章节数
+// 已删除章节的向量同时从缓存中清除
+func (x *RunbookIndex) EmbedSections(ctx context.Context, embed Embedder) (int, error) {
+	var pending []RunbookSection
+	live := map[string]bool{}
+	for _, s := range x.Sections {
+		live[s.Hash] = true
+		if _, ok := x.vectors[s.Hash]; !ok && !slices.ContainsFunc(pending, func(p RunbookSection) bool { return p.Hash == s.Hash }) {
+			pending = append(pending, s)
+		}
+	}
+	stale := false
+	for hash := range x.vectors {
+		if !live[hash] {
+			delete(x.vectors, hash)
+			stale = true
+		}
+	}
+	for start := 0; start < len(pending); start += embedBatchSize {
+		batch := pending[start:min(start+embedBatchSize, len(pending))]
+		inputs := make([]string, len(batch))
+		for i, s := range batch {
+			inputs[i] = s.Heading + "\n" + s.Text
+		}
+		vectors, err := embed(ctx, inputs)
+		if err != nil {
+			// 已经算好的部分仍然保存，下次只补齐剩下的
+			_ = x.saveVectors()
+			return start, err
+		}
+		for i, s := range batch {
+			x.vectors[s.Hash] = vectors[i]
+		}
+	}
+	if len(pending) == 0 && !stale {
+		return 0, nil
+	}
+	return len(pending), x.saveVectors()
+}
+
+func (x *RunbookIndex) saveVectors() error {
+	data, err := json.Marshal(runbookVectors{Model: x.model, Vectors: x.vectors})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(x.cachePath), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(x.cachePath, data, 0o600)
+}
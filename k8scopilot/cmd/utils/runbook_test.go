@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunbookTerms(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"The Pod was OOMKilled", []string{"pod", "oomkilled"}},
+		// 纯数字和单个字母丢弃，字母数字混合保留
+		{"exit code 137 on node-2 a", []string{"exit", "code", "node"}},
+		{"内存不足", []string{"内存", "存不", "不足"}},
+		{"查看 kubectl logs 日志", []string{"查看", "kubectl", "logs", "日志"}},
+		{"慢", []string{"慢"}},
+	}
+	for _, tt := range tests {
+		if got := RunbookTerms(tt.text); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("RunbookTerms(%q) = %v，期望 %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplitMarkdown(t *testing.T) {
+	content := "前言\n# OOM\n概述\n## 排查步骤\n```sh\n# 不是标题\nkubectl top pod\n```\n### 细节 ##\n内容\n## 修复\n调大 limits\n#not-heading\n"
+	want := []struct{ heading, text string }{
+		{"", "前言"},
+		{"OOM", "概述"},
+		{"OOM > 排查步骤", "```sh\n# 不是标题\nkubectl top pod\n```"},
+		{"OOM > 排查步骤 > 细节", "内容"},
+		{"OOM > 修复", "调大 limits\n#not-heading"},
+	}
+	got := splitMarkdown("pods/oom.md", content)
+	if len(got) != len(want) {
+		t.Fatalf("章节数 %d，期望 %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].Heading != w.heading || got[i].Text != w.text {
+			t.Errorf("第 %d 个章节: %q %q，期望 %q %q", i, got[i].Heading, got[i].Text, w.heading, w.text)
+		}
+	}
+	if got[2].Ref() != "pods/oom.md#OOM > 排查步骤" || got[0].Ref() != "pods/oom.md" {
+		t.Errorf("Ref 不对: %s, %s", got[2].Ref(), got[0].Ref())
+	}
+}
+
+func writeRunbooks(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunbookSearchBM25(t *testing.T) {
+	dir := writeRunbooks(t, map[string]string{
+		"pods/oom.md":      "# OOMKilled\n容器因内存不足被杀死时，检查 limits 并调大内存。\n",
+		"pods/image.md":    "# ImagePullBackOff\n镜像拉取失败时检查镜像名和 imagePullSecrets。\n",
+		"network/dns.md":   "# DNS 解析失败\n检查 CoreDNS Pod 是否正常，以及 Service 名称是否正确。\n",
+		"network/notes.md": "# 杂项\n内存 内存 内存\n",
+		"README.txt":       "OOMKilled 不应被读取",
+	})
+	idx, err := LoadRunbooks(RunbookConfig{Dir: dir}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Sections) != 4 || idx.TopK() != defaultRunbookTopK || idx.UsesEmbeddings() {
+		t.Fatalf("索引不对: %d 个章节, topK %d", len(idx.Sections), idx.TopK())
+	}
+
+	tests := []struct {
+		name, query string
+		k           int
+		want        []string
+	}{
+		{"标题命中排在前面", "Pod OOMKilled 内存不足", 2, []string{"pods/oom.md#OOMKilled", "network/notes.md#杂项"}},
+		{"只返回有得分的章节", "ImagePullBackOff", 3, []string{"pods/image.md#ImagePullBackOff"}},
+		{"中文查询", "DNS 解析失败", 1, []string{"network/dns.md#DNS 解析失败"}},
+		{"没有相关章节", "etcd 证书过期", 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, h := range idx.Search(tt.query, nil, tt.k) {
+				got = append(got, h.Section.Ref())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("检索结果 %v，期望 %v", got, tt.want)
+			}
+		})
+	}
+
+	if idx, err := LoadRunbooks(RunbookConfig{}, t.TempDir()); idx != nil || err != nil {
+		t.Errorf("未配置目录时应返回 nil: %v, %v", idx, err)
+	}
+}
+
+func TestRunbookEmbedSections(t *testing.T) {
+	dir := writeRunbooks(t, map[string]string{
+		"oom.md": "# OOMKilled\n调大内存\n",
+		"dns.md": "# DNS\n检查 CoreDNS\n",
+	})
+	dataDir := t.TempDir()
+	cfg := RunbookConfig{Dir: dir, EmbeddingModel: "text-embedding-3-small"}
+	calls := 0
+	embed := func(_ context.Context, inputs []string) ([][]float32, error) {
+		calls += len(inputs)
+		vectors := make([][]float32, len(inputs))
+		for i, in := range inputs {
+			if strings.Contains(in, "OOM") {
+				vectors[i] = []float32{1, 0}
+			} else {
+				vectors[i] = []float32{0, 1}
+			}
+		}
+		return vectors, nil
+	}
+
+	idx, err := LoadRunbooks(cfg, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := idx.EmbedSections(context.Background(), embed); n != 2 || err != nil {
+		t.Fatalf("EmbedSections = %d, %v", n, err)
+	}
+	// 查询与正文没有共同的词时，仍能通过向量检索到
+	hits := idx.Search("container killed", []float32{0.9, 0.1}, 1)
+	if len(hits) != 1 || hits[0].Section.File != "oom.md" {
+		t.Errorf("向量检索结果不对: %+v", hits)
+	}
+
+	// 重新加载后使用缓存，只计算修改过的章节
+	if err := os.WriteFile(filepath.Join(dir, "dns.md"), []byte("# DNS\n检查 CoreDNS 和 NodeLocal DNSCache\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx, err = LoadRunbooks(cfg, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := idx.EmbedSections(context.Background(), embed); n != 1 || err != nil || calls != 3 {
+		t.Errorf("EmbedSections = %d, %v，共计算 %d 次", n, err, calls)
+	}
+	if len(idx.vectors) != 2 {
+		t.Errorf("旧章节的向量应被清除，剩余 %d 个", len(idx.vectors))
+	}
+
+	// 更换模型后缓存失效
+	cfg.EmbeddingModel = "text-embedding-3-large"
+	idx, err = LoadRunbooks(cfg, dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.vectors) != 0 {
+		t.Errorf("更换模型后不应使用缓存: %d", len(idx.vectors))
+	}
+}
@@ -0,0 +1,440 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-errors/errors"
+	"golang.org/x/time/rate"
+)
+
+// 诊断结果的严重级别
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+var severityRank = map[string]int{
+	SeverityInfo:     0,
+	SeverityWarning:  1,
+	SeverityCritical: 2,
+}
+
+// SeverityRank 返回严重级别的排序值，越大越严重
+func SeverityRank(severity string) int {
+	return severityRank[strings.ToLower(severity)]
+}
+
+// Diagnosis 是一次分析的结果，会被投递到各个通知渠道
+type Diagnosis struct {
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Severity  string    `json:"severity"`
+	Events    []string  `json:"events,omitempty"`
+	Result    string    `json:"result"`
+	Time      time.Time `json:"time"`
+	// 生成结果所用的提示词模板版本
+	PromptVersion string `json:"promptVersion,omitempty"`
+}
+
+// Sink 是诊断结果的通知渠道
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, batch []Diagnosis, suppressed int) error
+}
+
+// NotifyConfig 对应配置文件中的 notify 段
+type NotifyConfig struct {
+	Sinks []SinkConfig `json:"sinks"`
+}
+
+// SinkConfig 描述一个通知渠道以及它的路由、批量和限流规则
+type SinkConfig struct {
+	Name string `json:"name"`
+	// slack / webhook / email
+	Type string `json:"type"`
+
+	// slack 和 webhook 使用
+	URL    string `json:"url"`
+	Secret string `json:"secret"`
+
+	// email 使用
+	SMTPHost string   `json:"smtpHost"`
+	SMTPPort int      `json:"smtpPort"`
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+
+	// 路由规则：为空表示全部命名空间
+	Namespaces  []string `json:"namespaces"`
+	MinSeverity string   `json:"minSeverity"`
+
+	// 每批最多多少条诊断，默认 10
+	BatchSize int `json:"batchSize"`
+	// 每分钟最多发送多少批，默认 6，超出的诊断会被丢弃并计数
+	MaxPerMinute int `json:"maxPerMinute"`
+}
+
+// 单次发送的超时时间，避免通知服务卡住时分析命令一直不退出
+const sinkTimeout = 10 * time.Second
+
+// NewSink 根据配置创建对应的通知渠道
+func NewSink(cfg SinkConfig) (Sink, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "slack":
+		if cfg.URL == "" {
+			return nil, errors.New("slack sink " + cfg.Name + " 缺少 url")
+		}
+		return &SlackSink{name: cfg.Name, url: cfg.URL, client: &http.Client{Timeout: sinkTimeout}}, nil
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, errors.New("webhook sink " + cfg.Name + " 缺少 url")
+		}
+		return &WebhookSink{name: cfg.Name, url: cfg.URL, secret: cfg.Secret, client: &http.Client{Timeout: sinkTimeout}}, nil
+	case "email":
+		if cfg.SMTPHost == "" || cfg.From == "" || len(cfg.To) == 0 {
+			return nil, errors.New("email sink " + cfg.Name + " 缺少 smtpHost/from/to")
+		}
+		port := cfg.SMTPPort
+		if port == 0 {
+			port = 25
+		}
+		return &EmailSink{
+			name:     cfg.Name,
+			addr:     net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(port)),
+			host:     cfg.SMTPHost,
+			username: cfg.Username,
+			password: cfg.Password,
+			from:     cfg.From,
+			to:       cfg.To,
+		}, nil
+	default:
+		return nil, fmt.Errorf("不支持的通知类型: %s", cfg.Type)
+	}
+}
+
+// SlackSink 通过 Slack 兼容的 Incoming Webhook 发送消息
+type SlackSink struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+func (s *SlackSink) Name() string { return s.name }
+
+func (s *SlackSink) Send(ctx context.Context, batch []Diagnosis, suppressed int) error {
+	body, err := json.Marshal(map[string]string{"text": FormatDiagnoses(batch, suppressed)})
+	if err != nil {
+		return err
+	}
+	return postJSON(ctx, s.client, s.url, body, nil)
+}
+
+// WebhookSink 以 JSON 发送原始诊断结果，配置 secret 时附带 HMAC-SHA256 签名
+type WebhookSink struct {
+	name   string
+	url    string
+	secret string
+	client *http.Client
+}
+
+// WebhookPayload 是通用 webhook 的请求体
+type WebhookPayload struct {
+	Diagnoses  []Diagnosis `json:"diagnoses"`
+	Suppressed int         `json:"suppressed"`
+}
+
+// WebhookSignatureHeader 存放请求体的 HMAC-SHA256 签名，格式为 sha256=<hex>
+const WebhookSignatureHeader = "X-K8scopilot-Signature"
+
+func (w *WebhookSink) Name() string { return w.name }
+
+func (w *WebhookSink) Send(ctx context.Context, batch []Diagnosis, suppressed int) error {
+	body, err := json.Marshal(WebhookPayload{Diagnoses: batch, Suppressed: suppressed})
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{}
+	if w.secret != "" {
+		headers[WebhookSignatureHeader] = "sha256=" + SignPayload(w.secret, body)
+	}
+	return postJSON(ctx, w.client, w.url, body, headers)
+}
+
+// SignPayload 计算请求体的 HMAC-SHA256 签名，接收方可用同样的 secret 校验
+func SignPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// EmailSink 通过 SMTP 发送邮件
+type EmailSink struct {
+	name     string
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+	to       []string
+}
+
+func (e *EmailSink) Name() string { return e.name }
+
+func (e *EmailSink) Send(ctx context.Context, batch []Diagnosis, suppressed int) error {
+	var auth smtp.Auth
+	if e.username != "" {
+		auth = smtp.PlainAuth("", e.username, e.password, e.host)
+	}
+	return e.sendMail(ctx, auth, e.message(batch, suppressed, time.Now()))
+}
+
+// message 生成完整的邮件，中文主题按 RFC 2047 编码，正文用 base64 避免 SMTP 服务器改写 8bit 内容
+func (e *EmailSink) message(batch []Diagnosis, suppressed int, now time.Time) []byte {
+	subject := fmt.Sprintf("[k8scopilot] %d 条诊断结果", len(batch))
+	domain := "k8scopilot.local"
+	if i := strings.LastIndex(e.from, "@"); i >= 0 {
+		domain = strings.Trim(e.from[i+1:], "> ")
+	}
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+
+	var b bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&b, "%s: %s\r\n", k, v) }
+	header("From", e.from)
+	header("To", strings.Join(e.to, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", now.Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%d.%s@%s>", now.UnixNano(), hex.EncodeToString(id), domain))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=UTF-8")
+	header("Content-Transfer-Encoding", "base64")
+	b.WriteString("\r\n")
+	body := base64.StdEncoding.EncodeToString([]byte(FormatDiagnoses(batch, suppressed)))
+	// base64 正文每行不超过 76 个字符
+	for len(body) > 76 {
+		b.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	b.WriteString(body + "\r\n")
+	return b.Bytes()
+}
+
+// sendMail 和 smtp.SendMail 的流程相同，但连接和整个会话都受 ctx 和 sinkTimeout 限制
+func (e *EmailSink) sendMail(ctx context.Context, auth smtp.Auth, msg []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, sinkTimeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(e.from); err != nil {
+		return err
+	}
+	for _, to := range e.to {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s 返回状态码 %d", url, resp.StatusCode)
+	}
+	return nil
+}
+
+// FormatDiagnoses 把一批诊断结果格式化为纯文本
+func FormatDiagnoses(batch []Diagnosis, suppressed int) string {
+	var b strings.Builder
+	for i, d := range batch {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "[%s] %s %s/%s\n", strings.ToUpper(d.Severity), d.Kind, d.Namespace, d.Name)
+		b.WriteString(d.Result)
+	}
+	if suppressed > 0 {
+		fmt.Fprintf(&b, "\n\n另有 %d 条诊断因限流被省略", suppressed)
+	}
+	return b.String()
+}
+
+// Notifier 按路由规则把诊断结果分发到各个渠道，并负责批量和限流
+type Notifier struct {
+	routes []*route
+}
+
+type route struct {
+	cfg        SinkConfig
+	sink       Sink
+	limiter    *rate.Limiter
+	pending    []Diagnosis
+	suppressed int
+}
+
+// NewNotifier 根据配置创建 Notifier，没有配置任何渠道时返回 nil
+func NewNotifier(cfg NotifyConfig) (*Notifier, error) {
+	if len(cfg.Sinks) == 0 {
+		return nil, nil
+	}
+	n := &Notifier{}
+	for _, sc := range cfg.Sinks {
+		sink, err := NewSink(sc)
+		if err != nil {
+			return nil, err
+		}
+		if sc.BatchSize <= 0 {
+			sc.BatchSize = 10
+		}
+		if sc.MaxPerMinute <= 0 {
+			sc.MaxPerMinute = 6
+		}
+		n.routes = append(n.routes, &route{
+			cfg:     sc,
+			sink:    sink,
+			limiter: rate.NewLimiter(rate.Limit(float64(sc.MaxPerMinute)/60), sc.MaxPerMinute),
+		})
+	}
+	return n, nil
+}
+
+// Notify 把诊断结果放入匹配渠道的批次中，批次满时立即发送
+func (n *Notifier) Notify(ctx context.Context, d Diagnosis) error {
+	if n == nil {
+		return nil
+	}
+	if d.Time.IsZero() {
+		d.Time = time.Now()
+	}
+	var errs []string
+	for _, r := range n.routes {
+		if !r.match(d) {
+			continue
+		}
+		r.pending = append(r.pending, d)
+		if len(r.pending) >= r.cfg.BatchSize {
+			if err := r.flush(ctx); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Flush 发送所有渠道中尚未发送的诊断结果，程序退出前应调用
+func (n *Notifier) Flush(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	var errs []string
+	for _, r := range n.routes {
+		if err := r.flush(ctx); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (r *route) match(d Diagnosis) bool {
+	if severityRank[d.Severity] < severityRank[strings.ToLower(r.cfg.MinSeverity)] {
+		return false
+	}
+	if len(r.cfg.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range r.cfg.Namespaces {
+		if ns == "*" || ns == d.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *route) flush(ctx context.Context) error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	batch := r.pending
+	r.pending = nil
+	// 超出限流的批次直接丢弃，只记录数量，在下一次成功发送时附带说明
+	if !r.limiter.Allow() {
+		r.suppressed += len(batch)
+		return nil
+	}
+	if err := r.sink.Send(ctx, batch, r.suppressed); err != nil {
+		return fmt.Errorf("通知 %s 发送失败: %w", r.sink.Name(), err)
+	}
+	r.suppressed = 0
+	return nil
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestWebhookSinkSignature(t *testing.T) {
+	tests := []struct {
+		name, secret string
+	}{
+		{"配置 secret 时附带签名", "s3cret"},
+		{"没有 secret 时不签名", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			var signature string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				signature = r.Header.Get(WebhookSignatureHeader)
+			}))
+			defer server.Close()
+
+			sink, err := NewSink(SinkConfig{Name: "hook", Type: "webhook", URL: server.URL, Secret: tt.secret})
+			if err != nil {
+				t.Fatal(err)
+			}
+			batch := []Diagnosis{{Kind: "Deployment", Namespace: "shop", Name: "web", Severity: SeverityCritical, Result: "OOMKilled"}}
+			if err := sink.Send(context.Background(), batch, 2); err != nil {
+				t.Fatal(err)
+			}
+			var payload WebhookPayload
+			if err := json.Unmarshal(body, &payload); err != nil || len(payload.Diagnoses) != 1 || payload.Suppressed != 2 {
+				t.Errorf("请求体不对: %s, %v", body, err)
+			}
+			want := ""
+			if tt.secret != "" {
+				want = "sha256=" + SignPayload(tt.secret, body)
+			}
+			if signature != want {
+				t.Errorf("签名 %q，期望 %q", signature, want)
+			}
+		})
+	}
+}
+
+func TestSignPayload(t *testing.T) {
+	// RFC 4231 测试用例 2
+	got := SignPayload("Jefe", []byte("what do ya want for nothing?"))
+	if got != "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843" {
+		t.Errorf("签名不对: %s", got)
+	}
+}
+
+// recordSink 记录收到的批次，用于测试路由、批量和限流
+type recordSink struct {
+	batches    [][]Diagnosis
+	suppressed []int
+}
+
+func (r *recordSink) Name() string { return "record" }
+
+func (r *recordSink) Send(_ context.Context, batch []Diagnosis, suppressed int) error {
+	r.batches = append(r.batches, batch)
+	r.suppressed = append(r.suppressed, suppressed)
+	return nil
+}
+
+// newTestLimiter 返回不补充令牌的限流器，方便测试
+func newTestLimiter(burst int) *rate.Limiter {
+	return rate.NewLimiter(0, burst)
+}
+
+func testNotifier(cfg SinkConfig, burst int) (*Notifier, *recordSink) {
+	sink := &recordSink{}
+	return &Notifier{routes: []*route{{cfg: cfg, sink: sink, limiter: newTestLimiter(burst)}}}, sink
+}
+
+func TestNotifierBatchAndRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SinkConfig
+		burst   int
+		send    []Diagnosis
+		batches []int
+		// 每次发送时附带的被省略条数
+		suppressed []int
+	}{
+		{
+			name:    "满一批立即发送，剩余的在 Flush 时发送",
+			cfg:     SinkConfig{BatchSize: 2},
+			burst:   10,
+			send:    diagnoses("shop", SeverityWarning, 5),
+			batches: []int{2, 2, 1},
+		},
+		{
+			name:    "按命名空间和严重级别路由",
+			cfg:     SinkConfig{BatchSize: 10, Namespaces: []string{"shop"}, MinSeverity: "Critical"},
+			burst:   10,
+			send:    append(append(diagnoses("shop", SeverityWarning, 2), diagnoses("kube-system", SeverityCritical, 2)...), diagnoses("shop", SeverityCritical, 1)...),
+			batches: []int{1},
+		},
+		{
+			name:       "超出限流的批次被丢弃，下次发送时附带数量",
+			cfg:        SinkConfig{BatchSize: 1},
+			burst:      1,
+			send:       diagnoses("shop", SeverityWarning, 3),
+			batches:    []int{1},
+			suppressed: []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, sink := testNotifier(tt.cfg, tt.burst)
+			for _, d := range tt.send {
+				if err := n.Notify(context.Background(), d); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := n.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, b := range sink.batches {
+				got = append(got, len(b))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.batches) {
+				t.Errorf("批次 %v，期望 %v", got, tt.batches)
+			}
+			if tt.suppressed != nil && fmt.Sprint(sink.suppressed) != fmt.Sprint(tt.suppressed) {
+				t.Errorf("省略 %v，期望 %v", sink.suppressed, tt.suppressed)
+			}
+		})
+	}
+
+	// 令牌恢复后的第一批附带此前被省略的数量
+	n, sink := testNotifier(SinkConfig{BatchSize: 1}, 1)
+	for _, d := range diagnoses("shop", SeverityWarning, 3) {
+		_ = n.Notify(context.Background(), d)
+	}
+	n.routes[0].limiter = newTestLimiter(1)
+	_ = n.Notify(context.Background(), diagnoses("shop", SeverityWarning, 1)[0])
+	if fmt.Sprint(sink.suppressed) != "[0 2]" {
+		t.Errorf("省略 %v，期望 [0 2]", sink.suppressed)
+	}
+}
+
+func diagnoses(namespace, severity string, count int) []Diagnosis {
+	var out []Diagnosis
+	for i := 0; i < count; i++ {
+		out = append(out, Diagnosis{Kind: "Deployment", Namespace: namespace, Name: fmt.Sprintf("web-%d", i), Severity: severity, Result: "结果"})
+	}
+	return out
+}
+
+func TestEmailSinkMessage(t *testing.T) {
+	sink := &EmailSink{from: "k8scopilot <ops@example.com>", to: []string{"a@example.com", "b@example.com"}}
+	batch := diagnoses("shop", SeverityCritical, 2)
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	msg, err := mail.ReadMessage(strings.NewReader(string(sink.message(batch, 1, now))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	date, err := msg.Header.Date()
+	if err != nil || !date.Equal(now) {
+		t.Errorf("Date 不对: %v, %v", date, err)
+	}
+	tests := []struct {
+		header, want string
+	}{
+		{"Subject", "[k8scopilot] 2 条诊断结果"},
+		{"MIME-Version", "1.0"},
+		{"To", "a@example.com, b@example.com"},
+		{"Content-Transfer-Encoding", "base64"},
+	}
+	for _, tt := range tests {
+		got := msg.Header.Get(tt.header)
+		if tt.header == "Subject" {
+			got = subject
+		}
+		if got != tt.want {
+			t.Errorf("%s: %q，期望 %q", tt.header, got, tt.want)
+		}
+	}
+	if id := msg.Header.Get("Message-ID"); !strings.HasPrefix(id, "<") || !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Message-ID 不对: %q", id)
+	}
+	body, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, msg.Body))
+	if err != nil || !strings.Contains(string(body), "另有 1 条诊断因限流被省略") {
+		t.Errorf("正文不对: %q, %v", body, err)
+	}
+	// 原始主题中不能有未编码的中文
+	if raw := msg.Header.Get("Subject"); strings.Contains(raw, "诊断") {
+		t.Errorf("主题没有编码: %q", raw)
+	}
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// SnapshotResources 是快照中包含的资源，Secret 只保留 key
+var SnapshotResources = []schema.GroupVersionResource{
+	{Version: "v1", Resource: "pods"},
+	{Version: "v1", Resource: "events"},
+	{Version: "v1", Resource: "services"},
+	{Version: "v1", Resource: "endpoints"},
+	{Version: "v1", Resource: "configmaps"},
+	{Version: "v1", Resource: "secrets"},
+	{Version: "v1", Resource: "persistentvolumeclaims"},
+	{Version: "v1", Resource: "serviceaccounts"},
+	{Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Version: "v1", Resource: "replicasets"},
+	{Group: "apps", Version: "v1", Resource: "statefulsets"},
+	{Group: "apps", Version: "v1", Resource: "daemonsets"},
+	{Group: "batch", Version: "v1", Resource: "jobs"},
+	{Group: "batch", Version: "v1", Resource: "cronjobs"},
+	{Group: "discovery.k8s.io", Version: "v1", Resource: "endpointslices"},
+	{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"},
+	{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "rolebindings"},
+}
+
+// SnapshotClusterResources 是快照中包含的集群级资源
+var SnapshotClusterResources = []schema.GroupVersionResource{
+	{Version: "v1", Resource: "nodes"},
+	{Version: "v1", Resource: "namespaces"},
+	{Version: "v1", Resource: "persistentvolumes"},
+	{Group: "storage.k8s.io", Version: "v1", Resource: "storageclasses"},
+	{Group: "storage.k8s.io", Version: "v1", Resource: "volumeattachments"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterrolebindings"},
+}
+
+// SnapshotMeta 是快照的描述信息，存放在压缩包的 manifest.json 中
+type SnapshotMeta struct {
+	CreatedAt  time.Time `json:"createdAt"`
+	Context    string    `json:"context,omitempty"`
+	Namespaces []string  `json:"namespaces,omitempty"`
+	// 每种资源的对象数量
+	Counts map[string]int `json:"counts"`
+	Logs   int            `json:"logs"`
+}
+
+// Snapshot 是集群在某一时刻的只读副本
+type Snapshot struct {
+	Meta SnapshotMeta
+	// key 为资源名，例如 pods、deployments.apps
+	Objects map[string][]unstructured.Unstructured
+	// key 为 namespace/pod
+	Logs map[string]string
+}
+
+// WriteSnapshot 把快照写成 tar.gz，布局为：
+// manifest.json、objects/<resource>.yaml、logs/<namespace>/<pod>.log
+func WriteSnapshot(w io.Writer, s *Snapshot) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	s.Meta.Counts = map[string]int{}
+	resources := make([]string, 0, len(s.Objects))
+	for r := range s.Objects {
+		resources = append(resources, r)
+	}
+	sort.Strings(resources)
+	for _, r := range resources {
+		items := s.Objects[r]
+		s.Meta.Counts[r] = len(items)
+		list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "List"}}
+		for _, item := range items {
+			RedactSecretObject(&item)
+			list.Items = append(list.Items, item)
+		}
+		data, err := list.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, "objects/"+r+".yaml", data); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0, len(s.Logs))
+	for k := range s.Logs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := writeTarFile(tw, "logs/"+k+".log", []byte(s.Logs[k])); err != nil {
+			return err
+		}
+	}
+	s.Meta.Logs = len(s.Logs)
+
+	manifest, err := json.MarshalIndent(s.Meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, "manifest.json", manifest); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// ReadSnapshot 读取 tar.gz 快照，返回可直接交给 NewFakeClientGo 的对象和日志
+func ReadSnapshot(file string) ([]runtime.Object, map[string]string, *SnapshotMeta, error) {
+	f, err := os.Open(expandHome(file))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%s 不是有效的快照: %w", file, err)
+	}
+	defer gz.Close()
+
+	var objects []runtime.Object
+	logs := map[string]string{}
+	meta := &SnapshotMeta{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		name := path.Clean(hdr.Name)
+		switch {
+		case name == "manifest.json":
+			if err := json.Unmarshal(data, meta); err != nil {
+				return nil, nil, nil, err
+			}
+		case strings.HasPrefix(name, "objects/"):
+			objs, err := DecodeObjects(data)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("%s: %w", name, err)
+			}
+			objects = append(objects, objs...)
+		case strings.HasPrefix(name, "logs/") && strings.HasSuffix(name, ".log"):
+			logs[strings.TrimSuffix(strings.TrimPrefix(name, "logs/"), ".log")] = string(data)
+		}
+	}
+	return objects, logs, meta, nil
+}
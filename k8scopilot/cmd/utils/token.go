@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// BudgetConfig 对应配置文件中的 budget 段
+type BudgetConfig struct {
+	// 模型回复的最大 token 数，默认 500
+	MaxResponseTokens int `json:"maxResponseTokens"`
+	// 提示词的最大 token 数，默认 12000，超过模型上下文窗口时按窗口计算
+	MaxPromptTokens int `json:"maxPromptTokens"`
+}
+
+// modelProfile 描述模型的上下文窗口和粗略的分词比例
+type modelProfile struct {
+	contextWindow int
+	// 平均每个 token 对应的 ASCII 字符数
+	asciiPerToken float64
+	// 平均每个非 ASCII 字符（中文等）对应的 token 数
+	tokensPerRune float64
+}
+
+// 按前缀匹配，越具体的前缀放在越前面
+var modelProfiles = []struct {
+	prefix  string
+	profile modelProfile
+}{
+	{"gpt-4o", modelProfile{128000, 4, 0.8}},
+	{"gpt-4-turbo", modelProfile{128000, 4, 1.2}},
+	{"gpt-4-32k", modelProfile{32768, 4, 1.2}},
+	{"gpt-4", modelProfile{8192, 4, 1.2}},
+	{"gpt-3.5-turbo", modelProfile{16385, 4, 1.2}},
+	{"deepseek", modelProfile{64000, 3.5, 0.7}},
+	{"qwen", modelProfile{32768, 3.5, 0.7}},
+}
+
+var defaultModelProfile = modelProfile{8192, 4, 1.2}
+
+func profileFor(model string) modelProfile {
+	model = strings.ToLower(model)
+	for _, p := range modelProfiles {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.profile
+		}
+	}
+	return defaultModelProfile
+}
+
+// ContextWindow 返回模型的上下文窗口大小
+func ContextWindow(model string) int {
+	return profileFor(model).contextWindow
+}
+
+// CountTokens 估算文本在指定模型下的 token 数
+// 这是按字符类别和模型前缀估算的经验值，不是各模型真实的分词器，结果偏保守
+func CountTokens(model, text string) int {
+	p := profileFor(model)
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < 128 {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return int(math.Ceil(float64(ascii)/p.asciiPerToken + float64(other)*p.tokensPerRune))
+}
+
+// TruncateToTokens 截断文本使其不超过 maxTokens，keepTail 为 true 时保留末尾
+func TruncateToTokens(model, text string, maxTokens int, keepTail bool) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	if CountTokens(model, text) <= maxTokens {
+		return text
+	}
+	runes := []rune(text)
+	// 二分查找可以保留的最大字符数
+	lo, hi := 0, len(runes)
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		var part string
+		if keepTail {
+			part = string(runes[len(runes)-mid:])
+		} else {
+			part = string(runes[:mid])
+		}
+		if CountTokens(model, part)+truncatedMarkerTokens <= maxTokens {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	if keepTail {
+		return truncatedMarker + string(runes[len(runes)-lo:])
+	}
+	return string(runes[:lo]) + truncatedMarker
+}
+
+const (
+	truncatedMarker       = "\n...(已截断)...\n"
+	truncatedMarkerTokens = 8
+)
+
+// PromptBudget 负责把模型的上下文窗口分配给提示词的各个部分
+type PromptBudget struct {
+	Model string
+	// 回复预留的 token 数
+	ResponseTokens int
+	// 提示词可用的 token 数
+	PromptTokens int
+}
+
+// 提示词默认的最大 token 数
+const defaultPromptTokens = 12000
+
+// NewPromptBudget 根据模型和配置计算提示词预算
+func NewPromptBudget(model string, cfg BudgetConfig) *PromptBudget {
+	response := cfg.MaxResponseTokens
+	if response <= 0 {
+		response = 500
+	}
+	// 默认不用满上下文窗口，长日志会被精简，避免单次分析的费用过高
+	prompt := cfg.MaxPromptTokens
+	if prompt <= 0 {
+		prompt = defaultPromptTokens
+	}
+	// 预留一部分给消息格式本身的开销
+	if window := ContextWindow(model) - response - 100; prompt > window {
+		prompt = window
+	}
+	return &PromptBudget{Model: model, ResponseTokens: response, PromptTokens: prompt}
+}
+
+// BudgetSection 是提示词中长度可变的一部分
+type BudgetSection struct {
+	Name string
+	// 完整内容需要的 token 数
+	Need int
+	// 预算不足时的分配权重
+	Weight float64
+}
+
+// Allocate 扣除固定部分后按权重分配剩余预算
+// 需求小于应得份额的部分只拿需要的量，省下的预算再分给其它部分
+func (b *PromptBudget) Allocate(fixed int, sections []BudgetSection) map[string]int {
+	result := make(map[string]int, len(sections))
+	available := b.PromptTokens - fixed
+	if available <= 0 {
+		for _, s := range sections {
+			result[s.Name] = 0
+		}
+		return result
+	}
+
+	pending := make([]BudgetSection, len(sections))
+	copy(pending, sections)
+	sort.SliceStable(pending, func(i, j int) bool {
+		return float64(pending[i].Need)/weightOf(pending[i]) < float64(pending[j].Need)/weightOf(pending[j])
+	})
+	for len(pending) > 0 {
+		totalWeight := 0.0
+		for _, s := range pending {
+			totalWeight += weightOf(s)
+		}
+		s := pending[0]
+		share := int(float64(available) * weightOf(s) / totalWeight)
+		if s.Need <= share {
+			result[s.Name] = s.Need
+			available -= s.Need
+			pending = pending[1:]
+			continue
+		}
+		// 剩余的部分都超出了应得份额，按权重瓜分
+		for _, s := range pending {
+			result[s.Name] = int(float64(available) * weightOf(s) / totalWeight)
+		}
+		break
+	}
+	return result
+}
+
+func weightOf(s BudgetSection) float64 {
+	if s.Weight <= 0 {
+		return 1
+	}
+	return s.Weight
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountTokens(t *testing.T) {
+	tests := []struct {
+		model, text string
+		want        int
+	}{
+		{"gpt-4o", "", 0},
+		{"gpt-4o", "abcdefgh", 2},
+		{"gpt-4o", "abcdefghi", 3},
+		{"gpt-4o", "问题诊断", 4},
+		{"gpt-4", "问题诊断", 5},
+		{"deepseek-chat", "abcdefg", 2},
+		// 未知模型按默认值估算
+		{"my-model", "abcdefgh", 2},
+	}
+	for _, tt := range tests {
+		if got := CountTokens(tt.model, tt.text); got != tt.want {
+			t.Errorf("CountTokens(%q, %q) = %d，期望 %d", tt.model, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateToTokens(t *testing.T) {
+	text := strings.Repeat("a", 400) + "END"
+	tests := []struct {
+		name      string
+		maxTokens int
+		keepTail  bool
+		check     func(string) bool
+	}{
+		{"不需要截断", 1000, false, func(s string) bool { return s == text }},
+		{"保留开头", 50, false, func(s string) bool { return strings.HasSuffix(s, truncatedMarker) && !strings.Contains(s, "END") }},
+		{"保留末尾", 50, true, func(s string) bool { return strings.HasPrefix(s, truncatedMarker) && strings.HasSuffix(s, "END") }},
+		{"预算为 0", 0, false, func(s string) bool { return s == "" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateToTokens("gpt-4o", text, tt.maxTokens, tt.keepTail)
+			if !tt.check(got) {
+				t.Errorf("结果不对: %q", got)
+			}
+			if tt.maxTokens > 0 && CountTokens("gpt-4o", got) > tt.maxTokens {
+				t.Errorf("超出预算: %d > %d", CountTokens("gpt-4o", got), tt.maxTokens)
+			}
+		})
+	}
+}
+
+func TestNewPromptBudget(t *testing.T) {
+	tests := []struct {
+		model            string
+		cfg              BudgetConfig
+		prompt, response int
+	}{
+		{"gpt-4o", BudgetConfig{}, 12000, 500},
+		{"gpt-4o", BudgetConfig{MaxPromptTokens: 50000, MaxResponseTokens: 1000}, 50000, 1000},
+		// 不超过上下文窗口
+		{"gpt-4", BudgetConfig{}, 8192 - 500 - 100, 500},
+		{"gpt-4", BudgetConfig{MaxPromptTokens: 100000}, 8192 - 500 - 100, 500},
+	}
+	for _, tt := range tests {
+		b := NewPromptBudget(tt.model, tt.cfg)
+		if b.PromptTokens != tt.prompt || b.ResponseTokens != tt.response {
+			t.Errorf("%s %+v: prompt %d response %d，期望 %d %d", tt.model, tt.cfg, b.PromptTokens, b.ResponseTokens, tt.prompt, tt.response)
+		}
+	}
+}
+
+func TestPromptBudgetAllocate(t *testing.T) {
+	budget := &PromptBudget{Model: "gpt-4o", PromptTokens: 1000}
+	tests := []struct {
+		name     string
+		fixed    int
+		sections []BudgetSection
+		want     map[string]int
+	}{
+		{
+			name:     "预算足够时按需分配",
+			fixed:    100,
+			sections: []BudgetSection{{Name: "events", Need: 200, Weight: 1}, {Name: "logs", Need: 300, Weight: 2}},
+			want:     map[string]int{"events": 200, "logs": 300},
+		},
+		{
+			name:     "需求小的部分省下的预算分给其它部分",
+			fixed:    100,
+			sections: []BudgetSection{{Name: "events", Need: 100, Weight: 1}, {Name: "logs", Need: 5000, Weight: 1}},
+			want:     map[string]int{"events": 100, "logs": 800},
+		},
+		{
+			name:     "都超出时按权重瓜分",
+			fixed:    100,
+			sections: []BudgetSection{{Name: "events", Need: 5000, Weight: 1}, {Name: "logs", Need: 5000, Weight: 2}},
+			want:     map[string]int{"events": 300, "logs": 600},
+		},
+		{
+			name:     "固定部分已经超出预算",
+			fixed:    2000,
+			sections: []BudgetSection{{Name: "events", Need: 10}},
+			want:     map[string]int{"events": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := budget.Allocate(tt.fixed, tt.sections)
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("%s: %d，期望 %d", name, got[name], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// 变更操作的类型，决定撤销方式
+const (
+	// 撤销时删除创建的对象
+	UndoCreate = "create"
+	// 撤销时重新创建删除前的对象
+	UndoDelete = "delete"
+	// 撤销时恢复修改前的 spec
+	UndoUpdate = "update"
+)
+
+// UndoEntry 记录一次变更之前的对象状态，ActionID 与审计记录的 ID 相同
+type UndoEntry struct {
+	ActionID  string    `json:"actionId"`
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	Group     string    `json:"group"`
+	Version   string    `json:"version"`
+	Resource  string    `json:"resource"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	// 执行变更时使用的集群，撤销时必须相同
+	Context string `json:"context,omitempty"`
+	// 变更前的对象，已去掉 status 和服务端字段；create 时为空
+	Object map[string]any `json:"object,omitempty"`
+	// 变更前就已保存，变更成功后清除；程序在变更过程中退出时保持为 true，对象可能已被修改
+	Pending bool `json:"pending,omitempty"`
+	// 已撤销时为撤销操作的审计记录 ID
+	UndoneBy string `json:"undoneBy,omitempty"`
+}
+
+// GVR 返回对象的资源类型
+func (e *UndoEntry) GVR() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: e.Group, Version: e.Version, Resource: e.Resource}
+}
+
+// SetGVR 设置对象的资源类型
+func (e *UndoEntry) SetGVR(gvr schema.GroupVersionResource) {
+	e.Group, e.Version, e.Resource = gvr.Group, gvr.Version, gvr.Resource
+}
+
+// Describe 返回撤销时要执行的操作
+func (e *UndoEntry) Describe() string {
+	target := e.Resource + "/" + e.Name
+	if e.Namespace != "" {
+		target += "（命名空间 " + e.Namespace + "）"
+	}
+	switch e.Operation {
+	case UndoCreate:
+		return "删除创建的 " + target
+	case UndoDelete:
+		return "重新创建被删除的 " + target
+	default:
+		return "恢复 " + target + " 修改前的 spec"
+	}
+}
+
+// 由 apiserver 维护的元数据字段，重新创建时必须去掉
+var serverSetMetadata = []string{
+	"uid", "resourceVersion", "generation", "creationTimestamp",
+	"deletionTimestamp", "deletionGracePeriodSeconds", "managedFields", "selfLink",
+}
+
+// StripServerFields 返回去掉 status 和服务端字段的对象副本，可以直接用于重新创建
+func StripServerFields(obj *unstructured.Unstructured) map[string]any {
+	out := obj.DeepCopy()
+	delete(out.Object, "status")
+	for _, field := range serverSetMetadata {
+		unstructured.RemoveNestedField(out.Object, "metadata", field)
+	}
+	// 原 ClusterIP 可能已被其它 Service 占用，交给 apiserver 重新分配；headless Service 保留 None
+	if out.GetKind() == "Service" {
+		if ip, _, _ := unstructured.NestedString(out.Object, "spec", "clusterIP"); ip != "None" {
+			unstructured.RemoveNestedField(out.Object, "spec", "clusterIP")
+			unstructured.RemoveNestedField(out.Object, "spec", "clusterIPs")
+		}
+	}
+	return out.Object
+}
+
+// UndoStore 把撤销信息保存在本地目录，每个操作一个文件
+// 删除 Secret 前的快照包含 Secret 的值，因此文件只允许本人读取
+type UndoStore struct {
+	dir string
+}
+
+// NewUndoStore 创建 UndoStore，目录为 <dataDir>/undo
+func NewUndoStore(dataDir string) *UndoStore {
+	return &UndoStore{dir: filepath.Join(dataDir, "undo")}
+}
+
+// Save 保存撤销信息
+func (s *UndoStore) Save(e *UndoEntry) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(s.dir, e.ActionID+".json"), data, 0o600)
+}
+
+// Load 按操作 ID 读取撤销信息，id 可以只写前缀
+func (s *UndoStore) Load(id string) (*UndoEntry, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var matched []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if strings.HasPrefix(name, id) {
+			matched = append(matched, name)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("操作 %s 没有可撤销的记录", id)
+	case 1:
+	default:
+		return nil, fmt.Errorf("前缀 %s 匹配到 %d 个操作，请写出更长的 id", id, len(matched))
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, matched[0]+".json"))
+	if err != nil {
+		return nil, err
+	}
+	e := &UndoEntry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Remove 删除撤销信息，用于变更没有执行成功的操作
+func (s *UndoStore) Remove(id string) error {
+	err := os.Remove(filepath.Join(s.dir, id+".json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestStripServerFields(t *testing.T) {
+	metadata := map[string]any{
+		"name": "web", "namespace": "shop", "labels": map[string]any{"app": "web"},
+		"uid": "d1", "resourceVersion": "42", "generation": int64(3), "creationTimestamp": "2026-10-19T07:40:00Z",
+		"managedFields": []any{map[string]any{"manager": "kubectl"}},
+	}
+	tests := []struct {
+		name string
+		obj  map[string]any
+		// 结果中应保留和应去掉的字段
+		keep, drop [][]string
+	}{
+		{
+			name: "Deployment",
+			obj:  map[string]any{"kind": "Deployment", "metadata": metadata, "spec": map[string]any{"replicas": int64(2)}, "status": map[string]any{"replicas": int64(2)}},
+			keep: [][]string{{"metadata", "name"}, {"metadata", "labels"}, {"spec", "replicas"}},
+			drop: [][]string{{"status"}, {"metadata", "uid"}, {"metadata", "resourceVersion"}, {"metadata", "generation"}, {"metadata", "creationTimestamp"}, {"metadata", "managedFields"}},
+		},
+		{
+			name: "Service 的 ClusterIP 交给 apiserver 重新分配",
+			obj:  map[string]any{"kind": "Service", "metadata": metadata, "spec": map[string]any{"clusterIP": "10.96.0.12", "clusterIPs": []any{"10.96.0.12"}, "ports": []any{}}},
+			keep: [][]string{{"spec", "ports"}},
+			drop: [][]string{{"spec", "clusterIP"}, {"spec", "clusterIPs"}},
+		},
+		{
+			name: "headless Service 保留 None",
+			obj:  map[string]any{"kind": "Service", "metadata": metadata, "spec": map[string]any{"clusterIP": "None"}},
+			keep: [][]string{{"spec", "clusterIP"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := &unstructured.Unstructured{Object: tt.obj}
+			got := StripServerFields(obj)
+			for _, path := range tt.keep {
+				if _, found, _ := unstructured.NestedFieldNoCopy(got, path...); !found {
+					t.Errorf("%s 不应去掉", strings.Join(path, "."))
+				}
+			}
+			for _, path := range tt.drop {
+				if _, found, _ := unstructured.NestedFieldNoCopy(got, path...); found {
+					t.Errorf("%s 应去掉", strings.Join(path, "."))
+				}
+			}
+			// 原对象不变
+			if obj.GetUID() != "d1" {
+				t.Error("不应修改原对象")
+			}
+		})
+	}
+}
+
+func TestUndoStore(t *testing.T) {
+	store := NewUndoStore(t.TempDir())
+	if _, err := store.Load("20261019"); err == nil {
+		t.Error("目录不存在时应报错")
+	}
+	for _, id := range []string{"20261019-aaa", "20261019-abc", "20261020-xyz"} {
+		if err := store.Save(&UndoEntry{ActionID: id, Operation: UndoDelete, Name: id, Context: "prod", Pending: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		id, want, wantErr string
+	}{
+		{"20261020", "20261020-xyz", ""},
+		{"20261019-ab", "20261019-abc", ""},
+		{"20261019", "", "匹配到 2 个操作"},
+		{"20261021", "", "没有可撤销的记录"},
+	}
+	for _, tt := range tests {
+		e, err := store.Load(tt.id)
+		switch {
+		case tt.wantErr != "":
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load(%q): 期望错误 %q，实际 %v", tt.id, tt.wantErr, err)
+			}
+		case err != nil || e.ActionID != tt.want || e.Context != "prod" || !e.Pending:
+			t.Errorf("Load(%q) = %+v, %v", tt.id, e, err)
+		}
+	}
+
+	if err := store.Remove("20261020-xyz"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load("20261020"); err == nil {
+		t.Error("删除后不应再读到")
+	}
+	if err := store.Remove("20261020-xyz"); err != nil {
+		t.Errorf("删除不存在的记录不应报错: %v", err)
+	}
+}
@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageConfig 对应配置文件中的 usage 段
+type UsageConfig struct {
+	// 账本文件，默认 <dataDir>/usage.jsonl
+	Ledger string `json:"ledger"`
+	// 覆盖或补充内置价格表，key 为模型名前缀
+	Prices map[string]ModelPrice `json:"prices"`
+	// 费用上限（美元），0 表示不限制，超出后拒绝继续调用模型
+	SessionLimit float64 `json:"sessionLimit"`
+	DailyLimit   float64 `json:"dailyLimit"`
+	MonthlyLimit float64 `json:"monthlyLimit"`
+}
+
+// ModelPrice 是每百万 token 的价格（美元）
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// 内置价格表，按模型名前缀匹配，最长前缀优先
+var defaultPrices = map[string]ModelPrice{
+	"gpt-4o":        {Input: 2.5, Output: 10},
+	"gpt-4o-mini":   {Input: 0.15, Output: 0.6},
+	"gpt-4-turbo":   {Input: 10, Output: 30},
+	"gpt-4":         {Input: 30, Output: 60},
+	"gpt-3.5-turbo": {Input: 0.5, Output: 1.5},
+	"deepseek":      {Input: 0.55, Output: 2.19},
+	"qwen":          {Input: 0.3, Output: 0.6},
+	// embedding 模型只有输入价格
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+	"text-embedding-ada-002": {Input: 0.1},
+}
+
+// UsageRecord 是账本中的一条记录，对应一次模型调用
+type UsageRecord struct {
+	Time             time.Time `json:"time"`
+	Operation        string    `json:"operation"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	LatencyMs        int64     `json:"latencyMs"`
+	Cost             float64   `json:"cost"`
+	// 命中缓存的调用不产生费用
+	Cached bool `json:"cached,omitempty"`
+}
+
+// ErrBudgetExceeded 表示费用已达到配置的上限
+var ErrBudgetExceeded = fmt.Errorf("已达到模型调用费用上限")
+
+// UsageTracker 记录模型调用的 token 用量和费用，并检查费用上限
+type UsageTracker struct {
+	cfg    UsageConfig
+	ledger string
+
+	mu      sync.Mutex
+	session []UsageRecord
+	// 本次会话开始前账本中当天和当月的费用
+	dailyBase   float64
+	monthlyBase float64
+}
+
+// NewUsageTracker 创建 UsageTracker，并从账本中读取当天和当月已有的费用
+func NewUsageTracker(cfg UsageConfig, dataDir string) (*UsageTracker, error) {
+	ledger := cfg.Ledger
+	if ledger == "" {
+		ledger = filepath.Join(dataDir, "usage.jsonl")
+	}
+	t := &UsageTracker{cfg: cfg, ledger: expandHome(ledger)}
+	if cfg.DailyLimit > 0 || cfg.MonthlyLimit > 0 {
+		records, err := ReadUsageLedger(t.ledger)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		for _, r := range records {
+			if sameMonth(r.Time, now) {
+				t.monthlyBase += r.Cost
+				if r.Time.YearDay() == now.YearDay() {
+					t.dailyBase += r.Cost
+				}
+			}
+		}
+	}
+	return t, nil
+}
+
+// Ledger 返回账本文件路径
+func (t *UsageTracker) Ledger() string {
+	return t.ledger
+}
+
+// Check 在调用模型之前检查费用上限
+func (t *UsageTracker) Check() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	spent := 0.0
+	for _, r := range t.session {
+		spent += r.Cost
+	}
+	if t.cfg.SessionLimit > 0 && spent >= t.cfg.SessionLimit {
+		return fmt.Errorf("%w: 本次会话已花费 $%.4f，上限 $%.2f", ErrBudgetExceeded, spent, t.cfg.SessionLimit)
+	}
+	if t.cfg.DailyLimit > 0 && t.dailyBase+spent >= t.cfg.DailyLimit {
+		return fmt.Errorf("%w: 今日已花费 $%.4f，上限 $%.2f", ErrBudgetExceeded, t.dailyBase+spent, t.cfg.DailyLimit)
+	}
+	if t.cfg.MonthlyLimit > 0 && t.monthlyBase+spent >= t.cfg.MonthlyLimit {
+		return fmt.Errorf("%w: 本月已花费 $%.4f，上限 $%.2f", ErrBudgetExceeded, t.monthlyBase+spent, t.cfg.MonthlyLimit)
+	}
+	return nil
+}
+
+// Record 计算费用并追加到账本
+func (t *UsageTracker) Record(r UsageRecord) error {
+	if t == nil {
+		return nil
+	}
+	if !r.Cached {
+		r.Cost = t.Cost(r.Model, r.PromptTokens, r.CompletionTokens)
+	}
+	t.mu.Lock()
+	t.session = append(t.session, r)
+	t.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(t.ledger), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(t.ledger, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Cost 根据价格表计算一次调用的费用
+func (t *UsageTracker) Cost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := lookupPrice(t.cfg.Prices, model)
+	if !ok {
+		price, _ = lookupPrice(defaultPrices, model)
+	}
+	return (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1e6
+}
+
+// Summary 返回本次会话的用量汇总，没有调用时返回空字符串
+func (t *UsageTracker) Summary() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.session) == 0 {
+		return ""
+	}
+	return FormatUsage(t.session)
+}
+
+func lookupPrice(prices map[string]ModelPrice, model string) (ModelPrice, bool) {
+	model = strings.ToLower(model)
+	best := ""
+	for prefix := range prices {
+		if strings.HasPrefix(model, strings.ToLower(prefix)) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return prices[best], true
+}
+
+func sameMonth(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.Month() == b.Month()
+}
+
+// ReadUsageLedger 读取账本，文件不存在时返回空
+func ReadUsageLedger(path string) ([]UsageRecord, error) {
+	f, err := os.Open(expandHome(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var records []UsageRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// 跳过写了一半的行
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+// FormatUsage 按模型和调用位置汇总用量
+func FormatUsage(records []UsageRecord) string {
+	type total struct {
+		calls            int
+		cached           int
+		promptTokens     int
+		completionTokens int
+		latencyMs        int64
+		cost             float64
+	}
+	groups := map[string]*total{}
+	var sum total
+	for _, r := range records {
+		key := r.Model + "\t" + r.Operation
+		g, ok := groups[key]
+		if !ok {
+			g = &total{}
+			groups[key] = g
+		}
+		for _, t := range []*total{g, &sum} {
+			t.calls++
+			if r.Cached {
+				t.cached++
+			}
+			t.promptTokens += r.PromptTokens
+			t.completionTokens += r.CompletionTokens
+			t.latencyMs += r.LatencyMs
+			t.cost += r.Cost
+		}
+	}
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-28s %-20s %6s %6s %10s %10s %10s %10s\n", "MODEL", "OPERATION", "CALLS", "CACHED", "PROMPT", "COMPLETION", "AVG(ms)", "COST($)")
+	for _, k := range keys {
+		g := groups[k]
+		parts := strings.SplitN(k, "\t", 2)
+		fmt.Fprintf(&b, "%-28s %-20s %6d %6d %10d %10d %10d %10.4f\n",
+			parts[0], parts[1], g.calls, g.cached, g.promptTokens, g.completionTokens, g.latencyMs/int64(g.calls), g.cost)
+	}
+	fmt.Fprintf(&b, "%-28s %-20s %6d %6d %10d %10d %10s %10.4f", "TOTAL", "", sum.calls, sum.cached, sum.promptTokens, sum.completionTokens, "", sum.cost)
+	return b.String()
+}
+
+type operationKey struct{}
+
+// WithOperation 标记本次模型调用来自哪里，用于用量统计
+func WithOperation(ctx context.Context, operation string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation)
+}
+
+func operationFrom(ctx context.Context) string {
+	if op, ok := ctx.Value(operationKey{}).(string); ok {
+		return op
+	}
+	return "unknown"
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// workloadRef 标识 Pod 所属的顶层工作负载，独立 Pod 时为 Pod 本身
+type workloadRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (w workloadRef) String() string {
+	return w.Kind + "/" + w.Name
+}
+
+// ownerResolver 沿 ownerReferences 向上查找顶层工作负载：
+// ReplicaSet→Deployment、Job→CronJob，StatefulSet、DaemonSet 直接返回
+// 同一个 ReplicaSet/Job 只查询一次
+type ownerResolver struct {
+	clientGo *utils.ClientGo
+	cache    map[string]*metav1.OwnerReference
+}
+
+func newOwnerResolver(clientGo *utils.ClientGo) *ownerResolver {
+	return &ownerResolver{clientGo: clientGo, cache: map[string]*metav1.OwnerReference{}}
+}
+
+func (r *ownerResolver) resolve(pod *corev1.Pod) workloadRef {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return workloadRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}
+	}
+	workload := workloadRef{Kind: ref.Kind, Namespace: pod.Namespace, Name: ref.Name}
+	switch ref.Kind {
+	case "ReplicaSet", "Job":
+		// 查不到上一级时停在 ReplicaSet/Job，例如单独创建的 Job
+		if parent := r.parent(workload); parent != nil {
+			workload.Kind, workload.Name = parent.Kind, parent.Name
+		}
+	}
+	return workload
+}
+
+func (r *ownerResolver) parent(w workloadRef) *metav1.OwnerReference {
+	key := w.Namespace + "/" + w.Kind + "/" + w.Name
+	if ref, ok := r.cache[key]; ok {
+		return ref
+	}
+	var ref *metav1.OwnerReference
+	switch w.Kind {
+	case "ReplicaSet":
+		if rs, err := r.clientGo.ClientSet.AppsV1().ReplicaSets(w.Namespace).Get(context.TODO(), w.Name, metav1.GetOptions{}); err == nil {
+			ref = metav1.GetControllerOf(rs)
+		}
+	case "Job":
+		if job, err := r.clientGo.ClientSet.BatchV1().Jobs(w.Namespace).Get(context.TODO(), w.Name, metav1.GetOptions{}); err == nil {
+			ref = metav1.GetControllerOf(job)
+		}
+	}
+	r.cache[key] = ref
+	return ref
+}
+
+// 重启次数最多的容器，用于挑选代表 Pod
+func podRestarts(pod *corev1.Pod) int32 {
+	var restarts int32
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.RestartCount > restarts {
+			restarts = cs.RestartCount
+		}
+	}
+	return restarts
+}
@@ -0,0 +1,59 @@
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// NewFixtureClientGo 用目录中的清单构造假集群，当前身份为 FakeUser，权限检查按清单中的 RBAC 对象回答
+func NewFixtureClientGo(dir string) (*utils.ClientGo, error) {
+	objects, logs, err := LoadFixtures(dir)
+	if err != nil {
+		return nil, err
+	}
+	c := utils.NewFakeClientGo(objects, logs)
+	clientSet := c.ClientSet.(*fake.Clientset)
+	clientSet.PrependReactor("create", "selfsubjectaccessreviews", accessReactor(clientSet.Tracker()))
+	return c, nil
+}
+
+// LoadFixtures 读取目录中的 YAML/JSON 清单作为假集群的对象
+// 容器日志放在 <dir>/logs/<namespace>/<pod>.log
+func LoadFixtures(dir string) ([]runtime.Object, map[string]string, error) {
+	var objects []runtime.Object
+	logs := map[string]string{}
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(rel, "logs"+string(filepath.Separator)) && strings.HasSuffix(path, ".log"):
+			parts := strings.Split(strings.TrimSuffix(rel, ".log"), string(filepath.Separator))
+			if len(parts) == 3 {
+				logs[parts[1]+"/"+parts[2]] = string(data)
+			}
+		case strings.HasSuffix(path, ".yaml"), strings.HasSuffix(path, ".yml"), strings.HasSuffix(path, ".json"):
+			objs, err := utils.DecodeObjects(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", rel, err)
+			}
+			objects = append(objects, objs...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return objects, logs, nil
+}
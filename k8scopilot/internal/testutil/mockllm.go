@@ -0,0 +1,192 @@
+// Package testutil 提供测试和离线调试用的模型服务和假集群，不会编译进 k8scopilot
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/cmd/utils"
+	"github.com/sashabaranov/go-openai"
+	"sigs.k8s.io/yaml"
+)
+
+// MockResponse 是一条预设的模型回复
+type MockResponse struct {
+	// 不为空时只匹配最后一条用户消息包含该子串的请求
+	Match string `json:"match"`
+	// 回复文本
+	Content string `json:"content"`
+	// 工具调用，arguments 为 JSON 字符串
+	ToolCalls []MockToolCall `json:"toolCalls"`
+	// 为 0 时按内容长度估算
+	PromptTokens     int `json:"promptTokens"`
+	CompletionTokens int `json:"completionTokens"`
+}
+
+// MockToolCall 是预设回复中的一次工具调用
+type MockToolCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// MockLLMServer 是兼容 OpenAI Chat Completions 接口的本地服务，按脚本依次回放回复
+// 用于在没有网络的情况下跑通 functionCalling、analyzeSinglePod 等流程
+type MockLLMServer struct {
+	mu       sync.Mutex
+	script   []MockResponse
+	used     []bool
+	requests []openai.ChatCompletionRequest
+}
+
+// NewMockLLMServer 创建回放服务
+func NewMockLLMServer(script []MockResponse) *MockLLMServer {
+	return &MockLLMServer{script: script, used: make([]bool, len(script))}
+}
+
+// LoadMockScript 从 YAML/JSON 文件读取回放脚本
+func LoadMockScript(path string) ([]MockResponse, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var script []MockResponse
+	if err := yaml.Unmarshal(data, &script); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// Start 在随机端口启动服务，返回可直接作为 BaseURL 使用的地址和关闭函数
+func (m *MockLLMServer) Start() (string, func()) {
+	server := httptest.NewServer(m)
+	return server.URL + "/v1", server.Close
+}
+
+// Requests 返回收到的全部请求，便于检查发送给模型的内容
+func (m *MockLLMServer) Requests() []openai.ChatCompletionRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]openai.ChatCompletionRequest(nil), m.requests...)
+}
+
+func (m *MockLLMServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/embeddings") {
+		m.serveEmbeddings(w, r)
+		return
+	}
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		http.NotFound(w, r)
+		return
+	}
+	var req openai.ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	m.requests = append(m.requests, req)
+	reply, ok := m.next(lastUserMessage(req.Messages))
+	m.mu.Unlock()
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, `{"error":{"message":"mock script exhausted","type":"mock_error"}}`)
+		return
+	}
+
+	msg := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: reply.Content}
+	finish := openai.FinishReasonStop
+	for i, tc := range reply.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, openai.ToolCall{
+			ID:       fmt.Sprintf("call_%d", i+1),
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: tc.Name, Arguments: tc.Arguments},
+		})
+		finish = openai.FinishReasonToolCalls
+	}
+	promptTokens := reply.PromptTokens
+	if promptTokens == 0 {
+		for _, message := range req.Messages {
+			promptTokens += utils.CountTokens(req.Model, message.Content)
+		}
+	}
+	completionTokens := reply.CompletionTokens
+	if completionTokens == 0 {
+		completionTokens = utils.CountTokens(req.Model, reply.Content)
+	}
+
+	resp := openai.ChatCompletionResponse{
+		ID:      fmt.Sprintf("mock-%d", len(m.Requests())),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []openai.ChatCompletionChoice{{Index: 0, Message: msg, FinishReason: finish}},
+		Usage: openai.Usage{
+			PromptTokens:     promptTokens,
+			CompletionTokens: completionTokens,
+			TotalTokens:      promptTokens + completionTokens,
+		},
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// mock 向量的维度
+const mockEmbeddingSize = 256
+
+// serveEmbeddings 把每个词散列到固定维度上计数，词重叠越多的文本相似度越高，不消耗脚本
+func (m *MockLLMServer) serveEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req openai.EmbeddingRequestStrings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := openai.EmbeddingResponse{Object: "list", Model: req.Model}
+	for i, input := range req.Input {
+		vector := make([]float32, mockEmbeddingSize)
+		for _, term := range utils.RunbookTerms(input) {
+			h := fnv.New32a()
+			h.Write([]byte(term))
+			vector[h.Sum32()%mockEmbeddingSize]++
+		}
+		resp.Data = append(resp.Data, openai.Embedding{Object: "embedding", Index: i, Embedding: vector})
+		resp.Usage.PromptTokens += utils.CountTokens(string(req.Model), input)
+	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// 优先选择匹配的回复，否则按顺序取第一条未使用且没有 match 的回复
+func (m *MockLLMServer) next(userMessage string) (MockResponse, bool) {
+	for i, r := range m.script {
+		if !m.used[i] && r.Match != "" && strings.Contains(userMessage, r.Match) {
+			m.used[i] = true
+			return r, true
+		}
+	}
+	for i, r := range m.script {
+		if !m.used[i] && r.Match == "" {
+			m.used[i] = true
+			return r, true
+		}
+	}
+	return MockResponse{}, false
+}
+
+func lastUserMessage(messages []openai.ChatCompletionMessage) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == openai.ChatMessageRoleUser {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
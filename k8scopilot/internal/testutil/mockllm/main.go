@@ -0,0 +1,34 @@
+// mockllm 启动本地的 OpenAI 兼容服务，按脚本回放回复，用于离线调试：
+//
+//	go run ./internal/testutil/mockllm --script script.yaml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/TarlyJQ/aiops/k8scopilot/internal/testutil"
+)
+
+func main() {
+	script := flag.String("script", "", "YAML file with the scripted responses")
+	addr := flag.String("addr", "127.0.0.1:8089", "listen address")
+	flag.Parse()
+	if *script == "" {
+		fmt.Println("请用 --script 指定回放脚本")
+		os.Exit(2)
+	}
+
+	responses, err := testutil.LoadMockScript(*script)
+	if err != nil {
+		fmt.Println("读取脚本失败:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("mock-llm 监听 %s，请设置 OPENAI_BASE_URL=http://%s/v1\n", *addr, *addr)
+	if err := http.ListenAndServe(*addr, testutil.NewMockLLMServer(responses)); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,92 @@
+package testutil
+
+import (
+	"slices"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/testing"
+)
+
+// FakeUser 是假集群中当前身份的用户名
+// 清单中没有任何绑定到该用户的 RoleBinding/ClusterRoleBinding 时允许所有操作
+const FakeUser = "fixture-user"
+
+var (
+	clusterRoleBindingsResource = rbacv1.SchemeGroupVersion.WithResource("clusterrolebindings")
+	roleBindingsResource        = rbacv1.SchemeGroupVersion.WithResource("rolebindings")
+	clusterRolesResource        = rbacv1.SchemeGroupVersion.WithResource("clusterroles")
+	rolesResource               = rbacv1.SchemeGroupVersion.WithResource("roles")
+)
+
+// accessReactor 按清单中的 RBAC 对象回答 SelfSubjectAccessReview
+// 直接读取 tracker，因为在 reactor 中调用 clientset 会死锁
+func accessReactor(tracker testing.ObjectTracker) testing.ReactionFunc {
+	return func(action testing.Action) (bool, runtime.Object, error) {
+		review := action.(testing.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview).DeepCopy()
+		if attrs := review.Spec.ResourceAttributes; attrs != nil {
+			review.Status.Allowed = authorize(tracker, attrs)
+		} else {
+			review.Status.Allowed = true
+		}
+		if !review.Status.Allowed {
+			review.Status.Reason = "清单中没有授予 " + FakeUser + " 该权限的规则"
+		}
+		return true, review, nil
+	}
+}
+
+func authorize(tracker testing.ObjectTracker, attrs *authorizationv1.ResourceAttributes) bool {
+	bound := false
+	allowed := false
+	check := func(roleRef rbacv1.RoleRef, namespace string, subjects []rbacv1.Subject) {
+		if !slices.ContainsFunc(subjects, func(s rbacv1.Subject) bool {
+			return s.Kind == rbacv1.UserKind && s.Name == FakeUser
+		}) {
+			return
+		}
+		bound = true
+		if allowed {
+			return
+		}
+		var rules []rbacv1.PolicyRule
+		if roleRef.Kind == "ClusterRole" {
+			if obj, err := tracker.Get(clusterRolesResource, "", roleRef.Name); err == nil {
+				rules = obj.(*rbacv1.ClusterRole).Rules
+			}
+		} else if obj, err := tracker.Get(rolesResource, namespace, roleRef.Name); err == nil {
+			rules = obj.(*rbacv1.Role).Rules
+		}
+		allowed = slices.ContainsFunc(rules, func(r rbacv1.PolicyRule) bool { return ruleAllows(r, attrs) })
+	}
+
+	if obj, err := tracker.List(clusterRoleBindingsResource, rbacv1.SchemeGroupVersion.WithKind("ClusterRoleBinding"), ""); err == nil {
+		for _, b := range obj.(*rbacv1.ClusterRoleBindingList).Items {
+			check(b.RoleRef, "", b.Subjects)
+		}
+	}
+	// RoleBinding 只在自己的命名空间内生效
+	if attrs.Namespace != "" {
+		if obj, err := tracker.List(roleBindingsResource, rbacv1.SchemeGroupVersion.WithKind("RoleBinding"), attrs.Namespace); err == nil {
+			for _, b := range obj.(*rbacv1.RoleBindingList).Items {
+				check(b.RoleRef, b.Namespace, b.Subjects)
+			}
+		}
+	}
+	return allowed || !bound
+}
+
+func ruleAllows(rule rbacv1.PolicyRule, attrs *authorizationv1.ResourceAttributes) bool {
+	resource := attrs.Resource
+	if attrs.Subresource != "" {
+		resource += "/" + attrs.Subresource
+	}
+	matches := func(values []string, v string) bool {
+		return slices.Contains(values, rbacv1.VerbAll) || slices.Contains(values, v)
+	}
+	return matches(rule.Verbs, attrs.Verb) &&
+		matches(rule.APIGroups, attrs.Group) &&
+		matches(rule.Resources, resource) &&
+		(len(rule.ResourceNames) == 0 || attrs.Name != "" && slices.Contains(rule.ResourceNames, attrs.Name))
+}